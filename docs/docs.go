@@ -15,140 +15,314 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/lines": {
+        "/admin/favorites/integrity": {
             "get": {
-                "description": "Provide a list of all the lines",
+                "description": "List the users with favorite stops that no longer exist in the stops dataset, suggesting for each of them the nearest stop to where it was or the one with the closest name",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Bus"
+                    "Admin"
                 ],
-                "summary": "List all of the lines",
+                "summary": "Check the favorite stops of every user",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.Line"
-                            }
+                            "$ref": "#/definitions/api.FavoritesIntegrity"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/stops": {
+        "/admin/stops/reload": {
+            "post": {
+                "description": "Load the stops database file again and switch to it without interrupting the requests in progress. The current dataset keeps being served if the new file is invalid",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reload the stops database",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Dataset"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/tokens": {
             "get": {
-                "description": "Provide a list of all the stops",
+                "description": "List every API token, including the revoked and expired ones, without their secrets",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Bus"
+                    "Admin"
                 ],
-                "summary": "List all of the stops",
+                "summary": "List the API tokens",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/api.Stop"
+                                "$ref": "#/definitions/api.Token"
                             }
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
                     }
                 }
-            }
-        },
-        "/api/stops/find": {
-            "get": {
-                "description": "Provide a list of stops that match the text in their name",
+            },
+            "post": {
+                "description": "Create an API token for a client with the given scopes and optional expiry, bound to the users of some identity providers or to all of them. The token is only returned in this response, as only its hash is stored",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Bus"
+                    "Admin"
                 ],
-                "summary": "Find a stop by text in its name",
+                "summary": "Create an API token",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Text to search for in stop name",
-                        "name": "text",
-                        "in": "query",
+                        "description": "Token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.CreatedToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/tokens/{id}": {
+            "delete": {
+                "description": "Revoke an API token, rejecting the requests made with it from then on. Revoking a token again has no effect",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Revoke an API token",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Token ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     }
                 ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/dataset": {
+            "get": {
+                "description": "Provide the size of the stops dataset currently served, its version and the latest imports from the open data dataset with how many stops and lines they changed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Bus"
+                ],
+                "summary": "Describe the stops dataset",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.Stop"
-                            }
+                            "$ref": "#/definitions/api.Dataset"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/stops/find/location": {
+        "/api/dataset/changes": {
             "get": {
-                "description": "Provide a list of stops in a given radius around a location",
+                "description": "Provide the stops added, removed, renamed or moved and the lines added or removed by the imports of the open data dataset after a version or time, oldest first, so the users whose favorite stops changed can be warned",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Bus"
                 ],
-                "summary": "Find a stop by its location",
+                "summary": "List the changes to the stops dataset",
                 "parameters": [
                     {
-                        "type": "number",
-                        "description": "Latitude",
-                        "name": "lat",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "number",
-                        "description": "Longitude",
-                        "name": "lon",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "number",
-                        "description": "Radius in meters",
-                        "name": "radius",
-                        "in": "query",
-                        "required": true
+                        "type": "string",
+                        "description": "Dataset version or RFC 3339 time after which to list the changes, default every change",
+                        "name": "since",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.Stop"
-                            }
+                            "$ref": "#/definitions/api.DatasetChanges"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/stops/find/location/image": {
+        "/api/departures/nearby": {
             "get": {
-                "description": "Provide the nearby stops for a location and return a PNG image and JSON array",
+                "description": "Provide the next departures from the stops around a location, sorted by time. Only the closest stop is kept for each line and route, and buses that can't be reached walking in time are left out",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Bus"
                 ],
-                "summary": "Get the nearby stops as a PNG image and JSON array",
+                "summary": "Get the next buses leaving near a location",
                 "parameters": [
                     {
                         "type": "number",
@@ -166,14 +340,14 @@ const docTemplate = `{
                     },
                     {
                         "type": "number",
-                        "description": "Radius in meters",
+                        "description": "Radius in meters, up to 2000",
                         "name": "radius",
                         "in": "query",
                         "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "Limit of stops to return, default 9",
+                        "description": "Limit of stops to check, default 10, up to 20",
                         "name": "limit",
                         "in": "query"
                     }
@@ -182,206 +356,417 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.NearbyStops"
+                            "$ref": "#/definitions/api.NearbyDepartures"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/stops/{stop_number}": {
+        "/api/lines": {
             "get": {
-                "description": "Provide a stop by its number",
+                "description": "Provide a list of all the lines",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Bus"
                 ],
-                "summary": "Get a stop by its number",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Stop Number",
-                        "name": "stop_number",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
+                "summary": "List all of the lines",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.Stop"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Line"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/stops/{stop_number}/schedule": {
+        "/api/plan": {
             "get": {
-                "description": "Provide the schedule for a stop",
+                "description": "Provide itineraries combining walks and bus lines between two locations, from fewer to more transfers. Times are estimated from average bus speeds and waiting times",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Bus"
                 ],
-                "summary": "Get the schedule for a stop",
+                "summary": "Plan a trip between two locations",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Stop Number",
-                        "name": "stop_number",
-                        "in": "path",
+                        "type": "string",
+                        "description": "Origin as lat,lon",
+                        "name": "from",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination as lat,lon",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Departure time in RFC 3339 format, default now",
+                        "name": "depart_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of transfers, default 2",
+                        "name": "max_transfers",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.StopSchedule"
+                            "$ref": "#/definitions/api.Plan"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/users/{provider}/{uuid}": {
+        "/api/providers": {
             "get": {
-                "description": "Provide a user by its UUID for a specific provider",
+                "description": "Provide the identity providers that can be used in the user endpoints, along with the format of their UUIDs",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Identity"
                 ],
-                "summary": "Get a user by its UUID for a specific provider",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Provider",
-                        "name": "provider",
-                        "in": "path",
-                        "required": true
+                "summary": "List the identity providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Provider"
+                            }
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "UUID",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
                     }
+                }
+            }
+        },
+        "/api/stops": {
+            "get": {
+                "description": "Provide a list of all the stops",
+                "produces": [
+                    "application/json"
                 ],
+                "tags": [
+                    "Bus"
+                ],
+                "summary": "List all of the stops",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.Identity"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Stop"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a new user",
+            }
+        },
+        "/api/stops/find": {
+            "get": {
+                "description": "Provide a list of stops that match the text in their name, ordered by relevance. Case, accents and small typos are ignored",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Identity"
+                    "Bus"
                 ],
-                "summary": "Create a new user",
+                "summary": "Find a stop by text in its name",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Provider",
-                        "name": "provider",
-                        "in": "path",
+                        "description": "Text to search for in stop name",
+                        "name": "text",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "UUID",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
+                        "type": "integer",
+                        "description": "Limit of stops to return, default 0 (no limit)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of stops to skip, default 0",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.Identity"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Stop"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/users/{provider}/{uuid}/favorite_stops/{stop_number}": {
-            "post": {
-                "description": "Add a favorite stop to a user",
+        "/api/stops/find/location": {
+            "get": {
+                "description": "Provide a list of stops in a given radius around a location, sorted from nearest to farthest, with their distance, bearing and walking time",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Identity"
+                    "Bus"
                 ],
-                "summary": "Add a favorite stop to a user",
+                "summary": "Find a stop by its location",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Provider",
-                        "name": "provider",
-                        "in": "path",
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "lat",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "UUID",
-                        "name": "uuid",
-                        "in": "path",
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "lon",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Stop Number",
-                        "name": "stop_number",
-                        "in": "path",
+                        "type": "number",
+                        "description": "Radius in meters",
+                        "name": "radius",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit of stops to return, default 0 (no limit)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.Identity"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.NearbyStop"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Remove a favorite stop from a user",
+            }
+        },
+        "/api/stops/find/location/image": {
+            "get": {
+                "description": "Provide the nearby stops for a location and return a PNG image and JSON array",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Identity"
+                    "Bus"
                 ],
-                "summary": "Remove a favorite stop from a user",
+                "summary": "Get the nearby stops as a PNG image and JSON array",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Provider",
-                        "name": "provider",
-                        "in": "path",
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "lat",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "UUID",
-                        "name": "uuid",
-                        "in": "path",
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "lon",
+                        "in": "query",
                         "required": true
                     },
+                    {
+                        "type": "number",
+                        "description": "Radius in meters",
+                        "name": "radius",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit of stops to return, default 9",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.NearbyStops"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stops/{stop_number}": {
+            "get": {
+                "description": "Provide a stop by its number",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Bus"
+                ],
+                "summary": "Get a stop by its number",
+                "parameters": [
                     {
                         "type": "integer",
                         "description": "Stop Number",
@@ -394,161 +779,2842 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.Identity"
+                            "$ref": "#/definitions/api.Stop"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/users/{provider}/{uuid}/metadata": {
-            "put": {
-                "description": "Update the metadata of a user",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Identity"
-                ],
-                "summary": "Update the metadata of a user",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Provider",
-                        "name": "provider",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "UUID",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Metadata",
-                        "name": "metadata",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "string"
+        "/api/stops/{stop_number}/reachable": {
+            "get": {
+                "description": "Provide the stops that can be reached by bus from a stop within the given minutes, with their estimated travel times. Bus speed, dwell, wait and transfer penalty default to the server configuration",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Bus"
+                ],
+                "summary": "Get the stops reachable from a stop within a time budget",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Stop Number",
+                        "name": "stop_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Time budget in minutes",
+                        "name": "minutes",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of transfers, default 2",
+                        "name": "max_transfers",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Average bus speed in km/h",
+                        "name": "bus_speed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Seconds a bus spends at each stop",
+                        "name": "dwell",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Average seconds spent waiting for a bus",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Extra seconds added every time the rider changes buses",
+                        "name": "transfer_penalty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include the GeoJSON polygon enclosing the reachable stops, default false",
+                        "name": "hull",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Reachability"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stops/{stop_number}/schedule": {
+            "get": {
+                "description": "Provide the schedule for a stop",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Bus"
+                ],
+                "summary": "Get the schedule for a stop",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Stop Number",
+                        "name": "stop_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.StopSchedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}": {
+            "get": {
+                "description": "Provide a user by its UUID for a specific provider, or a user_not_found problem if it doesn't exist. Favorite stops and lines that no longer exist are flagged as missing. Only the linked identities of the providers the token is bound to are listed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Get a user by its UUID for a specific provider",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a user along with its data. The user disappears right away and is purged for good after the retention period. Its favorite stops and metadata are kept by the identities linked to it, if any",
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Delete a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/export": {
+            "get": {
+                "description": "Provide all the data kept about a user as a JSON file: the identity with its favorite stops, metadata and linked identities, and the history of actions done on it. Only the linked identities of the providers the token is bound to are listed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Export the data of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.IdentityExport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/favorite_lines": {
+            "get": {
+                "description": "Provide the favorite lines of a user in the order they were added. Lines that no longer exist are flagged as missing",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "List the favorite lines of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.FavoriteLine"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/favorite_lines/{line_name}": {
+            "post": {
+                "description": "Add a line to the favorites of a user, identified by the name provided by the bus company",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Add a favorite line to a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Line Name",
+                        "name": "line_name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a line from the favorites of a user, even if it no longer exists",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Remove a favorite line from a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Line Name",
+                        "name": "line_name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/favorite_routes": {
+            "get": {
+                "description": "Provide the favorite trips between two stops of a user in the order they were added, along with the lines that go from the origin to the destination without changing buses. Stops that no longer exist are flagged as missing",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "List the favorite routes of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.FavoriteRoute"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/favorite_routes/{origin}/{destination}": {
+            "post": {
+                "description": "Add a trip from an origin stop to a destination stop to the favorites of a user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Add a favorite route to a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Origin Stop Number",
+                        "name": "origin",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Destination Stop Number",
+                        "name": "destination",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a trip from an origin stop to a destination stop from the favorites of a user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Remove a favorite route from a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Origin Stop Number",
+                        "name": "origin",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Destination Stop Number",
+                        "name": "destination",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/favorite_stops/order": {
+            "put": {
+                "description": "Set the order of the favorite stops of a user, listing the numbers of every one of them once in the new order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Reorder the favorite stops of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.FavoriteStopsOrder"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/favorite_stops/{stop_number}": {
+            "put": {
+                "description": "Add a favorite stop to a user if it isn't a favorite yet. Repeating the request has no further effect",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Make sure a stop is a favorite of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stop Number",
+                        "name": "stop_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a favorite stop to a user, failing if it is already a favorite. Use PUT to add it only if it is missing",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Add a favorite stop to a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stop Number",
+                        "name": "stop_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a favorite stop from a user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Remove a favorite stop from a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stop Number",
+                        "name": "stop_number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change the alias of a favorite stop, such as Home or Work, or the lines the user cares about at it. Only the fields present in the body are changed, and an empty list of lines means all of them",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Update a favorite stop of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stop Number",
+                        "name": "stop_number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Changes",
+                        "name": "favorite",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.FavoriteStopUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/link": {
+            "post": {
+                "description": "Move the user, along with the identities already linked to it, to the account of the identity that created the link code. Favorite stops are merged, and so is the metadata when both are JSON objects, keeping the values of the account linked to on conflicts",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Link a user to the account of another identity",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Link code",
+                        "name": "link",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.LinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/link-code": {
+            "post": {
+                "description": "Provide a one-time code that links another identity, such as the same rider in another app, to the account of the user. Linked identities share their favorite stops and metadata. The code replaces the previous one of the user and expires after a few minutes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Create a code to link another identity to the account of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.LinkCode"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{provider}/{uuid}/metadata": {
+            "put": {
+                "description": "Replace the metadata of a user, and of the identities linked to it, with a JSON object. The metadata must fit the size limit and match the JSON Schema configured by the operator, if any. Tokens bound to providers can only change it when they are bound to the providers of every linked identity",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Replace the metadata of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Metadata",
+                        "name": "metadata",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Apply a JSON merge patch (RFC 7396) to the metadata of a user, and of the identities linked to it: the keys of the patch replace the current ones, objects are merged and null values remove the keys. The result must fit the size limit and match the JSON Schema configured by the operator, if any. Tokens bound to providers can only change it when they are bound to the providers of every linked identity",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Identity"
+                ],
+                "summary": "Update the metadata of a user",
+                "parameters": [
+                    {
+                        "enum": [
+                            "telegram",
+                            "discord",
+                            "matrix",
+                            "web",
+                            "whatsapp"
+                        ],
+                        "type": "string",
+                        "description": "Provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Health endpoint",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Health endpoint",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.Coordinates": {
+            "type": "object",
+            "properties": {
+                "lat": {
+                    "description": "Lat is the latitude of the location",
+                    "type": "number"
+                },
+                "lon": {
+                    "description": "Lon is the longitude of the location",
+                    "type": "number"
+                }
+            }
+        },
+        "api.CreatedToken": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "CreatedAt is when the token was created",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the token stops being valid, if it ever does",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the token",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name identifies the client using the token, such as telegram-bot or dashboard",
+                    "type": "string"
+                },
+                "providers": {
+                    "description": "Providers are the identity providers whose users the token can access, all of them if empty",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ProviderType"
+                    }
+                },
+                "revoked_at": {
+                    "description": "RevokedAt is when the token was revoked, if it was",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes are the permissions granted to the token",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Scope"
+                    }
+                },
+                "token": {
+                    "description": "Secret is the value to send as bearer token. It can't be retrieved again",
+                    "type": "string"
+                }
+            }
+        },
+        "api.DanglingFavorites": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "ID is the unique identifier of the identity",
+                    "type": "integer"
+                },
+                "missing": {
+                    "description": "Missing is the list of favorite stops that no longer exist",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.MissingFavorite"
+                    }
+                },
+                "provider": {
+                    "description": "Provider is the type of the identity provider",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ProviderType"
+                        }
+                    ]
+                },
+                "uuid": {
+                    "description": "UUID is the unique identifier of the identity, usually provided by the auth provider",
+                    "type": "string"
+                }
+            }
+        },
+        "api.Dataset": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "description": "Lines is the number of lines in the dataset",
+                    "type": "integer"
+                },
+                "loaded_at": {
+                    "description": "LoadedAt is the time the dataset was loaded",
+                    "type": "string"
+                },
+                "stops": {
+                    "description": "Stops is the number of stops in the dataset",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Version is the number of the last import, 0 if the dataset was never updated since it was generated",
+                    "type": "integer"
+                },
+                "versions": {
+                    "description": "Versions is the list of the latest imports, newest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DatasetVersion"
+                    }
+                }
+            }
+        },
+        "api.DatasetChange": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is the way the record changed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.DatasetChangeAction"
+                        }
+                    ]
+                },
+                "distance_m": {
+                    "description": "Distance is how far the stop moved in meters, only for stops that were not added or removed",
+                    "type": "number"
+                },
+                "entity": {
+                    "description": "Entity is the kind of record that changed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.DatasetEntity"
+                        }
+                    ]
+                },
+                "imported_at": {
+                    "description": "ImportedAt is the time the dataset version was imported",
+                    "type": "string"
+                },
+                "line": {
+                    "description": "Line is the name of the line that changed, only for lines",
+                    "type": "string"
+                },
+                "location": {
+                    "description": "Location is the location of the stop after the change, only for stops that were not removed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Coordinates"
+                        }
+                    ]
+                },
+                "name": {
+                    "description": "Name is the name of the stop after the change, only for stops that were not removed",
+                    "type": "string"
+                },
+                "previous_location": {
+                    "description": "PreviousLocation is the location of the stop before the change, only for stops that were not added",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Coordinates"
+                        }
+                    ]
+                },
+                "previous_name": {
+                    "description": "PreviousName is the name of the stop before the change, only for stops that were not added",
+                    "type": "string"
+                },
+                "stop_number": {
+                    "description": "StopNumber is the number of the stop that changed, only for stops",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Version is the dataset version that made the change",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.DatasetChangeAction": {
+            "type": "string",
+            "enum": [
+                "added",
+                "updated",
+                "renamed",
+                "moved",
+                "removed"
+            ],
+            "x-enum-varnames": [
+                "DatasetChangeAdded",
+                "DatasetChangeUpdated",
+                "DatasetChangeRenamed",
+                "DatasetChangeMoved",
+                "DatasetChangeRemoved"
+            ]
+        },
+        "api.DatasetChanges": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "description": "Changes is the list of changes, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DatasetChange"
+                    }
+                },
+                "version": {
+                    "description": "Version is the number of the last import",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.DatasetEntity": {
+            "type": "string",
+            "enum": [
+                "stop",
+                "line"
+            ],
+            "x-enum-varnames": [
+                "DatasetEntityStop",
+                "DatasetEntityLine"
+            ]
+        },
+        "api.DatasetVersion": {
+            "type": "object",
+            "properties": {
+                "imported_at": {
+                    "description": "ImportedAt is the time the dataset was imported",
+                    "type": "string"
+                },
+                "lines_added": {
+                    "description": "LinesAdded is the number of new lines",
+                    "type": "integer"
+                },
+                "lines_removed": {
+                    "description": "LinesRemoved is the number of lines that no longer exist",
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Source is the URL the dataset was downloaded from",
+                    "type": "string"
+                },
+                "stops_added": {
+                    "description": "StopsAdded is the number of new stops",
+                    "type": "integer"
+                },
+                "stops_removed": {
+                    "description": "StopsRemoved is the number of stops that no longer exist",
+                    "type": "integer"
+                },
+                "stops_updated": {
+                    "description": "StopsUpdated is the number of stops that were renamed, moved or whose lines changed",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Version is the sequential number of the import",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Departure": {
+            "type": "object",
+            "properties": {
+                "leave_in": {
+                    "description": "LeaveIn is the time in minutes left before walking to the stop to catch the bus",
+                    "type": "integer"
+                },
+                "line": {
+                    "description": "Line is the line of the bus",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Line"
+                        }
+                    ]
+                },
+                "route": {
+                    "description": "Route is the route of the bus",
+                    "type": "string"
+                },
+                "stop": {
+                    "description": "Stop is the stop where the bus can be taken, with the walking distance from the location",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.NearbyStop"
+                        }
+                    ]
+                },
+                "time": {
+                    "description": "Time is the time in minutes until the bus arrives at the stop",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.FavoriteLine": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "ID is the unique identifier of the line",
+                    "type": "integer"
+                },
+                "missing": {
+                    "description": "Missing tells that the line no longer exists in the lines dataset",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "Name is the name of the line provided by the bus company",
+                    "type": "string"
+                }
+            }
+        },
+        "api.FavoriteRoute": {
+            "type": "object",
+            "properties": {
+                "destination": {
+                    "description": "Destination is the stop where the trip ends",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Stop"
+                        }
+                    ]
+                },
+                "lines": {
+                    "description": "Lines are the lines that go from the origin to the destination without changing buses",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Line"
+                    }
+                },
+                "origin": {
+                    "description": "Origin is the stop where the trip starts",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Stop"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.FavoriteStop": {
+            "type": "object",
+            "properties": {
+                "alias": {
+                    "description": "Alias is the name given by the user to the stop, such as Home or Work",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the stop",
+                    "type": "integer"
+                },
+                "lines": {
+                    "description": "Lines are the names of the lines the user cares about at the stop, all of them if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "location": {
+                    "description": "Location is the geographical location of the stop",
+                    "type": "object",
+                    "properties": {
+                        "lat": {
+                            "description": "Lat is the latitude of the stop",
+                            "type": "number"
+                        },
+                        "lon": {
+                            "description": "Lon is the longitude of the stop",
+                            "type": "number"
+                        }
+                    }
+                },
+                "missing": {
+                    "description": "Missing tells that the stop no longer exists in the stops dataset, only for favorite stops",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "Name is the name of the stop",
+                    "type": "string"
+                },
+                "position": {
+                    "description": "Position is the place of the stop in the list of favorites of the user, starting at 0",
+                    "type": "integer"
+                },
+                "stop_id": {
+                    "description": "StopID is the number of the stop used internally by the bus company",
+                    "type": "integer"
+                },
+                "stop_number": {
+                    "description": "StopNumber is the number of the stop provided by the bus company",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.FavoriteStopUpdate": {
+            "type": "object",
+            "properties": {
+                "alias": {
+                    "description": "Alias is the new name given by the user to the stop, empty to remove it",
+                    "type": "string"
+                },
+                "lines": {
+                    "description": "Lines are the new names of the lines the user cares about at the stop, empty for all of them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.FavoriteStopsOrder": {
+            "type": "object",
+            "required": [
+                "stop_numbers"
+            ],
+            "properties": {
+                "stop_numbers": {
+                    "description": "StopNumbers are the numbers of every favorite stop of the user, in the new order",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "api.FavoritesIntegrity": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "description": "CheckedAt is the time the check was made",
+                    "type": "string"
+                },
+                "dangling": {
+                    "description": "Dangling is the list of identities with favorite stops that no longer exist",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DanglingFavorites"
+                    }
+                },
+                "identities": {
+                    "description": "Identities is the number of identities checked",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Identity": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "description": "AccountID is the unique identifier of the account that groups the linked identities",
+                    "type": "integer"
+                },
+                "favorite_lines": {
+                    "description": "FavoriteLines is a list of the user's favorite lines, in the order they were added",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.FavoriteLine"
+                    }
+                },
+                "favorite_routes": {
+                    "description": "FavoriteRoutes is a list of the user's favorite trips between two stops, in the order they were added",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.FavoriteRoute"
+                    }
+                },
+                "favorite_stops": {
+                    "description": "FavoriteStops is a list of the user's favorite stops, in the order chosen by the user",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.FavoriteStop"
+                    }
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the identity",
+                    "type": "integer"
+                },
+                "linked_identities": {
+                    "description": "LinkedIdentities is a list of the other identities of the same account",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.LinkedIdentity"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata is a JSON object that holds additional information about the identity, shared by its account",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "provider": {
+                    "description": "Provider is the type of the identity provider",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ProviderType"
+                        }
+                    ]
+                },
+                "uuid": {
+                    "description": "UUID is the unique identifier of the identity, usually provided by the auth provider",
+                    "type": "string"
+                }
+            }
+        },
+        "api.IdentityAction": {
+            "type": "string",
+            "enum": [
+                "created",
+                "favorite_added",
+                "favorite_removed",
+                "favorite_updated",
+                "favorites_reordered",
+                "favorite_line_added",
+                "favorite_line_removed",
+                "favorite_route_added",
+                "favorite_route_removed",
+                "metadata_updated",
+                "link_code_created",
+                "linked",
+                "exported"
+            ],
+            "x-enum-varnames": [
+                "IdentityActionCreated",
+                "IdentityActionFavoriteAdded",
+                "IdentityActionFavoriteRemoved",
+                "IdentityActionFavoriteUpdated",
+                "IdentityActionFavoritesReordered",
+                "IdentityActionFavoriteLineAdded",
+                "IdentityActionFavoriteLineRemoved",
+                "IdentityActionFavoriteRouteAdded",
+                "IdentityActionFavoriteRouteRemoved",
+                "IdentityActionMetadataUpdated",
+                "IdentityActionLinkCodeCreated",
+                "IdentityActionLinked",
+                "IdentityActionExported"
+            ]
+        },
+        "api.IdentityEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is what was done",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.IdentityAction"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "description": "CreatedAt is when the action was done",
+                    "type": "string"
+                },
+                "detail": {
+                    "description": "Detail tells what the action was applied to, such as the stop number of a favorite",
+                    "type": "string"
+                }
+            }
+        },
+        "api.IdentityExport": {
+            "type": "object",
+            "properties": {
+                "exported_at": {
+                    "description": "ExportedAt is when the export was made",
+                    "type": "string"
+                },
+                "history": {
+                    "description": "History is the list of actions done on the identity, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.IdentityEvent"
+                    }
+                },
+                "identity": {
+                    "description": "Identity is the identity along with its favorite stops, metadata and linked identities",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Identity"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.Itinerary": {
+            "type": "object",
+            "properties": {
+                "arrival": {
+                    "description": "Arrival is the estimated time the destination is reached",
+                    "type": "string"
+                },
+                "departure": {
+                    "description": "Departure is the time the trip starts",
+                    "type": "string"
+                },
+                "duration": {
+                    "description": "Duration is the estimated duration of the trip in minutes",
+                    "type": "integer"
+                },
+                "legs": {
+                    "description": "Legs is the list of parts of the trip in order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Leg"
+                    }
+                },
+                "transfer_stops": {
+                    "description": "TransferStops is the list of stops where the rider changes buses",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Stop"
+                    }
+                },
+                "transfers": {
+                    "description": "Transfers is the number of times the rider changes buses",
+                    "type": "integer"
+                },
+                "walking_distance_m": {
+                    "description": "WalkingDistance is the total distance walked in meters",
+                    "type": "number"
+                }
+            }
+        },
+        "api.Leg": {
+            "type": "object",
+            "properties": {
+                "arrival": {
+                    "description": "Arrival is the estimated time the leg ends",
+                    "type": "string"
+                },
+                "departure": {
+                    "description": "Departure is the estimated time the leg starts",
+                    "type": "string"
+                },
+                "distance_m": {
+                    "description": "Distance is the distance covered by the leg in meters",
+                    "type": "number"
+                },
+                "duration": {
+                    "description": "Duration is the estimated duration of the leg in minutes",
+                    "type": "integer"
+                },
+                "from": {
+                    "description": "From is where the leg starts",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Place"
+                        }
+                    ]
+                },
+                "line": {
+                    "description": "Line is the bus line to take, only for bus legs",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Line"
+                        }
+                    ]
+                },
+                "mode": {
+                    "description": "Mode is the way of moving along the leg",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.LegMode"
+                        }
+                    ]
+                },
+                "stops": {
+                    "description": "Stops is the list of stops the bus goes through, including where it is boarded and left, only for bus legs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Stop"
+                    }
+                },
+                "to": {
+                    "description": "To is where the leg ends",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Place"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.LegMode": {
+            "type": "string",
+            "enum": [
+                "walk",
+                "bus"
+            ],
+            "x-enum-varnames": [
+                "LegModeWalk",
+                "LegModeBus"
+            ]
+        },
+        "api.Line": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "ID is the unique identifier of the line",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name is the name of the line provided by the bus company",
+                    "type": "string"
+                }
+            }
+        },
+        "api.LinkCode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is the value to send from the identity to link",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the code stops being valid",
+                    "type": "string"
+                }
+            }
+        },
+        "api.LinkRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "description": "Code is the link code requested from the other identity",
+                    "type": "string"
+                }
+            }
+        },
+        "api.LinkedIdentity": {
+            "type": "object",
+            "properties": {
+                "provider": {
+                    "description": "Provider is the type of the identity provider",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ProviderType"
+                        }
+                    ]
+                },
+                "uuid": {
+                    "description": "UUID is the unique identifier of the identity, usually provided by the auth provider",
+                    "type": "string"
+                }
+            }
+        },
+        "api.MissingFavorite": {
+            "type": "object",
+            "properties": {
+                "previous_location": {
+                    "description": "PreviousLocation is the location the stop had before it was removed, if the removal was recorded",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Coordinates"
+                        }
+                    ]
+                },
+                "previous_name": {
+                    "description": "PreviousName is the name the stop had before it was removed, if the removal was recorded",
+                    "type": "string"
+                },
+                "removed_in_version": {
+                    "description": "RemovedInVersion is the dataset version that removed the stop, if the removal was recorded",
+                    "type": "integer"
+                },
+                "replacement": {
+                    "description": "Replacement is the stop suggested instead, either the nearest one to where the stop was or the one with the closest name",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Stop"
+                        }
+                    ]
+                },
+                "replacement_distance_m": {
+                    "description": "ReplacementDistance is the distance in meters between the missing stop and the replacement, only when found by location",
+                    "type": "number"
+                },
+                "stop_number": {
+                    "description": "StopNumber is the number of the missing stop",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.NearbyDepartures": {
+            "type": "object",
+            "properties": {
+                "departures": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Departure"
+                    }
+                },
+                "origin": {
+                    "type": "object",
+                    "properties": {
+                        "lat": {
+                            "type": "number"
+                        },
+                        "lon": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "radius": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.NearbyStop": {
+            "type": "object",
+            "properties": {
+                "bearing": {
+                    "description": "Bearing is the direction in degrees clockwise from north to walk from the location to the stop",
+                    "type": "number"
+                },
+                "compass": {
+                    "description": "Compass is the compass point closest to the bearing, such as N or SW",
+                    "type": "string"
+                },
+                "distance_m": {
+                    "description": "Distance is the distance in meters from the location to the stop",
+                    "type": "number"
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the stop",
+                    "type": "integer"
+                },
+                "location": {
+                    "description": "Location is the geographical location of the stop",
+                    "type": "object",
+                    "properties": {
+                        "lat": {
+                            "description": "Lat is the latitude of the stop",
+                            "type": "number"
+                        },
+                        "lon": {
+                            "description": "Lon is the longitude of the stop",
+                            "type": "number"
+                        }
+                    }
+                },
+                "missing": {
+                    "description": "Missing tells that the stop no longer exists in the stops dataset, only for favorite stops",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "Name is the name of the stop",
+                    "type": "string"
+                },
+                "stop_id": {
+                    "description": "StopID is the number of the stop used internally by the bus company",
+                    "type": "integer"
+                },
+                "stop_number": {
+                    "description": "StopNumber is the number of the stop provided by the bus company",
+                    "type": "integer"
+                },
+                "walking_minutes": {
+                    "description": "WalkingMinutes is the estimated time in minutes to walk from the location to the stop",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.NearbyStops": {
+            "type": "object",
+            "properties": {
+                "image": {
+                    "type": "string"
+                },
+                "origin": {
+                    "type": "object",
+                    "properties": {
+                        "lat": {
+                            "type": "number"
+                        },
+                        "lon": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "radius": {
+                    "type": "number"
+                },
+                "stops": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.NearbyStop"
+                    }
+                }
+            }
+        },
+        "api.Place": {
+            "type": "object",
+            "properties": {
+                "location": {
+                    "description": "Location is the geographical location of the place",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Coordinates"
                         }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.Identity"
+                    ]
+                },
+                "stop": {
+                    "description": "Stop is the stop at the place, empty for the origin and destination of the trip",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Stop"
                         }
-                    }
+                    ]
                 }
             }
         },
-        "/health": {
-            "get": {
-                "description": "Health endpoint",
-                "produces": [
-                    "text/plain"
-                ],
-                "tags": [
-                    "Health"
-                ],
-                "summary": "Health endpoint",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "string"
+        "api.Plan": {
+            "type": "object",
+            "properties": {
+                "depart_at": {
+                    "description": "DepartAt is the time the trip starts",
+                    "type": "string"
+                },
+                "from": {
+                    "description": "From is the origin of the trip",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Coordinates"
                         }
+                    ]
+                },
+                "itineraries": {
+                    "description": "Itineraries is the list of itineraries found, from fewer to more transfers",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Itinerary"
                     }
+                },
+                "to": {
+                    "description": "To is the destination of the trip",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Coordinates"
+                        }
+                    ]
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.Identity": {
+        },
+        "api.Polygon": {
             "type": "object",
             "properties": {
-                "favorite_stops": {
-                    "description": "FavoriteStops is a list of the user's favorite stops",
+                "coordinates": {
+                    "description": "Coordinates is the list of closed rings of the polygon, each one a list of [lon, lat] positions",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/api.Stop"
+                        "type": "array",
+                        "items": {
+                            "type": "array",
+                            "items": {
+                                "type": "number"
+                            }
+                        }
                     }
                 },
-                "id": {
-                    "description": "ID is the unique identifier of the identity",
+                "type": {
+                    "description": "Type is always Polygon",
+                    "type": "string"
+                }
+            }
+        },
+        "api.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is the stable, machine-readable kind of error",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ProblemCode"
+                        }
+                    ]
+                },
+                "detail": {
+                    "description": "Detail is a human-readable explanation of this occurrence of the error",
+                    "type": "string"
+                },
+                "instance": {
+                    "description": "Instance is the path of the request that failed",
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "RequestID identifies the request in the server logs",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is the HTTP status code of the response",
                     "type": "integer"
                 },
-                "metadata": {
-                    "description": "Metadata is a genric string that holds additional information about the identity",
+                "title": {
+                    "description": "Title is a short summary of the kind of error",
                     "type": "string"
                 },
-                "provider": {
-                    "description": "Provider is the type of the identity provider",
+                "type": {
+                    "description": "Type is a URI that identifies the kind of error",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ProblemCode": {
+            "type": "string",
+            "enum": [
+                "internal_error",
+                "invalid_parameter",
+                "invalid_coordinates",
+                "invalid_stop_number",
+                "invalid_body",
+                "unknown_provider",
+                "invalid_uuid",
+                "invalid_metadata",
+                "metadata_too_large",
+                "metadata_schema_violation",
+                "stop_not_found",
+                "line_not_found",
+                "user_not_found",
+                "user_exists",
+                "favorite_exists",
+                "favorite_not_found",
+                "invalid_link_code",
+                "already_linked",
+                "invalid_favorite_order",
+                "invalid_favorite_route",
+                "line_not_serving_stop",
+                "upstream_unavailable",
+                "dataset_unavailable",
+                "invalid_dataset",
+                "forbidden",
+                "insufficient_scope",
+                "invalid_scope",
+                "provider_not_allowed",
+                "token_not_found",
+                "rate_limited"
+            ],
+            "x-enum-varnames": [
+                "ProblemInternalError",
+                "ProblemInvalidParameter",
+                "ProblemInvalidCoordinates",
+                "ProblemInvalidStopNumber",
+                "ProblemInvalidBody",
+                "ProblemUnknownProvider",
+                "ProblemInvalidUUID",
+                "ProblemInvalidMetadata",
+                "ProblemMetadataTooLarge",
+                "ProblemMetadataSchemaViolation",
+                "ProblemStopNotFound",
+                "ProblemLineNotFound",
+                "ProblemUserNotFound",
+                "ProblemUserExists",
+                "ProblemFavoriteExists",
+                "ProblemFavoriteNotFound",
+                "ProblemInvalidLinkCode",
+                "ProblemAlreadyLinked",
+                "ProblemInvalidFavoriteOrder",
+                "ProblemInvalidFavoriteRoute",
+                "ProblemLineNotServingStop",
+                "ProblemUpstreamUnavailable",
+                "ProblemDatasetUnavailable",
+                "ProblemInvalidDataset",
+                "ProblemForbidden",
+                "ProblemInsufficientScope",
+                "ProblemInvalidScope",
+                "ProblemProviderNotAllowed",
+                "ProblemTokenNotFound",
+                "ProblemRateLimited"
+            ]
+        },
+        "api.Provider": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Name is the display name of the provider",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is the value used as provider in the user endpoints",
                     "allOf": [
                         {
                             "$ref": "#/definitions/api.ProviderType"
                         }
                     ]
                 },
-                "uuid": {
-                    "description": "UUID is the unique identifier of the identity, usually provided by the auth provider",
+                "uuid_format": {
+                    "description": "UUIDFormat describes the UUIDs accepted for the provider",
+                    "type": "string"
+                },
+                "uuid_pattern": {
+                    "description": "UUIDPattern is the regular expression the UUIDs must match",
                     "type": "string"
                 }
             }
         },
-        "api.Line": {
+        "api.ProviderType": {
+            "type": "string",
+            "enum": [
+                "telegram",
+                "discord",
+                "matrix",
+                "web",
+                "whatsapp"
+            ],
+            "x-enum-varnames": [
+                "ProviderTypeTelegram",
+                "ProviderTypeDiscord",
+                "ProviderTypeMatrix",
+                "ProviderTypeWeb",
+                "ProviderTypeWhatsApp"
+            ]
+        },
+        "api.Reachability": {
             "type": "object",
             "properties": {
-                "id": {
-                    "description": "ID is the unique identifier of the line",
+                "hull": {
+                    "description": "Hull is the convex polygon enclosing the origin and every reachable stop, only when requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Polygon"
+                        }
+                    ]
+                },
+                "minutes": {
+                    "description": "Minutes is the time budget in minutes",
                     "type": "integer"
                 },
-                "name": {
-                    "description": "Name is the name of the line provided by the bus company",
-                    "type": "string"
+                "origin": {
+                    "description": "Origin is the stop the trips start from",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.Stop"
+                        }
+                    ]
+                },
+                "stops": {
+                    "description": "Stops is the list of reachable stops, sorted by travel time",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ReachableStop"
+                    }
                 }
             }
         },
-        "api.NearbyStops": {
+        "api.ReachableStop": {
             "type": "object",
             "properties": {
-                "image": {
-                    "type": "string"
+                "id": {
+                    "description": "ID is the unique identifier of the stop",
+                    "type": "integer"
                 },
-                "origin": {
+                "location": {
+                    "description": "Location is the geographical location of the stop",
                     "type": "object",
                     "properties": {
                         "lat": {
+                            "description": "Lat is the latitude of the stop",
                             "type": "number"
                         },
                         "lon": {
+                            "description": "Lon is the longitude of the stop",
                             "type": "number"
                         }
                     }
                 },
-                "radius": {
-                    "type": "number"
+                "minutes": {
+                    "description": "Minutes is the estimated travel time in minutes from the origin stop",
+                    "type": "integer"
                 },
-                "stops": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/api.Stop"
-                    }
+                "missing": {
+                    "description": "Missing tells that the stop no longer exists in the stops dataset, only for favorite stops",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "Name is the name of the stop",
+                    "type": "string"
+                },
+                "stop_id": {
+                    "description": "StopID is the number of the stop used internally by the bus company",
+                    "type": "integer"
+                },
+                "stop_number": {
+                    "description": "StopNumber is the number of the stop provided by the bus company",
+                    "type": "integer"
+                },
+                "transfers": {
+                    "description": "Transfers is the number of times the rider changes buses to get to the stop",
+                    "type": "integer"
                 }
             }
         },
-        "api.ProviderType": {
-            "type": "string",
-            "enum": [
-                "telegram"
-            ],
-            "x-enum-varnames": [
-                "ProviderTypeTelegram"
-            ]
-        },
         "api.Schedule": {
             "type": "object",
             "properties": {
@@ -570,6 +3636,21 @@ const docTemplate = `{
                 }
             }
         },
+        "api.Scope": {
+            "type": "string",
+            "enum": [
+                "stops:read",
+                "schedules:read",
+                "users:write",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "ScopeStopsRead",
+                "ScopeSchedulesRead",
+                "ScopeUsersWrite",
+                "ScopeAdmin"
+            ]
+        },
         "api.Stop": {
             "type": "object",
             "properties": {
@@ -591,6 +3672,10 @@ const docTemplate = `{
                         }
                     }
                 },
+                "missing": {
+                    "description": "Missing tells that the stop no longer exists in the stops dataset, only for favorite stops",
+                    "type": "boolean"
+                },
                 "name": {
                     "description": "Name is the name of the stop",
                     "type": "string"
@@ -624,6 +3709,76 @@ const docTemplate = `{
                     ]
                 }
             }
+        },
+        "api.Token": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "CreatedAt is when the token was created",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the token stops being valid, if it ever does",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is the unique identifier of the token",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name identifies the client using the token, such as telegram-bot or dashboard",
+                    "type": "string"
+                },
+                "providers": {
+                    "description": "Providers are the identity providers whose users the token can access, all of them if empty",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ProviderType"
+                    }
+                },
+                "revoked_at": {
+                    "description": "RevokedAt is when the token was revoked, if it was",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes are the permissions granted to the token",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Scope"
+                    }
+                }
+            }
+        },
+        "api.TokenRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expires_at": {
+                    "description": "ExpiresAt is when the token stops being valid, never if empty",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name identifies the client that will use the token",
+                    "type": "string"
+                },
+                "providers": {
+                    "description": "Providers are the identity providers whose users the token can access, all of them if empty",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ProviderType"
+                    }
+                },
+                "scopes": {
+                    "description": "Scopes are the permissions granted to the token",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Scope"
+                    }
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -648,7 +3803,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "Vigo Bus Core API",
-	Description:      "This is the API for the Vigo Bus Core project.",
+	Description:      "This is the API for the Vigo Bus Core project. Errors are returned as RFC 7807 application/problem+json documents with a stable code and the request ID, also sent in the X-Request-ID header.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -4,11 +4,14 @@ go 1.23.1
 
 require (
 	github.com/gin-gonic/gin v1.10.0
+	github.com/lib/pq v1.12.3
 	github.com/mattn/go-sqlite3 v1.14.23
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/swaggo/files v1.0.1
 	github.com/swaggo/gin-swagger v1.6.0
 	github.com/swaggo/swag v1.16.3
 	golang.org/x/net v0.29.0
+	golang.org/x/text v0.18.0
 	golang.org/x/time v0.6.0
 )
 
@@ -42,7 +45,6 @@ require (
 	golang.org/x/arch v0.10.0 // indirect
 	golang.org/x/crypto v0.27.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
-	golang.org/x/text v0.18.0 // indirect
 	golang.org/x/tools v0.25.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
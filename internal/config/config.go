@@ -12,20 +12,80 @@ var (
 	Port             string
 	Token            string
 	StopsDBPath      string
+	StopsDBWatch     int
 	IdentityDBPath   string
+	IdentityDBDriver string
+	IdentityDBURL    string
+	AutoMigrate      bool
+	LinkCodeTTL      int
+	UserRetention    int
+	Metadata         struct {
+		SchemaPath string
+		MaxBytes   int
+	}
 	GoogleMapsAPIKey string
 	RateLimiter      struct {
 		Limit int
 		Burst int
 	}
+	Dataset struct {
+		URL            string
+		UpdateInterval int
+		MoveThreshold  float64
+	}
+	Planner struct {
+		BusSpeed        float64
+		Dwell           int
+		Wait            int
+		TransferPenalty int
+	}
 )
 
 func Init() {
 	// Define command-line flags
 	flag.StringVar(&Port, "port", getEnv("PORT", "8080"), "Port to run the server on")
-	flag.StringVar(&Token, "token", getEnv("TOKEN", "your-secret-token"), "Authentication token")
+	flag.StringVar(&Token, "token", getEnv("TOKEN", "your-secret-token"), "Root authentication token, granting every scope. Empty to only accept API tokens")
 	flag.StringVar(&StopsDBPath, "stops-db-path", getEnv("STOPS_DB_PATH", "stops.db"), "Path to the stops database")
+	stopsDBWatch, err := strconv.Atoi(getEnv("STOPS_DB_WATCH_INTERVAL", "30"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse STOPS_DB_WATCH_INTERVAL: %v", err))
+	}
+	flag.IntVar(&StopsDBWatch, "stops-db-watch-interval", stopsDBWatch, "Seconds between checks for a replaced stops database, 0 to disable")
+	flag.StringVar(&Dataset.URL, "dataset-url", getEnv("DATASET_URL", "https://datos.vigo.org/data/transporte/paradas.json"), "URL of the paradas.json stops dataset")
+	datasetUpdateInterval, err := strconv.Atoi(getEnv("DATASET_UPDATE_INTERVAL", "0"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse DATASET_UPDATE_INTERVAL: %v", err))
+	}
+	flag.IntVar(&Dataset.UpdateInterval, "dataset-update-interval", datasetUpdateInterval, "Seconds between updates of the stops database from the dataset, 0 to disable")
+	moveThreshold, err := strconv.ParseFloat(getEnv("DATASET_MOVE_THRESHOLD", "50"), 64)
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse DATASET_MOVE_THRESHOLD: %v", err))
+	}
+	flag.Float64Var(&Dataset.MoveThreshold, "dataset-move-threshold", moveThreshold, "Meters a stop has to move in a dataset update to be reported as moved")
 	flag.StringVar(&IdentityDBPath, "identity-db-path", getEnv("IDENTITY_DB_PATH", "identity.db"), "Path to the identity database")
+	flag.StringVar(&IdentityDBDriver, "identity-db-driver", getEnv("IDENTITY_DB_DRIVER", "sqlite"), "Identity database driver, sqlite or postgres")
+	flag.StringVar(&IdentityDBURL, "identity-db-url", getEnv("IDENTITY_DB_URL", ""), "URL of the identity database when using the postgres driver")
+	autoMigrate, err := strconv.ParseBool(getEnv("AUTO_MIGRATE", "true"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse AUTO_MIGRATE: %v", err))
+	}
+	flag.BoolVar(&AutoMigrate, "auto-migrate", autoMigrate, "Apply the pending identity database migrations on startup")
+	linkCodeTTL, err := strconv.Atoi(getEnv("LINK_CODE_TTL", "600"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse LINK_CODE_TTL: %v", err))
+	}
+	flag.IntVar(&LinkCodeTTL, "link-code-ttl", linkCodeTTL, "Seconds an account link code can be used")
+	userRetention, err := strconv.Atoi(getEnv("USER_RETENTION_DAYS", "30"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse USER_RETENTION_DAYS: %v", err))
+	}
+	flag.IntVar(&UserRetention, "user-retention-days", userRetention, "Days a deleted user is kept before being purged for good")
+	flag.StringVar(&Metadata.SchemaPath, "metadata-schema-path", getEnv("METADATA_SCHEMA_PATH", ""), "Path to a JSON Schema the user metadata must match, empty to accept any JSON object")
+	metadataMaxBytes, err := strconv.Atoi(getEnv("METADATA_MAX_BYTES", "16384"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse METADATA_MAX_BYTES: %v", err))
+	}
+	flag.IntVar(&Metadata.MaxBytes, "metadata-max-bytes", metadataMaxBytes, "Size limit in bytes of the metadata of a user")
 	flag.StringVar(&GoogleMapsAPIKey, "google-maps-api-key", getEnv("GOOGLE_MAPS_API_KEY", ""), "Google maps api key for generating images")
 	limit, err := strconv.Atoi(getEnv("RATE_LIMITER_LIMIT", "1"))
 	if err != nil {
@@ -37,6 +97,26 @@ func Init() {
 		log.Fatal(fmt.Errorf("failed to parse RATE_LIMITER_BURST: %v", err))
 	}
 	flag.IntVar(&RateLimiter.Burst, "rate-limiter-burst", burst, "Rate limiter burst")
+	busSpeed, err := strconv.ParseFloat(getEnv("PLANNER_BUS_SPEED", "18"), 64)
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse PLANNER_BUS_SPEED: %v", err))
+	}
+	flag.Float64Var(&Planner.BusSpeed, "planner-bus-speed", busSpeed, "Average bus speed in km/h used to estimate travel times")
+	dwell, err := strconv.Atoi(getEnv("PLANNER_DWELL", "20"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse PLANNER_DWELL: %v", err))
+	}
+	flag.IntVar(&Planner.Dwell, "planner-dwell", dwell, "Seconds a bus spends at each stop")
+	wait, err := strconv.Atoi(getEnv("PLANNER_WAIT", "300"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse PLANNER_WAIT: %v", err))
+	}
+	flag.IntVar(&Planner.Wait, "planner-wait", wait, "Average seconds spent waiting for a bus")
+	transferPenalty, err := strconv.Atoi(getEnv("PLANNER_TRANSFER_PENALTY", "120"))
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse PLANNER_TRANSFER_PENALTY: %v", err))
+	}
+	flag.IntVar(&Planner.TransferPenalty, "planner-transfer-penalty", transferPenalty, "Extra seconds added every time the rider changes buses")
 
 	// Parse command-line flags
 	flag.Parse()
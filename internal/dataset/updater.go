@@ -0,0 +1,160 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+)
+
+// fetchTimeout is how long the download of the dataset may take
+const fetchTimeout = time.Minute
+
+// Updater keeps the stops database in sync with the stops dataset published as open data
+type Updater struct {
+	// URL is where the dataset is downloaded from
+	URL string
+
+	// Bus is the stops database that gets updated
+	Bus *sqlite.ReloadableBusConnector
+
+	// MoveThreshold is the distance in meters a stop has to move to be reported as moved
+	MoveThreshold float64
+
+	// Client is the HTTP client used to download the dataset
+	Client *http.Client
+}
+
+// NewUpdater creates an Updater that downloads the dataset from the given URL
+func NewUpdater(url string, moveThreshold float64, bus *sqlite.ReloadableBusConnector) *Updater {
+	return &Updater{
+		URL:           url,
+		Bus:           bus,
+		MoveThreshold: moveThreshold,
+		Client:        &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// Run updates the stops database right away and then every interval, until the context is done
+func (u *Updater) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := u.Update(); err != nil {
+			log.Printf("Failed to update stops dataset: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update downloads the dataset and applies its differences to the stops database, returning
+// whether anything changed
+func (u *Updater) Update() (bool, error) {
+	stops, err := u.fetch()
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := u.Bus.Update(func(c *sqlite.BusConnector) (bool, error) {
+		return c.ImportDataset(u.URL, stops, u.MoveThreshold)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if changed {
+		log.Printf("Updated stops dataset from %s", u.URL)
+	}
+	return changed, nil
+}
+
+// paradasStop is a stop in the paradas.json dataset
+type paradasStop struct {
+	ID     flexibleNumber `json:"id"`
+	StopID flexibleNumber `json:"stop_id"`
+	Nombre string         `json:"nombre"`
+	Lat    flexibleNumber `json:"lat"`
+	Lon    flexibleNumber `json:"lon"`
+	Lineas string         `json:"lineas"`
+}
+
+// flexibleNumber is a number that the dataset may publish either as a JSON number or as a string
+type flexibleNumber string
+
+// UnmarshalJSON accepts both numbers and strings
+func (n *flexibleNumber) UnmarshalJSON(data []byte) error {
+	*n = flexibleNumber(strings.Trim(string(bytes.TrimSpace(data)), `"`))
+	return nil
+}
+
+// fetch downloads and parses the dataset
+func (u *Updater) fetch() ([]storage.DatasetStop, error) {
+	resp, err := u.Client.Get(u.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download dataset: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download dataset: unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %v", err)
+	}
+
+	return parse(body)
+}
+
+// parse reads the stops of the paradas.json dataset, where the lines are a comma separated list
+func parse(data []byte) ([]storage.DatasetStop, error) {
+	var paradas []paradasStop
+	if err := json.Unmarshal(data, &paradas); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset: %v", err)
+	}
+
+	stops := make([]storage.DatasetStop, 0, len(paradas))
+	for _, parada := range paradas {
+		var stop storage.DatasetStop
+		var err error
+		if stop.StopNumber, err = strconv.Atoi(string(parada.ID)); err != nil {
+			return nil, fmt.Errorf("invalid stop number %q: %v", parada.ID, err)
+		}
+		if stop.StopID, err = strconv.Atoi(string(parada.StopID)); err != nil {
+			return nil, fmt.Errorf("invalid stop id %q of stop %d: %v", parada.StopID, stop.StopNumber, err)
+		}
+		if stop.Location.Lat, err = strconv.ParseFloat(string(parada.Lat), 64); err != nil {
+			return nil, fmt.Errorf("invalid latitude %q of stop %d: %v", parada.Lat, stop.StopNumber, err)
+		}
+		if stop.Location.Lon, err = strconv.ParseFloat(string(parada.Lon), 64); err != nil {
+			return nil, fmt.Errorf("invalid longitude %q of stop %d: %v", parada.Lon, stop.StopNumber, err)
+		}
+		stop.Name = parada.Nombre
+
+		for _, line := range strings.Split(parada.Lineas, ",") {
+			if line = strings.TrimSpace(line); line != "" {
+				stop.Lines = append(stop.Lines, line)
+			}
+		}
+
+		stops = append(stops, stop)
+	}
+
+	return stops, nil
+}
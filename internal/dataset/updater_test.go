@@ -0,0 +1,235 @@
+package dataset
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// testMoveThreshold is the distance in meters a stop has to move in the tests to be reported as moved
+const testMoveThreshold = 50
+
+// newTestUpdater creates an updater on a stops database with the stops of the first fixture, which
+// downloads the fixture set in the returned variable
+func newTestUpdater(t *testing.T) (*Updater, *string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stops.db")
+	c, err := sqlite.NewBusConnector(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ImportDataset("paradas_v1.json", readFixture(t, "paradas_v1.json"), testMoveThreshold); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	bus, err := sqlite.NewReloadableBusConnector(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bus.Close() })
+
+	fixture := "paradas_v1.json"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", fixture))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewUpdater(server.URL, testMoveThreshold, bus), &fixture
+}
+
+// readFixture parses a dataset of the testdata directory
+func readFixture(t *testing.T, name string) []storage.DatasetStop {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stops, err := parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stops
+}
+
+// changeSummary is the part of a dataset change checked by the tests
+type changeSummary struct {
+	entity api.DatasetEntity
+	action api.DatasetChangeAction
+	stop   int
+	line   string
+}
+
+func summarize(changes []api.DatasetChange) []changeSummary {
+	summaries := make([]changeSummary, 0, len(changes))
+	for _, change := range changes {
+		summaries = append(summaries, changeSummary{change.Entity, change.Action, change.StopNumber, change.Line})
+	}
+	return summaries
+}
+
+func TestUpdate(t *testing.T) {
+	u, fixture := newTestUpdater(t)
+
+	// The same dataset doesn't record a new version
+	changed, err := u.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("expected no changes when importing the same dataset")
+	}
+
+	*fixture = "paradas_v2.json"
+	changed, err = u.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected the new dataset to change the stops")
+	}
+
+	dataset, err := u.Bus.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataset.Stops != 4 || dataset.Lines != 4 || dataset.Version != 2 || len(dataset.Versions) != 2 {
+		t.Fatalf("expected 4 stops and 4 lines at version 2, got %+v", dataset)
+	}
+
+	want := []api.DatasetVersion{
+		{Version: 2, Source: u.URL, StopsAdded: 1, StopsUpdated: 3, StopsRemoved: 1, LinesAdded: 1},
+		{Version: 1, Source: "paradas_v1.json", StopsAdded: 4, LinesAdded: 3},
+	}
+	for i, version := range dataset.Versions {
+		if version.ImportedAt.IsZero() {
+			t.Errorf("expected version %d to have an import time", version.Version)
+		}
+		version.ImportedAt = want[i].ImportedAt
+		if version != want[i] {
+			t.Errorf("expected version %+v, got %+v", want[i], version)
+		}
+	}
+
+	// Stop 1040 is no longer in the dataset and line 4C still serves stop 1010
+	changes, err := u.Bus.DatasetChanges(1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var added []changeSummary
+	for _, change := range summarize(changes.Changes) {
+		if change.action == api.DatasetChangeAdded || change.action == api.DatasetChangeRemoved {
+			added = append(added, change)
+		}
+	}
+	wantChanges := []changeSummary{
+		{api.DatasetEntityLine, api.DatasetChangeAdded, 0, "10"},
+		{api.DatasetEntityStop, api.DatasetChangeAdded, 1050, ""},
+		{api.DatasetEntityStop, api.DatasetChangeRemoved, 1040, ""},
+	}
+	if len(added) != len(wantChanges) {
+		t.Fatalf("expected changes %+v, got %+v", wantChanges, added)
+	}
+	for i := range added {
+		if added[i] != wantChanges[i] {
+			t.Errorf("expected change %+v, got %+v", wantChanges[i], added[i])
+		}
+	}
+}
+
+func TestUpdateInvalidDataset(t *testing.T) {
+	u, fixture := newTestUpdater(t)
+
+	*fixture = "missing.json"
+	if _, err := u.Update(); err == nil {
+		t.Error("expected an error when the dataset can't be downloaded")
+	}
+
+	dataset, err := u.Bus.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataset.Version != 1 || dataset.Stops != 4 {
+		t.Errorf("expected the first version to be kept, got %+v", dataset)
+	}
+}
+
+func TestDatasetChanges(t *testing.T) {
+	u, fixture := newTestUpdater(t)
+	start := time.Now().Add(-time.Minute)
+
+	*fixture = "paradas_v2.json"
+	if _, err := u.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := u.Bus.DatasetChanges(1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Version != 2 {
+		t.Errorf("expected version 2, got %d", changes.Version)
+	}
+
+	// Stop 1010 moved a few meters, below the threshold, and 1030 about 220 meters
+	want := []changeSummary{
+		{api.DatasetEntityLine, api.DatasetChangeAdded, 0, "10"},
+		{api.DatasetEntityStop, api.DatasetChangeUpdated, 1010, ""},
+		{api.DatasetEntityStop, api.DatasetChangeRenamed, 1020, ""},
+		{api.DatasetEntityStop, api.DatasetChangeMoved, 1030, ""},
+		{api.DatasetEntityStop, api.DatasetChangeAdded, 1050, ""},
+		{api.DatasetEntityStop, api.DatasetChangeRemoved, 1040, ""},
+	}
+	got := summarize(changes.Changes)
+	if len(got) != len(want) {
+		t.Fatalf("expected changes %+v, got %+v", want, got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("expected change %+v, got %+v", want[i], got[i])
+		}
+		if changes.Changes[i].Version != 2 {
+			t.Errorf("expected change %+v to belong to version 2, got %d", got[i], changes.Changes[i].Version)
+		}
+	}
+
+	renamed := changes.Changes[2]
+	if renamed.PreviousName != "Praza de España" || renamed.Name != "Praza de España - Rotonda" {
+		t.Errorf("expected the stop to be renamed, got %q to %q", renamed.PreviousName, renamed.Name)
+	}
+	moved := changes.Changes[3]
+	if moved.Distance < 200 || moved.Distance > 250 || moved.PreviousLocation == nil || moved.PreviousLocation.Lat != 42.209 {
+		t.Errorf("expected the stop to move about 220 meters from its previous location, got %+v", moved)
+	}
+
+	tests := []struct {
+		name         string
+		sinceVersion int
+		sinceTime    time.Time
+		want         int
+	}{
+		{"every import", 0, time.Time{}, 13},
+		{"after the first import", 1, time.Time{}, 6},
+		{"after the latest import", 2, time.Time{}, 0},
+		{"after a time before the imports", 0, start, 13},
+		{"after a time later than the imports", 0, time.Now().Add(time.Minute), 0},
+	}
+	for _, test := range tests {
+		changes, err := u.Bus.DatasetChanges(test.sinceVersion, test.sinceTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes.Changes) != test.want {
+			t.Errorf("%s: expected %d changes, got %d", test.name, test.want, len(changes.Changes))
+		}
+	}
+}
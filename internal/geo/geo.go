@@ -0,0 +1,70 @@
+package geo
+
+import "math"
+
+// EarthRadius is the mean radius of the Earth in meters
+const EarthRadius = 6371000.0
+
+// WalkingSpeed is the average walking speed of a pedestrian in meters per second
+const WalkingSpeed = 1.3
+
+// compassPoints are the names of the 8 main directions, clockwise starting at north
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// Distance calculates the great-circle distance in meters between two points using the Haversine formula
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := toRadians(lat1)
+	lat2Rad := toRadians(lat2)
+	dlat := toRadians(lat2 - lat1)
+	dlon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return EarthRadius * c
+}
+
+// Bearing calculates the initial bearing in degrees clockwise from north to go from the first point to the second
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := toRadians(lat1)
+	lat2Rad := toRadians(lat2)
+	dlon := toRadians(lon2 - lon1)
+
+	y := math.Sin(dlon) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dlon)
+
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// CompassPoint returns the name of the closest of the 8 main compass directions to a bearing in degrees
+func CompassPoint(bearing float64) string {
+	index := int(math.Round(math.Mod(bearing+360, 360)/45)) % len(compassPoints)
+	return compassPoints[index]
+}
+
+// WalkingMinutes estimates the minutes needed to walk a distance in meters, rounded up
+func WalkingMinutes(distance float64) int {
+	return int(math.Ceil(distance / WalkingSpeed / 60))
+}
+
+// BoundingBox returns the latitude and longitude ranges that contain every point within radius meters of a location
+func BoundingBox(lat, lon, radius float64) (minLat, maxLat, minLon, maxLon float64) {
+	dlat := toDegrees(radius / EarthRadius)
+
+	// Near the poles the longitude range covers the whole circle
+	cosLat := math.Cos(toRadians(lat))
+	if cosLat < 1e-9 {
+		return lat - dlat, lat + dlat, -180, 180
+	}
+	dlon := toDegrees(radius / (EarthRadius * cosLat))
+
+	return lat - dlat, lat + dlat, lon - dlon, lon + dlon
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
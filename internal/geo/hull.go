@@ -0,0 +1,49 @@
+package geo
+
+import "sort"
+
+// Point is a location given as longitude and latitude, in the order used by GeoJSON
+type Point [2]float64
+
+// ConvexHull returns the smallest convex polygon containing every point, as a closed ring
+// in counterclockwise order. It returns nil when the points don't enclose any area
+func ConvexHull(points []Point) []Point {
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+		return sorted[i][1] < sorted[j][1]
+	})
+
+	// Andrew's monotone chain builds the lower and the upper halves of the hull
+	hull := make([]Point, 0, 2*len(sorted))
+	for _, p := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	// The last point is the first one again, closing the ring
+	if len(hull) < 4 {
+		return nil
+	}
+
+	return hull
+}
+
+// cross returns the z component of the cross product of the vectors oa and ob,
+// which is positive when o, a and b make a counterclockwise turn
+func cross(o, a, b Point) float64 {
+	return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+}
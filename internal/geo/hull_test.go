@@ -0,0 +1,55 @@
+package geo
+
+import "testing"
+
+func TestConvexHull(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   []Point
+	}{
+		{
+			name:   "square with a point inside",
+			points: []Point{{1, 1}, {0, 0}, {0.5, 0.5}, {1, 0}, {0, 1}},
+			want:   []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}},
+		},
+		{
+			name:   "points along the edges",
+			points: []Point{{0, 0}, {0.5, 0}, {1, 0}, {1, 1}, {0.5, 0.5}, {0, 1}, {0, 0.5}},
+			want:   []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}},
+		},
+		{
+			name:   "triangle with duplicate points",
+			points: []Point{{0, 0}, {2, 0}, {1, 2}, {2, 0}, {0, 0}, {1, 2}},
+			want:   []Point{{0, 0}, {2, 0}, {1, 2}, {0, 0}},
+		},
+		{
+			name:   "collinear points",
+			points: []Point{{0, 0}, {2, 2}, {1, 1}, {3, 3}},
+		},
+		{
+			name:   "duplicate points",
+			points: []Point{{1, 1}, {1, 1}, {1, 1}},
+		},
+		{
+			name:   "two points",
+			points: []Point{{0, 0}, {1, 1}},
+		},
+		{
+			name: "no points",
+		},
+	}
+	for _, test := range tests {
+		got := ConvexHull(test.points)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/eryalito/vigo-bus-core/internal/integrity"
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReloadStops godoc
+// @Summary Reload the stops database
+// @Description Load the stops database file again and switch to it without interrupting the requests in progress. The current dataset keeps being served if the new file is invalid
+// @Tags Admin
+// @Produce  json
+// @Success 200 {object} api.Dataset
+// @Failure 403 {object} api.Problem
+// @Failure 422 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Failure 501 {object} api.Problem
+// @Router /admin/stops/reload [post]
+func (h *Handler) ReloadStops(c *gin.Context) {
+	if h.Dataset == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotImplemented, api.ProblemDatasetUnavailable, "Stops dataset can't be reloaded"))
+		return
+	}
+
+	// The reason the file is invalid may reveal its path and contents, so it is only logged
+	err := h.Dataset.Reload()
+	if errors.Is(err, storage.ErrInvalidDataset) {
+		log.Printf("Request %s failed to reload the stops database: %v", middleware.RequestID(c), err)
+		c.Error(middleware.NewHTTPError(http.StatusUnprocessableEntity, api.ProblemInvalidDataset, "Stops database is invalid, the current dataset is still served"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	dataset, err := h.Dataset.Dataset()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dataset)
+}
+
+// CheckFavoriteStops godoc
+// @Summary Check the favorite stops of every user
+// @Description List the users with favorite stops that no longer exist in the stops dataset, suggesting for each of them the nearest stop to where it was or the one with the closest name
+// @Tags Admin
+// @Produce  json
+// @Success 200 {object} api.FavoritesIntegrity
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /admin/favorites/integrity [get]
+func (h *Handler) CheckFavoriteStops(c *gin.Context) {
+	report, err := integrity.CheckFavorites(h.Stops, h.Identities, h.Dataset)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
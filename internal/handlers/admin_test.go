@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// stubDataset is a dataset whose reloads fail with the given error
+type stubDataset struct {
+	reloadErr error
+}
+
+func (d *stubDataset) Dataset() (api.Dataset, error) {
+	return api.Dataset{Stops: 3, Lines: 2}, nil
+}
+
+func (d *stubDataset) DatasetChanges(sinceVersion int, sinceTime time.Time) (api.DatasetChanges, error) {
+	return api.DatasetChanges{}, nil
+}
+
+func (d *stubDataset) Reload() error {
+	return d.reloadErr
+}
+
+func TestReloadStops(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expectProblem(http.StatusNotImplemented, api.ProblemDatasetUnavailable, http.MethodPost, "/admin/stops/reload", "")
+
+	s.handler.Dataset = &stubDataset{}
+	var dataset api.Dataset
+	s.expect(http.StatusOK, http.MethodPost, "/admin/stops/reload", "", &dataset)
+	if dataset.Stops != 3 || dataset.Lines != 2 {
+		t.Errorf("expected the reloaded dataset, got %+v", dataset)
+	}
+}
+
+func TestReloadStopsErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   api.ProblemCode
+	}{
+		{
+			name:   "invalid file",
+			err:    fmt.Errorf("%w: database integrity check failed: row 3 missing from index", storage.ErrInvalidDataset),
+			status: http.StatusUnprocessableEntity,
+			code:   api.ProblemInvalidDataset,
+		},
+		{
+			name:   "missing file",
+			err:    errors.New("failed to stat stops database: stat /srv/secret/stops.db: no such file or directory"),
+			status: http.StatusInternalServerError,
+			code:   api.ProblemInternalError,
+		},
+	}
+	for _, test := range tests {
+		s.handler.Dataset = &stubDataset{reloadErr: test.err}
+
+		var problem api.Problem
+		s.expect(test.status, http.MethodPost, "/admin/stops/reload", "", &problem)
+		if problem.Code != test.code {
+			t.Errorf("%s: expected problem %s, got %s", test.name, test.code, problem.Code)
+		}
+		if strings.Contains(problem.Detail, "secret") || strings.Contains(problem.Detail, "index") {
+			t.Errorf("%s: expected the reason not to be sent to the client, got %q", test.name, problem.Detail)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/storage/memory"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+const (
+	// concurrentWorkers is how many clients change the same user at the same time
+	concurrentWorkers = 8
+
+	// stopsPerWorker is how many favorite stops every client adds and removes
+	stopsPerWorker = 6
+
+	// patchesPerWorker is how many metadata patches every client sends
+	patchesPerWorker = 10
+
+	// sharedStopNumber is a stop every client adds, so it must end up in the favorites once
+	sharedStopNumber = 999
+)
+
+func TestConcurrentUserChanges(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testConcurrentUserChanges(t, memory.NewIdentityStore())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		identities, err := sqlite.NewIdentityConnector(filepath.Join(t.TempDir(), "identity.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer identities.Close()
+
+		migrator, err := identities.Migrator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(); err != nil {
+			t.Fatal(err)
+		}
+
+		testConcurrentUserChanges(t, identities)
+	})
+}
+
+// testConcurrentUserChanges makes many clients add and remove favorite stops and patch the metadata
+// of the same user at the same time, and checks that the user ends up with the result of every change
+func testConcurrentUserChanges(t *testing.T, identities storage.IdentityRepository) {
+	bus := memory.NewBusStore()
+	bus.AddStop(api.Stop{StopNumber: sharedStopNumber})
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		for i := 0; i < stopsPerWorker; i++ {
+			bus.AddStop(api.Stop{StopNumber: workerStopNumber(worker, i)})
+		}
+	}
+
+	s := newTestServerWith(t, bus, identities)
+	s.createUser("telegram", "1")
+
+	// Every client keeps its even stops and removes its odd ones, and leaves its last patch in the metadata
+	expectedStops := []int{sharedStopNumber}
+	expectedMetadata := map[string]any{}
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		for i := 0; i < stopsPerWorker; i += 2 {
+			expectedStops = append(expectedStops, workerStopNumber(worker, i))
+		}
+		expectedMetadata[fmt.Sprintf("worker%d", worker)] = float64(patchesPerWorker - 1)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			expectStatus := func(method, path, body string) {
+				if w := s.request(method, path, body); w.Code != http.StatusOK {
+					t.Errorf("%s %s: expected status 200, got %d: %s", method, path, w.Code, w.Body.String())
+				}
+			}
+
+			for i := 0; i < max(stopsPerWorker, patchesPerWorker); i++ {
+				if i < stopsPerWorker {
+					path := fmt.Sprintf("/api/users/telegram/1/favorite_stops/%d", workerStopNumber(worker, i))
+					expectStatus(http.MethodPut, path, "")
+					expectStatus(http.MethodPut, fmt.Sprintf("/api/users/telegram/1/favorite_stops/%d", sharedStopNumber), "")
+					if i%2 == 1 {
+						expectStatus(http.MethodDelete, path, "")
+					}
+				}
+				if i < patchesPerWorker {
+					expectStatus(http.MethodPatch, "/api/users/telegram/1/metadata", fmt.Sprintf(`{"worker%d":%d}`, worker, i))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &user)
+
+	stops := favoriteStopNumbers(user)
+	slices.Sort(stops)
+	slices.Sort(expectedStops)
+	if !slices.Equal(stops, expectedStops) {
+		t.Fatalf("expected favorite stops %v, got %v", expectedStops, stops)
+	}
+	for i, favorite := range user.FavoriteStops {
+		if favorite.Position != i {
+			t.Fatalf("expected favorite stop %d at position %d, got %d", favorite.StopNumber, i, favorite.Position)
+		}
+	}
+
+	if !reflect.DeepEqual(user.Metadata, expectedMetadata) {
+		t.Fatalf("expected metadata %v, got %v", expectedMetadata, user.Metadata)
+	}
+}
+
+// workerStopNumber returns the number of the ith stop added by a client
+func workerStopNumber(worker, i int) int {
+	return 1000 + worker*stopsPerWorker + i
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDataset godoc
+// @Summary Describe the stops dataset
+// @Description Provide the size of the stops dataset currently served, its version and the latest imports from the open data dataset with how many stops and lines they changed
+// @Tags Bus
+// @Produce  json
+// @Success 200 {object} api.Dataset
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Failure 501 {object} api.Problem
+// @Router /api/dataset [get]
+func (h *Handler) GetDataset(c *gin.Context) {
+	if h.Dataset == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotImplemented, api.ProblemDatasetUnavailable, "Stops dataset information unavailable"))
+		return
+	}
+
+	dataset, err := h.Dataset.Dataset()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dataset)
+}
+
+// GetDatasetChanges godoc
+// @Summary List the changes to the stops dataset
+// @Description Provide the stops added, removed, renamed or moved and the lines added or removed by the imports of the open data dataset after a version or time, oldest first, so the users whose favorite stops changed can be warned
+// @Tags Bus
+// @Produce  json
+// @Param since query string false "Dataset version or RFC 3339 time after which to list the changes, default every change"
+// @Success 200 {object} api.DatasetChanges
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Failure 501 {object} api.Problem
+// @Router /api/dataset/changes [get]
+func (h *Handler) GetDatasetChanges(c *gin.Context) {
+	if h.Dataset == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotImplemented, api.ProblemDatasetUnavailable, "Stops dataset information unavailable"))
+		return
+	}
+
+	var sinceVersion int
+	var sinceTime time.Time
+	if since := c.Query("since"); since != "" {
+		var err error
+		if sinceVersion, err = strconv.Atoi(since); err != nil || sinceVersion < 0 {
+			sinceVersion = 0
+			if sinceTime, err = time.Parse(time.RFC3339, since); err != nil {
+				c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid since query parameter"))
+				return
+			}
+		}
+	}
+
+	changes, err := h.Dataset.DatasetChanges(sinceVersion, sinceTime)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
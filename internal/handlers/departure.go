@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxConcurrentScheduleRequests limits how many schedules are requested to Vitrasa at the same time
+const maxConcurrentScheduleRequests = 5
+
+// maxNearbyDeparturesStops is the highest number of stops whose schedules can be requested at once,
+// as every stop is a request to Vitrasa
+const maxNearbyDeparturesStops = 20
+
+// maxNearbyDeparturesRadius is the largest radius in meters to look for departures in
+const maxNearbyDeparturesRadius = 2000
+
+// GetNearbyDepartures godoc
+// @Summary Get the next buses leaving near a location
+// @Description Provide the next departures from the stops around a location, sorted by time. Only the closest stop is kept for each line and route, and buses that can't be reached walking in time are left out
+// @Tags Bus
+// @Produce  json
+// @Param lat query float64 true "Latitude"
+// @Param lon query float64 true "Longitude"
+// @Param radius query float64 true "Radius in meters, up to 2000"
+// @Param limit query int false "Limit of stops to check, default 10, up to 20"
+// @Success 200 {object} api.NearbyDepartures
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Failure 502 {object} api.Problem
+// @Router /api/departures/nearby [get]
+func (h *Handler) GetNearbyDepartures(c *gin.Context) {
+	location, ok := parseLocation(c)
+	if !ok {
+		return
+	}
+	lat, lon := location.Lat, location.Lon
+
+	radius, ok := parseRadius(c)
+	if !ok {
+		return
+	}
+	if radius > maxNearbyDeparturesRadius {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, fmt.Sprintf("Invalid radius, expected at most %d meters", maxNearbyDeparturesRadius)))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > maxNearbyDeparturesStops {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, fmt.Sprintf("Invalid limit, expected between 1 and %d", maxNearbyDeparturesStops)))
+		return
+	}
+
+	stops, err := h.Stops.FindStopsByLocation(lat, lon, radius, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	schedules, failed := h.fetchSchedules(stops)
+	if len(stops) > 0 && failed == len(stops) {
+		c.Error(middleware.NewHTTPError(http.StatusBadGateway, api.ProblemUpstreamUnavailable, "Failed to retrieve schedules"))
+		return
+	}
+
+	nearbyDepartures := api.NearbyDepartures{
+		Radius:     radius,
+		Departures: mergeDepartures(stops, schedules),
+	}
+	nearbyDepartures.Origin.Lat = lat
+	nearbyDepartures.Origin.Lon = lon
+
+	c.JSON(http.StatusOK, nearbyDepartures)
+}
+
+// fetchSchedules retrieves the schedules of every stop concurrently. The schedules are returned
+// in the same order as the stops, along with how many of the stops failed to be retrieved
+func (h *Handler) fetchSchedules(stops []api.NearbyStop) ([][]api.Schedule, int) {
+	schedules := make([][]api.Schedule, len(stops))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	semaphore := make(chan struct{}, maxConcurrentScheduleRequests)
+	for i, stop := range stops {
+		wg.Add(1)
+		go func(i int, stopNumber int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			schedule, err := h.Vitrasa.GetSchedules(stopNumber)
+			if err != nil {
+				log.Printf("Failed to retrieve schedule for stop %d: %v", stopNumber, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				return
+			}
+			schedules[i] = schedule
+		}(i, stop.StopNumber)
+	}
+	wg.Wait()
+
+	return schedules, failed
+}
+
+// mergeDepartures builds a single list of departures sorted by time from the schedules of stops sorted by distance.
+// Departures that can't be reached walking in time are dropped, and each line and route is only kept at the closest stop
+func mergeDepartures(stops []api.NearbyStop, schedules [][]api.Schedule) []api.Departure {
+	closestStop := make(map[string]int)
+	departures := []api.Departure{}
+	for i, stop := range stops {
+		for _, schedule := range schedules[i] {
+			if schedule.Time < stop.WalkingMinutes {
+				continue
+			}
+
+			key := strconv.Itoa(schedule.Line.ID) + "|" + schedule.Route
+			if stopNumber, exists := closestStop[key]; exists && stopNumber != stop.StopNumber {
+				continue
+			}
+			closestStop[key] = stop.StopNumber
+
+			departures = append(departures, api.Departure{
+				Stop:    stop,
+				Line:    schedule.Line,
+				Route:   schedule.Route,
+				Time:    schedule.Time,
+				LeaveIn: schedule.Time - stop.WalkingMinutes,
+			})
+		}
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].Time < departures[j].Time
+	})
+
+	return departures
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+func TestGetNearbyDepartures(t *testing.T) {
+	s := newTestServer(t)
+
+	// No stop is close enough, so no schedule is requested
+	var nearby api.NearbyDepartures
+	s.expect(http.StatusOK, http.MethodGet, "/api/departures/nearby?lat=42.23&lon=-8.72&radius=500", "", &nearby)
+	if len(nearby.Departures) != 0 || nearby.Radius != 500 {
+		t.Fatalf("expected no departures within 500 meters, got %+v", nearby)
+	}
+}
+
+func TestGetNearbyDeparturesLimits(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		query string
+		code  api.ProblemCode
+	}{
+		{"lat=500&lon=0&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=NaN&lon=0&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=0&lon=200&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=0&lon=0&radius=0", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=NaN", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=1e9", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=100&limit=0", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=100&limit=100000", api.ProblemInvalidParameter},
+	}
+	for _, test := range tests {
+		s.expectProblem(http.StatusBadRequest, test.code, http.MethodGet, "/api/departures/nearby?"+test.query, "")
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFavoriteAliasLength is the highest number of characters of the alias of a favorite stop
+const maxFavoriteAliasLength = 64
+
+// UpdateFavoriteStop godoc
+// @Summary Update a favorite stop of a user
+// @Description Change the alias of a favorite stop, such as Home or Work, or the lines the user cares about at it. Only the fields present in the body are changed, and an empty list of lines means all of them
+// @Tags Identity
+// @Accept  json
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param stop_number path int true "Stop Number"
+// @Param favorite body api.FavoriteStopUpdate true "Changes"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 422 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_stops/{stop_number} [patch]
+func (h *Handler) UpdateFavoriteStop(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+	stopNumber := c.Param("stop_number")
+
+	stopNumberInt, err := strconv.Atoi(stopNumber)
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid stop number"))
+		return
+	}
+
+	var update api.FavoriteStopUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Invalid body, expected a JSON object with an alias or lines"))
+		return
+	}
+	if update.Alias != nil && utf8.RuneCountInString(*update.Alias) > maxFavoriteAliasLength {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, fmt.Sprintf("Alias can't be longer than %d characters", maxFavoriteAliasLength)))
+		return
+	}
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	if !slices.ContainsFunc(user.FavoriteStops, func(favorite api.FavoriteStop) bool {
+		return favorite.StopNumber == stopNumberInt
+	}) {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemFavoriteNotFound, "Stop is not a favorite"))
+		return
+	}
+
+	if update.Lines != nil {
+		lines, err := h.checkLineFilter(stopNumberInt, *update.Lines)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		update.Lines = &lines
+	}
+
+	// The stop may have been removed from the favorites by a concurrent request since it was checked
+	updated, err := h.Identities.UpdateFavoriteStop(user.ID, stopNumberInt, update)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !updated {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemFavoriteNotFound, "Stop is not a favorite"))
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionFavoriteUpdated, stopNumber)
+
+	h.respondWithUser(c, user.ID)
+}
+
+// ReorderFavoriteStops godoc
+// @Summary Reorder the favorite stops of a user
+// @Description Set the order of the favorite stops of a user, listing the numbers of every one of them once in the new order
+// @Tags Identity
+// @Accept  json
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param order body api.FavoriteStopsOrder true "New order"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_stops/order [put]
+func (h *Handler) ReorderFavoriteStops(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	var order api.FavoriteStopsOrder
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Invalid body, expected a JSON object with the stop numbers"))
+		return
+	}
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	err = h.Identities.ReorderFavoriteStops(user.ID, order.StopNumbers)
+	if errors.Is(err, storage.ErrInvalidFavoriteOrder) {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidFavoriteOrder, "The new order must list every favorite stop once"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionFavoritesReordered, "")
+
+	h.respondWithUser(c, user.ID)
+}
+
+// checkLineFilter checks that every line of a favorite stop filter exists and stops at the stop,
+// returning the line names without duplicates
+func (h *Handler) checkLineFilter(stopNumber int, names []string) ([]string, error) {
+	lines := []string{}
+	if len(names) == 0 {
+		return lines, nil
+	}
+
+	stop, err := h.Stops.GetStopByNumber(stopNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	lineStops, err := h.Lines.GetLineStops()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if slices.Contains(lines, name) {
+			continue
+		}
+
+		line, err := h.Lines.GetLineByName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		served := slices.ContainsFunc(lineStops[line.ID], func(lineStop api.Stop) bool {
+			return lineStop.ID == stop.ID
+		})
+		if !served {
+			return nil, middleware.NewHTTPError(http.StatusUnprocessableEntity, api.ProblemLineNotServingStop, fmt.Sprintf("Line %s doesn't stop at stop %d", name, stopNumber))
+		}
+
+		lines = append(lines, name)
+	}
+	return lines, nil
+}
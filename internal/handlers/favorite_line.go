@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFavoriteLines godoc
+// @Summary List the favorite lines of a user
+// @Description Provide the favorite lines of a user in the order they were added. Lines that no longer exist are flagged as missing
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Success 200 {array} api.FavoriteLine
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_lines [get]
+func (h *Handler) ListFavoriteLines(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	if err := h.populateFavoriteLines(user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, append([]api.FavoriteLine{}, user.FavoriteLines...))
+}
+
+// AddFavoriteLine godoc
+// @Summary Add a favorite line to a user
+// @Description Add a line to the favorites of a user, identified by the name provided by the bus company
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param line_name path string true "Line Name"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 409 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_lines/{line_name} [post]
+func (h *Handler) AddFavoriteLine(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+	lineName := c.Param("line_name")
+
+	line, err := h.Lines.GetLineByName(lineName)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	added, err := h.Identities.AddFavoriteLine(user.ID, line.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !added {
+		c.Error(middleware.NewHTTPError(http.StatusConflict, api.ProblemFavoriteExists, "Line is already a favorite"))
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionFavoriteLineAdded, line.Name)
+
+	h.respondWithUser(c, user.ID)
+}
+
+// RemoveFavoriteLine godoc
+// @Summary Remove a favorite line from a user
+// @Description Remove a line from the favorites of a user, even if it no longer exists
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param line_name path string true "Line Name"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_lines/{line_name} [delete]
+func (h *Handler) RemoveFavoriteLine(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+	lineName := c.Param("line_name")
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	removed, err := h.Identities.RemoveFavoriteLine(user.ID, lineName)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !removed {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemFavoriteNotFound, "Line is not a favorite"))
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionFavoriteLineRemoved, lineName)
+
+	h.respondWithUser(c, user.ID)
+}
+
+// populateFavoriteLines fills the favorite lines of a user with the info from the bus lines
+// database, flagging the ones that no longer exist instead of failing
+func (h *Handler) populateFavoriteLines(user *api.Identity) error {
+	for i, favorite := range user.FavoriteLines {
+		line, err := h.Lines.GetLineByName(favorite.Name)
+		if errors.Is(err, storage.ErrLineNotFound) {
+			user.FavoriteLines[i] = api.FavoriteLine{Line: api.Line{Name: favorite.Name}, Missing: true}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		user.FavoriteLines[i] = api.FavoriteLine{Line: line}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFavoriteRoutes godoc
+// @Summary List the favorite routes of a user
+// @Description Provide the favorite trips between two stops of a user in the order they were added, along with the lines that go from the origin to the destination without changing buses. Stops that no longer exist are flagged as missing
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Success 200 {array} api.FavoriteRoute
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_routes [get]
+func (h *Handler) ListFavoriteRoutes(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	if err := h.populateFavoriteRoutes(user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, append([]api.FavoriteRoute{}, user.FavoriteRoutes...))
+}
+
+// AddFavoriteRoute godoc
+// @Summary Add a favorite route to a user
+// @Description Add a trip from an origin stop to a destination stop to the favorites of a user
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param origin path int true "Origin Stop Number"
+// @Param destination path int true "Destination Stop Number"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 409 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_routes/{origin}/{destination} [post]
+func (h *Handler) AddFavoriteRoute(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	origin, destination, ok := parseFavoriteRoute(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.Stops.GetStopByNumber(origin); err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := h.Stops.GetStopByNumber(destination); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	added, err := h.Identities.AddFavoriteRoute(user.ID, origin, destination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !added {
+		c.Error(middleware.NewHTTPError(http.StatusConflict, api.ProblemFavoriteExists, "Route is already a favorite"))
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionFavoriteRouteAdded, fmt.Sprintf("%d-%d", origin, destination))
+
+	h.respondWithUser(c, user.ID)
+}
+
+// RemoveFavoriteRoute godoc
+// @Summary Remove a favorite route from a user
+// @Description Remove a trip from an origin stop to a destination stop from the favorites of a user
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param origin path int true "Origin Stop Number"
+// @Param destination path int true "Destination Stop Number"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_routes/{origin}/{destination} [delete]
+func (h *Handler) RemoveFavoriteRoute(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	origin, destination, ok := parseFavoriteRoute(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	removed, err := h.Identities.RemoveFavoriteRoute(user.ID, origin, destination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !removed {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemFavoriteNotFound, "Route is not a favorite"))
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionFavoriteRouteRemoved, fmt.Sprintf("%d-%d", origin, destination))
+
+	h.respondWithUser(c, user.ID)
+}
+
+// parseFavoriteRoute reads the origin and destination stop numbers of a favorite route from the path,
+// reporting the error to the client if they are not valid
+func parseFavoriteRoute(c *gin.Context) (int, int, bool) {
+	origin, err := strconv.Atoi(c.Param("origin"))
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid origin stop number"))
+		return 0, 0, false
+	}
+
+	destination, err := strconv.Atoi(c.Param("destination"))
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid destination stop number"))
+		return 0, 0, false
+	}
+
+	if origin == destination {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidFavoriteRoute, "Origin and destination must be different stops"))
+		return 0, 0, false
+	}
+
+	return origin, destination, true
+}
+
+// populateFavoriteRoutes fills the favorite routes of a user with the info from the bus database,
+// flagging the stops that no longer exist instead of failing, and finds the lines that go from the
+// origin to the destination of every route
+func (h *Handler) populateFavoriteRoutes(user *api.Identity) error {
+	if len(user.FavoriteRoutes) == 0 {
+		return nil
+	}
+
+	lineRoutes, err := h.Lines.GetLineRoutes()
+	if err != nil {
+		return err
+	}
+
+	for i, favorite := range user.FavoriteRoutes {
+		origin, err := h.favoriteStop(favorite.Origin.StopNumber)
+		if err != nil {
+			return err
+		}
+		destination, err := h.favoriteStop(favorite.Destination.StopNumber)
+		if err != nil {
+			return err
+		}
+
+		user.FavoriteRoutes[i] = api.FavoriteRoute{
+			Origin:      origin,
+			Destination: destination,
+			Lines:       directLines(lineRoutes, origin.StopNumber, destination.StopNumber),
+		}
+	}
+	return nil
+}
+
+// directLines returns the lines with a route that goes through the origin stop and later through the
+// destination stop
+func directLines(lineRoutes []api.LineRoute, origin, destination int) []api.Line {
+	lines := []api.Line{}
+	for _, route := range lineRoutes {
+		from := slices.IndexFunc(route.Stops, func(stop api.Stop) bool {
+			return stop.StopNumber == origin
+		})
+		if from == -1 {
+			continue
+		}
+
+		to := slices.IndexFunc(route.Stops[from+1:], func(stop api.Stop) bool {
+			return stop.StopNumber == destination
+		})
+		if to == -1 || slices.Contains(lines, route.Line) {
+			continue
+		}
+
+		lines = append(lines, route.Line)
+	}
+	return lines
+}
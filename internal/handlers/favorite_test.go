@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+func TestAddFavoriteStop(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/101", "", nil)
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", &user)
+	if numbers := favoriteStopNumbers(user); !slices.Equal(numbers, []int{101, 100}) {
+		t.Fatalf("expected favorite stops [101 100], got %v", numbers)
+	}
+	if user.FavoriteStops[1].Name != "Policarpo Sanz" || user.FavoriteStops[1].Position != 1 {
+		t.Fatalf("expected the favorite stops to be populated, got %+v", user.FavoriteStops[1])
+	}
+
+	s.expectProblem(http.StatusConflict, api.ProblemFavoriteExists, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemStopNotFound, http.MethodPost, "/api/users/telegram/1/favorite_stops/999", "")
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidStopNumber, http.MethodPost, "/api/users/telegram/1/favorite_stops/abc", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodPost, "/api/users/telegram/2/favorite_stops/100", "")
+}
+
+func TestPutFavoriteStop(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPut, "/api/users/telegram/1/favorite_stops/100", "", nil)
+	s.expect(http.StatusOK, http.MethodPut, "/api/users/telegram/1/favorite_stops/100", "", &user)
+	if numbers := favoriteStopNumbers(user); !slices.Equal(numbers, []int{100}) {
+		t.Fatalf("expected favorite stops [100], got %v", numbers)
+	}
+}
+
+func TestRemoveFavoriteStop(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", nil)
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/101", "", nil)
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodDelete, "/api/users/telegram/1/favorite_stops/100", "", &user)
+	if numbers := favoriteStopNumbers(user); !slices.Equal(numbers, []int{101}) {
+		t.Fatalf("expected favorite stops [101], got %v", numbers)
+	}
+
+	// The favorites are stored, not only returned
+	var stored api.Identity
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &stored)
+	if numbers := favoriteStopNumbers(stored); !slices.Equal(numbers, []int{101}) {
+		t.Fatalf("expected stored favorite stops [101], got %v", numbers)
+	}
+
+	s.expectProblem(http.StatusNotFound, api.ProblemFavoriteNotFound, http.MethodDelete, "/api/users/telegram/1/favorite_stops/100", "")
+}
+
+func TestMissingFavoriteStop(t *testing.T) {
+	s := newTestServer(t)
+	user := s.createUser("telegram", "1")
+
+	// A stop removed from the dataset stays in the favorites, flagged as missing
+	if _, err := s.handler.Identities.AddFavorite(user.ID, 555); err != nil {
+		t.Fatal(err)
+	}
+
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &user)
+	if len(user.FavoriteStops) != 1 || !user.FavoriteStops[0].Missing {
+		t.Fatalf("expected the favorite stop to be missing, got %+v", user.FavoriteStops)
+	}
+}
+
+func TestUpdateFavoriteStop(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", nil)
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPatch, "/api/users/telegram/1/favorite_stops/100", `{"alias":"Home","lines":["C1","C1"]}`, &user)
+	if favorite := user.FavoriteStops[0]; favorite.Alias != "Home" || !slices.Equal(favorite.Lines, []string{"C1"}) {
+		t.Fatalf("expected alias Home and lines [C1], got %+v", favorite)
+	}
+
+	// Only the fields in the body are changed
+	var updated api.Identity
+	s.expect(http.StatusOK, http.MethodPatch, "/api/users/telegram/1/favorite_stops/100", `{"lines":[]}`, &updated)
+	if favorite := updated.FavoriteStops[0]; favorite.Alias != "Home" || len(favorite.Lines) != 0 {
+		t.Fatalf("expected alias Home and no lines, got %+v", favorite)
+	}
+
+	s.expectProblem(http.StatusUnprocessableEntity, api.ProblemLineNotServingStop, http.MethodPatch, "/api/users/telegram/1/favorite_stops/100", `{"lines":["L5"]}`)
+	s.expectProblem(http.StatusNotFound, api.ProblemLineNotFound, http.MethodPatch, "/api/users/telegram/1/favorite_stops/100", `{"lines":["X9"]}`)
+	s.expectProblem(http.StatusNotFound, api.ProblemFavoriteNotFound, http.MethodPatch, "/api/users/telegram/1/favorite_stops/101", `{"alias":"Work"}`)
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidBody, http.MethodPatch, "/api/users/telegram/1/favorite_stops/100", `{"alias":1}`)
+}
+
+func TestReorderFavoriteStops(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	for _, stopNumber := range []string{"100", "101", "102"} {
+		s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/"+stopNumber, "", nil)
+	}
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPut, "/api/users/telegram/1/favorite_stops/order", `{"stop_numbers":[102,100,101]}`, &user)
+	if numbers := favoriteStopNumbers(user); !slices.Equal(numbers, []int{102, 100, 101}) {
+		t.Fatalf("expected favorite stops [102 100 101], got %v", numbers)
+	}
+
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidFavoriteOrder, http.MethodPut, "/api/users/telegram/1/favorite_stops/order", `{"stop_numbers":[102,100]}`)
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidFavoriteOrder, http.MethodPut, "/api/users/telegram/1/favorite_stops/order", `{"stop_numbers":[102,100,100]}`)
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidFavoriteOrder, http.MethodPut, "/api/users/telegram/1/favorite_stops/order", `{"stop_numbers":[102,100,101,999]}`)
+}
+
+func TestFavoriteLines(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_lines/L5", "", nil)
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_lines/C1", "", &user)
+	if len(user.FavoriteLines) != 2 || user.FavoriteLines[0].Name != "L5" || user.FavoriteLines[1].ID == 0 {
+		t.Fatalf("expected favorite lines L5 and C1, got %+v", user.FavoriteLines)
+	}
+
+	s.expectProblem(http.StatusConflict, api.ProblemFavoriteExists, http.MethodPost, "/api/users/telegram/1/favorite_lines/C1", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemLineNotFound, http.MethodPost, "/api/users/telegram/1/favorite_lines/X9", "")
+
+	s.expect(http.StatusOK, http.MethodDelete, "/api/users/telegram/1/favorite_lines/L5", "", nil)
+	s.expectProblem(http.StatusNotFound, api.ProblemFavoriteNotFound, http.MethodDelete, "/api/users/telegram/1/favorite_lines/L5", "")
+
+	var lines []api.FavoriteLine
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1/favorite_lines", "", &lines)
+	if len(lines) != 1 || lines[0].Name != "C1" {
+		t.Fatalf("expected favorite lines [C1], got %+v", lines)
+	}
+}
+
+func TestFavoriteRoutes(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_routes/100/101", "", nil)
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_routes/101/100", "", nil)
+
+	var routes []api.FavoriteRoute
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1/favorite_routes", "", &routes)
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 favorite routes, got %d", len(routes))
+	}
+	if len(routes[0].Lines) != 1 || routes[0].Lines[0].Name != "C1" {
+		t.Fatalf("expected line C1 to go from 100 to 101, got %+v", routes[0].Lines)
+	}
+	if len(routes[1].Lines) != 0 {
+		t.Fatalf("expected no line to go from 101 to 100, got %+v", routes[1].Lines)
+	}
+
+	s.expectProblem(http.StatusConflict, api.ProblemFavoriteExists, http.MethodPost, "/api/users/telegram/1/favorite_routes/100/101", "")
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidFavoriteRoute, http.MethodPost, "/api/users/telegram/1/favorite_routes/100/100", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemStopNotFound, http.MethodPost, "/api/users/telegram/1/favorite_routes/100/999", "")
+
+	s.expect(http.StatusOK, http.MethodDelete, "/api/users/telegram/1/favorite_routes/100/101", "", nil)
+	s.expectProblem(http.StatusNotFound, api.ProblemFavoriteNotFound, http.MethodDelete, "/api/users/telegram/1/favorite_routes/100/101", "")
+}
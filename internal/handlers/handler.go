@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"sync"
+
+	"github.com/eryalito/vigo-bus-core/internal/metadata"
+	"github.com/eryalito/vigo-bus-core/internal/planner"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/vitrasa"
+)
+
+// defaultMetadataMaxBytes is the size limit of the metadata of a user unless another validator is set
+const defaultMetadataMaxBytes = 16384
+
+// Handler holds the dependencies shared by every endpoint
+type Handler struct {
+	// Stops is the repository of bus stops
+	Stops storage.StopRepository
+
+	// Lines is the repository of bus lines
+	Lines storage.LineRepository
+
+	// Identities is the repository of users
+	Identities storage.IdentityRepository
+
+	// Tokens is the repository of API tokens
+	Tokens storage.TokenRepository
+
+	// Dataset is the reloadable source of the stops and lines, if any
+	Dataset storage.ReloadableDataset
+
+	// Metadata checks the metadata of the users before it is stored
+	Metadata *metadata.Validator
+
+	// Vitrasa is the client used to retrieve the live schedules
+	Vitrasa *vitrasa.VitrasaClient
+
+	// network is the stops and lines graph used by the planner, loaded on first use
+	network   *planner.Network
+	networkMu sync.Mutex
+}
+
+// NewHandler creates a new Handler using the given repositories
+func NewHandler(stops storage.StopRepository, lines storage.LineRepository, identities storage.IdentityRepository) *Handler {
+	return &Handler{
+		Stops:      stops,
+		Lines:      lines,
+		Identities: identities,
+		Metadata:   &metadata.Validator{MaxBytes: defaultMetadataMaxBytes},
+		Vitrasa:    vitrasa.NewVitrasaClient(lines),
+	}
+}
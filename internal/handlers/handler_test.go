@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/internal/config"
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/storage/memory"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testToken is the root token accepted by the test router
+const testToken = "test-token"
+
+// testServer is a router with the endpoints of the API backed by the given repositories
+type testServer struct {
+	t       *testing.T
+	router  *gin.Engine
+	handler *Handler
+
+	// token is the bearer token the requests are authenticated with
+	token string
+}
+
+// newTestServer creates a test server on in-memory repositories with a few stops and lines:
+// stops 100 and 101 are served by line C1, which goes from 100 to 101, and stop 102 only by line L5
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	bus := memory.NewBusStore()
+	first := bus.AddStop(api.Stop{StopNumber: 100, Name: "Policarpo Sanz"})
+	second := bus.AddStop(api.Stop{StopNumber: 101, Name: "Praza de España"})
+	third := bus.AddStop(api.Stop{StopNumber: 102, Name: "Samil"})
+	c1 := bus.AddLine(api.Line{Name: "C1"}, first, second)
+	bus.AddLine(api.Line{Name: "L5"}, third)
+	bus.AddLineRoute(api.LineRoute{Line: c1, Stops: []api.Stop{first, second}})
+
+	return newTestServerWith(t, bus, memory.NewIdentityStore())
+}
+
+// newTestServerWith creates a test server on the given repositories
+func newTestServerWith(t *testing.T, bus *memory.BusStore, identities storage.IdentityRepository) *testServer {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	config.Token = testToken
+	config.LinkCodeTTL = 600
+
+	h := NewHandler(bus, bus, identities)
+	h.Tokens = memory.NewTokenStore()
+
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware)
+	r.Use(middleware.ErrorMiddleware)
+
+	RegisterRoutes(r, h, middleware.NewAuthMiddleware(h.Tokens))
+
+	return &testServer{t: t, router: r, handler: h, token: testToken}
+}
+
+// as returns a copy of the test server whose requests are authenticated with the given token
+func (s *testServer) as(token string) *testServer {
+	copied := *s
+	copied.token = token
+	return &copied
+}
+
+// request serves a request authenticated with the token of the server, sending the body as JSON if not empty
+func (s *testServer) request(method, path, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect serves a request and fails the test unless it responds with the given status, decoding
+// the response into out if not nil
+func (s *testServer) expect(status int, method, path, body string, out any) {
+	s.t.Helper()
+
+	w := s.request(method, path, body)
+	if w.Code != status {
+		s.t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, w.Code, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+}
+
+// expectProblem serves a request and fails the test unless it responds with a problem of the given
+// status and code
+func (s *testServer) expectProblem(status int, code api.ProblemCode, method, path, body string) {
+	s.t.Helper()
+
+	var problem api.Problem
+	s.expect(status, method, path, body, &problem)
+	if problem.Code != code {
+		s.t.Fatalf("%s %s: expected problem %s, got %s", method, path, code, problem.Code)
+	}
+}
+
+// createUser creates a user and returns it
+func (s *testServer) createUser(provider, uuid string) api.Identity {
+	s.t.Helper()
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/"+provider+"/"+uuid, "", &user)
+	return user
+}
+
+// favoriteStopNumbers returns the stop numbers of the favorite stops of a user in order
+func favoriteStopNumbers(user api.Identity) []int {
+	numbers := []int{}
+	for _, favorite := range user.FavoriteStops {
+		numbers = append(numbers, favorite.StopNumber)
+	}
+	return numbers
+}
@@ -13,6 +13,6 @@ import (
 // @Produce text/plain
 // @Success 200 {string} string "OK"
 // @Router /health [get]
-func HealthCheck(c *gin.Context) {
+func (h *Handler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, "ok")
 }
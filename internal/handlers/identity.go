@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/metadata"
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/tokens"
 	"github.com/eryalito/vigo-bus-core/pkg/api"
 
 	"github.com/gin-gonic/gin"
@@ -14,49 +20,89 @@ import (
 
 // GetUser godoc
 // @Summary Get a user by its UUID for a specific provider
-// @Description Provide a user by its UUID for a specific provider
+// @Description Provide a user by its UUID for a specific provider, or a user_not_found problem if it doesn't exist. Favorite stops and lines that no longer exist are flagged as missing. Only the linked identities of the providers the token is bound to are listed
 // @Tags Identity
 // @Produce  json
-// @Param provider path string true "Provider"
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
 // @Param uuid path string true "UUID"
 // @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/users/{provider}/{uuid} [get]
-func GetUser(c *gin.Context) {
+func (h *Handler) GetUser(c *gin.Context) {
 	provider := c.Param("provider")
 	uuid := c.Param("uuid")
 
-	sdb_conn, err := sqlite.NewIdentityConnector()
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
 		return
 	}
 
-	user, err := sdb_conn.GetUserByUUID(provider, uuid)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.populateFavorites(user); err != nil {
+		c.Error(err)
 		return
 	}
+	hideLinkedIdentities(c, user)
 
-	// Populate the favorite stops with the info from the bus stops database
-	bdb_conn, err := sqlite.NewBusConnector()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, user)
+}
+
+// hideLinkedIdentities removes the linked identities of the providers the API token of the request
+// can't access, so a token bound to some providers doesn't learn the users of the rest
+func hideLinkedIdentities(c *gin.Context, user *api.Identity) {
+	token := middleware.Token(c)
+	if token == nil {
 		return
 	}
-	defer bdb_conn.Close()
 
-	if user != nil {
-		for i, stop := range user.FavoriteStops {
-			stopInfo, err := bdb_conn.GetStopByNumber(stop.StopNumber)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-			user.FavoriteStops[i] = stopInfo
+	var visible []api.LinkedIdentity
+	for _, linked := range user.LinkedIdentities {
+		if tokens.AllowsProvider(token, linked.Provider) {
+			visible = append(visible, linked)
 		}
 	}
+	user.LinkedIdentities = visible
+}
 
-	c.JSON(http.StatusOK, user)
+// populateFavorites fills the favorite stops, lines and routes of a user with the info from the bus
+// database, flagging the ones that no longer exist instead of failing
+func (h *Handler) populateFavorites(user *api.Identity) error {
+	if err := h.populateFavoriteStops(user); err != nil {
+		return err
+	}
+	if err := h.populateFavoriteLines(user); err != nil {
+		return err
+	}
+	return h.populateFavoriteRoutes(user)
+}
+
+// populateFavoriteStops fills the favorite stops of a user with the info from the bus stops
+// database, flagging the ones that no longer exist instead of failing
+func (h *Handler) populateFavoriteStops(user *api.Identity) error {
+	for i, favorite := range user.FavoriteStops {
+		stop, err := h.favoriteStop(favorite.StopNumber)
+		if err != nil {
+			return err
+		}
+		user.FavoriteStops[i].Stop = stop
+	}
+	return nil
+}
+
+// favoriteStop retrieves a stop saved by a user, flagging it as missing if it no longer exists
+func (h *Handler) favoriteStop(stopNumber int) (api.Stop, error) {
+	stop, err := h.Stops.GetStopByNumber(stopNumber)
+	if errors.Is(err, storage.ErrStopNotFound) {
+		return api.Stop{StopNumber: stopNumber, Missing: true}, nil
+	}
+	return stop, err
 }
 
 // CreateUser godoc
@@ -64,29 +110,26 @@ func GetUser(c *gin.Context) {
 // @Description Create a new user
 // @Tags Identity
 // @Produce  json
-// @Param provider path string true "Provider"
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
 // @Param uuid path string true "UUID"
 // @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 409 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/users/{provider}/{uuid} [post]
-func CreateUser(c *gin.Context) {
+func (h *Handler) CreateUser(c *gin.Context) {
 	provider := c.Param("provider")
 	uuid := c.Param("uuid")
 
-	sdb_conn, err := sqlite.NewIdentityConnector()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer sdb_conn.Close()
-
 	// Check if a user with the same UUID and provider already exists
-	existingUser, err := sdb_conn.GetUserByUUID(provider, uuid)
+	existingUser, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	if existingUser != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with the same UUID and provider already exists"})
+		c.Error(middleware.NewHTTPError(http.StatusConflict, api.ProblemUserExists, "User with the same UUID and provider already exists"))
 		return
 	}
 
@@ -95,94 +138,106 @@ func CreateUser(c *gin.Context) {
 		UUID:     uuid,
 	}
 
-	err = sdb_conn.InsertIdentity(identity)
+	// A concurrent request may have created the same user after the check
+	err = h.Identities.InsertIdentity(identity)
+	if errors.Is(err, storage.ErrIdentityExists) {
+		c.Error(middleware.NewHTTPError(http.StatusConflict, api.ProblemUserExists, "User with the same UUID and provider already exists"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	user, err := sdb_conn.GetUserByUUID(provider, uuid)
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	h.recordEvent(user.ID, api.IdentityActionCreated, "")
 
 	c.JSON(http.StatusOK, user)
 }
 
 // AddFavoriteStopToIdentity godoc
 // @Summary Add a favorite stop to a user
-// @Description Add a favorite stop to a user
+// @Description Add a favorite stop to a user, failing if it is already a favorite. Use PUT to add it only if it is missing
 // @Tags Identity
 // @Produce  json
-// @Param provider path string true "Provider"
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
 // @Param uuid path string true "UUID"
 // @Param stop_number path int true "Stop Number"
 // @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 409 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/users/{provider}/{uuid}/favorite_stops/{stop_number} [post]
-func AddFavoriteStopToIdentity(c *gin.Context) {
+func (h *Handler) AddFavoriteStopToIdentity(c *gin.Context) {
+	h.addFavoriteStop(c, false)
+}
+
+// PutFavoriteStop godoc
+// @Summary Make sure a stop is a favorite of a user
+// @Description Add a favorite stop to a user if it isn't a favorite yet. Repeating the request has no further effect
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param stop_number path int true "Stop Number"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/favorite_stops/{stop_number} [put]
+func (h *Handler) PutFavoriteStop(c *gin.Context) {
+	h.addFavoriteStop(c, true)
+}
+
+// addFavoriteStop adds the stop of the request to the favorites of the user. When idempotent is
+// false, a stop that is already a favorite is reported as a conflict
+func (h *Handler) addFavoriteStop(c *gin.Context, idempotent bool) {
 	provider := c.Param("provider")
 	uuid := c.Param("uuid")
 	stopNumber := c.Param("stop_number")
 
 	stopNumberInt, err := strconv.Atoi(stopNumber)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stop number"})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid stop number"))
 		return
 	}
 
-	bdb_conn, err := sqlite.NewBusConnector()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if _, err := h.Stops.GetStopByNumber(stopNumberInt); err != nil {
+		c.Error(err)
 		return
 	}
-	defer bdb_conn.Close()
 
-	stop, err := bdb_conn.GetStopByNumber(stopNumberInt)
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if stop == (api.Stop{}) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stop not found"})
+		c.Error(err)
 		return
 	}
-
-	sdb_conn, err := sqlite.NewIdentityConnector()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
 		return
 	}
-	defer sdb_conn.Close()
 
-	user, err := sdb_conn.GetUserByUUID(provider, uuid)
+	added, err := h.Identities.AddFavorite(user.ID, stopNumberInt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !added && !idempotent {
+		c.Error(middleware.NewHTTPError(http.StatusConflict, api.ProblemFavoriteExists, "Stop is already a favorite"))
 		return
 	}
-
-	// Check if the stop is already a favorite
-	for _, stop := range user.FavoriteStops {
-		if stop.StopNumber == stopNumberInt {
-			c.JSON(http.StatusConflict, gin.H{"error": "Stop is already a favorite"})
-			return
-		}
+	if added {
+		h.recordEvent(user.ID, api.IdentityActionFavoriteAdded, stopNumber)
 	}
 
-	user.FavoriteStops = append(user.FavoriteStops, stop)
-
-	err = sdb_conn.UpdateIdentity(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, user)
+	h.respondWithUser(c, user.ID)
 }
 
 // RemoveFavoriteStopFromIdentity godoc
@@ -190,108 +245,272 @@ func AddFavoriteStopToIdentity(c *gin.Context) {
 // @Description Remove a favorite stop from a user
 // @Tags Identity
 // @Produce  json
-// @Param provider path string true "Provider"
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
 // @Param uuid path string true "UUID"
 // @Param stop_number path int true "Stop Number"
 // @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/users/{provider}/{uuid}/favorite_stops/{stop_number} [delete]
-func RemoveFavoriteStopFromIdentity(c *gin.Context) {
+func (h *Handler) RemoveFavoriteStopFromIdentity(c *gin.Context) {
 	provider := c.Param("provider")
 	uuid := c.Param("uuid")
 	stopNumber := c.Param("stop_number")
 
 	stopNumberInt, err := strconv.Atoi(stopNumber)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stop number"})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid stop number"))
 		return
 	}
 
-	sdb_conn, err := sqlite.NewIdentityConnector()
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
 		return
 	}
-	defer sdb_conn.Close()
 
-	user, err := sdb_conn.GetUserByUUID(provider, uuid)
+	removed, err := h.Identities.RemoveFavorite(user.ID, stopNumberInt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !removed {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemFavoriteNotFound, "Stop is not a favorite"))
 		return
 	}
+	h.recordEvent(user.ID, api.IdentityActionFavoriteRemoved, stopNumber)
 
-	// Check if the stop is a favorite
-	var stopIndex = -1
-	for i, stop := range user.FavoriteStops {
-		if stop.StopNumber == stopNumberInt {
-			stopIndex = i
-			break
-		}
+	h.respondWithUser(c, user.ID)
+}
+
+// respondWithUser responds with the current state of a user after changing it, along with the info
+// of its favorites
+func (h *Handler) respondWithUser(c *gin.Context, id int) {
+	user, err := h.Identities.GetIdentity(id)
+	if err != nil {
+		c.Error(err)
+		return
 	}
-	if stopIndex == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stop is not a favorite"})
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
 		return
 	}
 
-	user.FavoriteStops = append(user.FavoriteStops[:stopIndex], user.FavoriteStops[stopIndex+1:]...)
-
-	err = sdb_conn.UpdateIdentity(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.populateFavorites(user); err != nil {
+		c.Error(err)
 		return
 	}
+	hideLinkedIdentities(c, user)
 
 	c.JSON(http.StatusOK, user)
 }
 
 // UpdateMetadata godoc
-// @Summary Update the metadata of a user
-// @Description Update the metadata of a user
+// @Summary Replace the metadata of a user
+// @Description Replace the metadata of a user, and of the identities linked to it, with a JSON object. The metadata must fit the size limit and match the JSON Schema configured by the operator, if any. Tokens bound to providers can only change it when they are bound to the providers of every linked identity
 // @Tags Identity
+// @Accept  json
 // @Produce  json
-// @Param provider path string true "Provider"
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
 // @Param uuid path string true "UUID"
-// @Param metadata body string true "Metadata"
+// @Param metadata body object true "Metadata"
 // @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 413 {object} api.Problem
+// @Failure 422 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/users/{provider}/{uuid}/metadata [put]
-func UpdateMetadata(c *gin.Context) {
+func (h *Handler) UpdateMetadata(c *gin.Context) {
+	h.changeMetadata(c, func(_, body map[string]any) map[string]any {
+		return body
+	})
+}
+
+// PatchMetadata godoc
+// @Summary Update the metadata of a user
+// @Description Apply a JSON merge patch (RFC 7396) to the metadata of a user, and of the identities linked to it: the keys of the patch replace the current ones, objects are merged and null values remove the keys. The result must fit the size limit and match the JSON Schema configured by the operator, if any. Tokens bound to providers can only change it when they are bound to the providers of every linked identity
+// @Tags Identity
+// @Accept  json
+// @Accept  application/merge-patch+json
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param patch body object true "Merge patch"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 413 {object} api.Problem
+// @Failure 422 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/metadata [patch]
+func (h *Handler) PatchMetadata(c *gin.Context) {
+	h.changeMetadata(c, metadata.MergePatch)
+}
+
+// changeMetadata replaces the metadata of the user with the result of applying the body of the
+// request to it, once it is checked by the metadata validator
+func (h *Handler) changeMetadata(c *gin.Context, apply func(current, body map[string]any) map[string]any) {
 	provider := c.Param("provider")
 	uuid := c.Param("uuid")
-	var metadata string
+
+	// The body can't be bigger than the metadata, as it's either the new metadata or a patch of it
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.Metadata.MaxBytes))
 	bodyBytes, err := io.ReadAll(c.Request.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.Error(middleware.NewHTTPError(http.StatusRequestEntityTooLarge, api.ProblemMetadataTooLarge, fmt.Sprintf("Metadata can't be bigger than %d bytes", h.Metadata.MaxBytes)))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Failed to read the body"))
 		return
 	}
-	metadata = string(bodyBytes)
 
-	sdb_conn, err := sqlite.NewIdentityConnector()
+	body, err := metadata.Parse(bodyBytes)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidMetadata, "Metadata must be a JSON object"))
 		return
 	}
-	defer sdb_conn.Close()
 
-	user, err := sdb_conn.GetUserByUUID(provider, uuid)
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	// The metadata is shared by the whole account, so it can only be changed with access to all of its identities
+	token := middleware.Token(c)
+	for _, linked := range user.LinkedIdentities {
+		if !tokens.AllowsProvider(token, linked.Provider) {
+			c.Error(middleware.NewHTTPError(http.StatusForbidden, api.ProblemProviderNotAllowed, fmt.Sprintf("Token can't change the metadata shared with the users of provider %s", linked.Provider)))
+			return
+		}
+	}
+
+	// The body is applied to the metadata current when the update runs, so concurrent changes aren't lost
+	err = h.Identities.UpdateMetadata(user.ID, func(current map[string]any) (map[string]any, error) {
+		updated := apply(current, body)
+		if err := h.Metadata.Validate(updated); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	})
+	switch {
+	case errors.Is(err, metadata.ErrTooLarge):
+		c.Error(middleware.NewHTTPError(http.StatusRequestEntityTooLarge, api.ProblemMetadataTooLarge, err.Error()))
+		return
+	case errors.Is(err, metadata.ErrSchemaViolation):
+		c.Error(middleware.NewHTTPError(http.StatusUnprocessableEntity, api.ProblemMetadataSchemaViolation, err.Error()))
+		return
+	case err != nil:
+		c.Error(err)
 		return
 	}
+	h.recordEvent(user.ID, api.IdentityActionMetadataUpdated, "")
+
+	h.respondWithUser(c, user.ID)
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Delete a user along with its data. The user disappears right away and is purged for good after the retention period. Its favorite stops and metadata are kept by the identities linked to it, if any
+// @Tags Identity
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Success 204
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid} [delete]
+func (h *Handler) DeleteUser(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
 
-	user.Metadata = metadata
-	log.Println(metadata)
-	err = sdb_conn.UpdateIdentity(user)
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	if err := h.Identities.SoftDeleteIdentity(user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ExportUser godoc
+// @Summary Export the data of a user
+// @Description Provide all the data kept about a user as a JSON file: the identity with its favorite stops, metadata and linked identities, and the history of actions done on it. Only the linked identities of the providers the token is bound to are listed
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Success 200 {object} api.IdentityExport
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/export [get]
+func (h *Handler) ExportUser(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	if err := h.populateFavorites(user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	hideLinkedIdentities(c, user)
+
+	h.recordEvent(user.ID, api.IdentityActionExported, "")
+	history, err := h.Identities.ListIdentityEvents(user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="vigo-bus-user-data.json"`)
+	c.JSON(http.StatusOK, api.IdentityExport{
+		ExportedAt: time.Now(),
+		Identity:   *user,
+		History:    history,
+	})
+}
+
+// recordEvent adds an action to the history of a user, logging the failures instead of failing the request
+func (h *Handler) recordEvent(identityID int, action api.IdentityAction, detail string) {
+	event := api.IdentityEvent{Action: action, Detail: detail, CreatedAt: time.Now()}
+	if err := h.Identities.AddIdentityEvent(identityID, event); err != nil {
+		log.Printf("Failed to record %s event of identity %d: %v", action, identityID, err)
+	}
 }
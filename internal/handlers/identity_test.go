@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+func TestCreateUser(t *testing.T) {
+	s := newTestServer(t)
+
+	user := s.createUser("telegram", "1")
+	if user.ID == 0 || user.Provider != api.ProviderTypeTelegram || user.UUID != "1" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	s.expectProblem(http.StatusConflict, api.ProblemUserExists, http.MethodPost, "/api/users/telegram/1", "")
+	s.expectProblem(http.StatusBadRequest, api.ProblemUnknownProvider, http.MethodPost, "/api/users/myspace/1", "")
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidUUID, http.MethodPost, "/api/users/telegram/abc", "")
+}
+
+func TestGetUser(t *testing.T) {
+	s := newTestServer(t)
+	created := s.createUser("telegram", "1")
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &user)
+	if user.ID != created.ID {
+		t.Fatalf("expected user %d, got %d", created.ID, user.ID)
+	}
+
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodGet, "/api/users/telegram/2", "")
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+
+	s.expect(http.StatusNoContent, http.MethodDelete, "/api/users/telegram/1", "", nil)
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodGet, "/api/users/telegram/1", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodDelete, "/api/users/telegram/1", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodGet, "/api/users/telegram/1/export", "")
+
+	// The UUID can be used again right away
+	s.createUser("telegram", "1")
+}
+
+func TestExportUser(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", nil)
+
+	w := s.request(http.MethodGet, "/api/users/telegram/1/export", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") == "" {
+		t.Fatalf("expected the export as an attachment, got %d: %v", w.Code, w.Header())
+	}
+
+	var export api.IdentityExport
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1/export", "", &export)
+	if numbers := favoriteStopNumbers(export.Identity); !slices.Equal(numbers, []int{100}) {
+		t.Fatalf("expected favorite stops [100], got %v", numbers)
+	}
+
+	var actions []api.IdentityAction
+	for _, event := range export.History {
+		actions = append(actions, event.Action)
+	}
+	expected := []api.IdentityAction{api.IdentityActionCreated, api.IdentityActionFavoriteAdded, api.IdentityActionExported, api.IdentityActionExported}
+	if !slices.Equal(actions, expected) {
+		t.Fatalf("expected history %v, got %v", expected, actions)
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", nil)
+
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPut, "/api/users/telegram/1/metadata", `{"lang":"gl","theme":{"dark":true}}`, &user)
+	expected := map[string]any{"lang": "gl", "theme": map[string]any{"dark": true}}
+	if !reflect.DeepEqual(user.Metadata, expected) {
+		t.Fatalf("expected metadata %v, got %v", expected, user.Metadata)
+	}
+	if len(user.FavoriteStops) != 1 || user.FavoriteStops[0].Name != "Policarpo Sanz" {
+		t.Fatalf("expected the favorite stops to be populated, got %+v", user.FavoriteStops)
+	}
+
+	var patched api.Identity
+	s.expect(http.StatusOK, http.MethodPatch, "/api/users/telegram/1/metadata", `{"lang":null,"theme":{"size":2}}`, &patched)
+	expected = map[string]any{"theme": map[string]any{"dark": true, "size": float64(2)}}
+	if !reflect.DeepEqual(patched.Metadata, expected) {
+		t.Fatalf("expected metadata %v, got %v", expected, patched.Metadata)
+	}
+
+	var stored api.Identity
+	s.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &stored)
+	if !reflect.DeepEqual(stored.Metadata, expected) {
+		t.Fatalf("expected stored metadata %v, got %v", expected, stored.Metadata)
+	}
+}
+
+func TestUpdateMetadataErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	s.handler.Metadata.MaxBytes = 32
+
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidMetadata, http.MethodPut, "/api/users/telegram/1/metadata", `["not", "an", "object"]`)
+	s.expectProblem(http.StatusRequestEntityTooLarge, api.ProblemMetadataTooLarge, http.MethodPut, "/api/users/telegram/1/metadata", `{"text":"longer than thirty two bytes"}`)
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodPut, "/api/users/telegram/2/metadata", `{}`)
+}
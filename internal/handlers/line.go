@@ -3,8 +3,6 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/eryalito/vigo-bus-core/internal/sqlite"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,17 +12,13 @@ import (
 // @Tags Bus
 // @Produce  json
 // @Success 200 {array} api.Line
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/lines [get]
-func ListLines(c *gin.Context) {
-	sdb_conn, err := sqlite.NewBusConnector()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	lines, err := sdb_conn.GetLines()
+func (h *Handler) ListLines(c *gin.Context) {
+	lines, err := h.Lines.GetLines()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
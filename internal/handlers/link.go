@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/config"
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// linkCodeAlphabet holds the characters of the link codes, leaving out the ones easily mistaken for others
+const linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// linkCodeLength is the number of characters of the link codes
+const linkCodeLength = 8
+
+// CreateLinkCode godoc
+// @Summary Create a code to link another identity to the account of a user
+// @Description Provide a one-time code that links another identity, such as the same rider in another app, to the account of the user. Linked identities share their favorite stops and metadata. The code replaces the previous one of the user and expires after a few minutes
+// @Tags Identity
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Success 200 {object} api.LinkCode
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/link-code [post]
+func (h *Handler) CreateLinkCode(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	code, err := newLinkCode()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	linkCode := api.LinkCode{
+		Code:      code,
+		ExpiresAt: time.Now().Add(time.Duration(config.LinkCodeTTL) * time.Second).UTC().Truncate(time.Second),
+	}
+	if err := h.Identities.CreateLinkCode(user.ID, linkCode.Code, linkCode.ExpiresAt); err != nil {
+		c.Error(err)
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionLinkCodeCreated, "")
+
+	c.JSON(http.StatusOK, linkCode)
+}
+
+// LinkIdentity godoc
+// @Summary Link a user to the account of another identity
+// @Description Move the user, along with the identities already linked to it, to the account of the identity that created the link code. Favorite stops are merged, and so is the metadata when both are JSON objects, keeping the values of the account linked to on conflicts
+// @Tags Identity
+// @Accept  json
+// @Produce  json
+// @Param provider path string true "Provider" Enums(telegram, discord, matrix, web, whatsapp)
+// @Param uuid path string true "UUID"
+// @Param link body api.LinkRequest true "Link code"
+// @Success 200 {object} api.Identity
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 409 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/users/{provider}/{uuid}/link [post]
+func (h *Handler) LinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+	uuid := c.Param("uuid")
+
+	var request api.LinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Invalid body, expected a JSON object with a code"))
+		return
+	}
+
+	user, err := h.Identities.GetUserByUUID(provider, uuid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemUserNotFound, "User not found"))
+		return
+	}
+
+	// Codes are typed by the riders, so surrounding spaces and lowercase letters are accepted
+	code := strings.ToUpper(strings.TrimSpace(request.Code))
+
+	linked, err := h.Identities.LinkIdentity(user.ID, code)
+	if errors.Is(err, storage.ErrInvalidLinkCode) {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidLinkCode, "Invalid, used or expired link code"))
+		return
+	}
+	if errors.Is(err, storage.ErrAlreadyLinked) {
+		c.Error(middleware.NewHTTPError(http.StatusConflict, api.ProblemAlreadyLinked, "User is already linked to the account"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.recordEvent(user.ID, api.IdentityActionLinked, fmt.Sprintf("account %d", linked.AccountID))
+
+	if err := h.populateFavorites(linked); err != nil {
+		c.Error(err)
+		return
+	}
+	hideLinkedIdentities(c, linked)
+
+	c.JSON(http.StatusOK, linked)
+}
+
+// newLinkCode generates a random link code
+func newLinkCode() (string, error) {
+	b := make([]byte, linkCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate link code: %v", err)
+	}
+
+	// The alphabet has 32 characters, so every byte maps to one of them without bias
+	for i := range b {
+		b[i] = linkCodeAlphabet[int(b[i])%len(linkCodeAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+func TestLinkIdentity(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("telegram", "1")
+	s.createUser("discord", "123456789012345678")
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", nil)
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/discord/123456789012345678/favorite_stops/101", "", nil)
+
+	var code api.LinkCode
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/link-code", "", &code)
+	if code.Code == "" || code.ExpiresAt.IsZero() {
+		t.Fatalf("expected a link code with an expiration, got %+v", code)
+	}
+
+	// Codes are accepted as typed by the riders
+	body := `{"code":" ` + strings.ToLower(code.Code) + ` "}`
+	var user api.Identity
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/discord/123456789012345678/link", body, &user)
+	if numbers := favoriteStopNumbers(user); !slices.Equal(numbers, []int{100, 101}) {
+		t.Fatalf("expected favorite stops [100 101], got %v", numbers)
+	}
+	if len(user.LinkedIdentities) != 1 || user.LinkedIdentities[0] != (api.LinkedIdentity{Provider: api.ProviderTypeTelegram, UUID: "1"}) {
+		t.Fatalf("expected the telegram identity to be linked, got %+v", user.LinkedIdentities)
+	}
+
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidLinkCode, http.MethodPost, "/api/users/discord/123456789012345678/link", body)
+
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/link-code", "", &code)
+	s.expectProblem(http.StatusConflict, api.ProblemAlreadyLinked, http.MethodPost, "/api/users/discord/123456789012345678/link", `{"code":"`+code.Code+`"}`)
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidBody, http.MethodPost, "/api/users/discord/123456789012345678/link", `{}`)
+	s.expectProblem(http.StatusNotFound, api.ProblemUserNotFound, http.MethodPost, "/api/users/telegram/2/link-code", "")
+}
+
+func TestLinkedIdentitiesOfOtherProviders(t *testing.T) {
+	s := newTestServer(t)
+	telegram := s.as(s.createToken(`{"name":"telegram","scopes":["users:write"],"providers":["telegram"]}`).Secret)
+	both := s.as(s.createToken(`{"name":"both","scopes":["users:write"],"providers":["telegram","discord"]}`).Secret)
+
+	s.createUser("telegram", "1")
+	s.createUser("telegram", "2")
+	s.createUser("discord", "123456789012345678")
+	var code api.LinkCode
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/link-code", "", &code)
+	s.expect(http.StatusOK, http.MethodPost, "/api/users/discord/123456789012345678/link", `{"code":"`+code.Code+`"}`, nil)
+
+	// The discord identity is hidden from the token bound to telegram only
+	var user api.Identity
+	telegram.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &user)
+	if len(user.LinkedIdentities) != 0 {
+		t.Errorf("expected no linked identities, got %+v", user.LinkedIdentities)
+	}
+	var export api.IdentityExport
+	telegram.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1/export", "", &export)
+	if len(export.Identity.LinkedIdentities) != 0 {
+		t.Errorf("expected no linked identities in the export, got %+v", export.Identity.LinkedIdentities)
+	}
+	telegram.expect(http.StatusOK, http.MethodPost, "/api/users/telegram/1/favorite_stops/100", "", &user)
+	if len(user.LinkedIdentities) != 0 {
+		t.Errorf("expected no linked identities after adding a favorite, got %+v", user.LinkedIdentities)
+	}
+	both.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", &user)
+	if len(user.LinkedIdentities) != 1 || user.LinkedIdentities[0].Provider != api.ProviderTypeDiscord {
+		t.Errorf("expected the discord identity to be linked, got %+v", user.LinkedIdentities)
+	}
+
+	// The metadata is shared with the discord identity, so only tokens bound to both can change it
+	telegram.expectProblem(http.StatusForbidden, api.ProblemProviderNotAllowed, http.MethodPut, "/api/users/telegram/1/metadata", `{"lang":"gl"}`)
+	telegram.expectProblem(http.StatusForbidden, api.ProblemProviderNotAllowed, http.MethodPatch, "/api/users/telegram/1/metadata", `{"lang":"gl"}`)
+	both.expect(http.StatusOK, http.MethodPut, "/api/users/telegram/1/metadata", `{"lang":"gl"}`, nil)
+	telegram.expect(http.StatusOK, http.MethodPut, "/api/users/telegram/2/metadata", `{"lang":"es"}`, nil)
+}
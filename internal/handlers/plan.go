@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/planner"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPlanTransfers is the highest number of transfers that can be requested to the planner
+const maxPlanTransfers = 4
+
+// PlanTrip godoc
+// @Summary Plan a trip between two locations
+// @Description Provide itineraries combining walks and bus lines between two locations, from fewer to more transfers. Times are estimated from average bus speeds and waiting times
+// @Tags Bus
+// @Produce  json
+// @Param from query string true "Origin as lat,lon"
+// @Param to query string true "Destination as lat,lon"
+// @Param depart_at query string false "Departure time in RFC 3339 format, default now"
+// @Param max_transfers query int false "Maximum number of transfers, default 2"
+// @Success 200 {object} api.Plan
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/plan [get]
+func (h *Handler) PlanTrip(c *gin.Context) {
+	from, err := parseCoordinates(c.Query("from"))
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidCoordinates, "Invalid from query parameter"))
+		return
+	}
+
+	to, err := parseCoordinates(c.Query("to"))
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidCoordinates, "Invalid to query parameter"))
+		return
+	}
+
+	departAt := time.Now()
+	if departAtStr := c.Query("depart_at"); departAtStr != "" {
+		departAt, err = time.Parse(time.RFC3339, departAtStr)
+		if err != nil {
+			c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid depart_at query parameter"))
+			return
+		}
+	}
+
+	maxTransfers, err := strconv.Atoi(c.DefaultQuery("max_transfers", "2"))
+	if err != nil || maxTransfers < 0 || maxTransfers > maxPlanTransfers {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid max_transfers query parameter"))
+		return
+	}
+
+	n, err := h.getNetwork()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	itineraries := n.Plan(from, to, departAt, maxTransfers, planner.DefaultParams())
+	if itineraries == nil {
+		itineraries = []api.Itinerary{}
+	}
+
+	c.JSON(http.StatusOK, api.Plan{
+		From:        from,
+		To:          to,
+		DepartAt:    departAt,
+		Itineraries: itineraries,
+	})
+}
+
+// getNetwork returns the planner network, loading it from the bus database the first time
+func (h *Handler) getNetwork() (*planner.Network, error) {
+	h.networkMu.Lock()
+	defer h.networkMu.Unlock()
+
+	if h.network != nil {
+		return h.network, nil
+	}
+
+	n, err := planner.LoadNetwork(h.Stops, h.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	h.network = n
+	return h.network, nil
+}
+
+// ResetNetwork discards the planner network so it is loaded again from the current dataset
+func (h *Handler) ResetNetwork() {
+	h.networkMu.Lock()
+	defer h.networkMu.Unlock()
+
+	h.network = nil
+}
+
+// parseCoordinates parses a location in lat,lon format
+func parseCoordinates(value string) (api.Coordinates, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return api.Coordinates{}, errors.New("coordinates must be in lat,lon format")
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || !validLatitude(lat) {
+		return api.Coordinates{}, errors.New("invalid latitude")
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || !validLongitude(lon) {
+		return api.Coordinates{}, errors.New("invalid longitude")
+	}
+
+	return api.Coordinates{Lat: lat, Lon: lon}, nil
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/eryalito/vigo-bus-core/internal/providers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProviders godoc
+// @Summary List the identity providers
+// @Description Provide the identity providers that can be used in the user endpoints, along with the format of their UUIDs
+// @Tags Identity
+// @Produce  json
+// @Success 200 {array} api.Provider
+// @Failure 403 {object} api.Problem
+// @Router /api/providers [get]
+func (h *Handler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, providers.List())
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes adds the endpoints of the API to the router. The API endpoints are authenticated
+// with auth and restricted to the tokens with the scope of each group
+func RegisterRoutes(r gin.IRouter, h *Handler, auth gin.HandlerFunc) {
+	apiRoutes := r.Group("/api")
+	apiRoutes.Use(auth)
+	{
+		apiRoutes.GET("/providers", h.ListProviders)
+	}
+
+	stops := apiRoutes.Group("")
+	stops.Use(middleware.RequireScope(api.ScopeStopsRead))
+	{
+		stops.GET("/stops", h.ListStops)
+		stops.GET("/stops/:stop_number", h.GetStop)
+		stops.GET("/stops/:stop_number/reachable", h.GetReachableStops)
+		stops.GET("/stops/find", h.FindStops)
+		stops.GET("/stops/find/location", h.FindStopsByLocation)
+		stops.GET("/stops/find/location/image", h.GetNearbyStopsImage)
+		stops.GET("/lines", h.ListLines)
+		stops.GET("/plan", h.PlanTrip)
+		stops.GET("/dataset", h.GetDataset)
+		stops.GET("/dataset/changes", h.GetDatasetChanges)
+	}
+
+	schedules := apiRoutes.Group("")
+	schedules.Use(middleware.RequireScope(api.ScopeSchedulesRead))
+	{
+		schedules.GET("/stops/:stop_number/schedule", h.GetStopSchedule)
+		schedules.GET("/departures/nearby", h.GetNearbyDepartures)
+	}
+
+	// User endpoints, only for the supported providers
+	users := apiRoutes.Group("/users/:provider/:uuid")
+	users.Use(middleware.RequireScope(api.ScopeUsersWrite), middleware.IdentityMiddleware, middleware.RequireProvider)
+	{
+		users.GET("", h.GetUser)
+		users.POST("", h.CreateUser)
+		users.DELETE("", h.DeleteUser)
+		users.GET("/export", h.ExportUser)
+		users.PUT("/metadata", h.UpdateMetadata)
+		users.PATCH("/metadata", h.PatchMetadata)
+		users.POST("/favorite_stops/:stop_number", h.AddFavoriteStopToIdentity)
+		users.PUT("/favorite_stops/:stop_number", h.PutFavoriteStop)
+		users.DELETE("/favorite_stops/:stop_number", h.RemoveFavoriteStopFromIdentity)
+		users.PATCH("/favorite_stops/:stop_number", h.UpdateFavoriteStop)
+		users.PUT("/favorite_stops/order", h.ReorderFavoriteStops)
+		users.GET("/favorite_lines", h.ListFavoriteLines)
+		users.POST("/favorite_lines/:line_name", h.AddFavoriteLine)
+		users.DELETE("/favorite_lines/:line_name", h.RemoveFavoriteLine)
+		users.GET("/favorite_routes", h.ListFavoriteRoutes)
+		users.POST("/favorite_routes/:origin/:destination", h.AddFavoriteRoute)
+		users.DELETE("/favorite_routes/:origin/:destination", h.RemoveFavoriteRoute)
+		users.POST("/link-code", h.CreateLinkCode)
+		users.POST("/link", h.LinkIdentity)
+	}
+
+	admin := r.Group("/admin")
+	admin.Use(auth, middleware.RequireScope(api.ScopeAdmin))
+	{
+		admin.POST("/stops/reload", h.ReloadStops)
+		admin.GET("/favorites/integrity", h.CheckFavoriteStops)
+		admin.POST("/tokens", h.CreateToken)
+		admin.GET("/tokens", h.ListTokens)
+		admin.DELETE("/tokens/:id", h.RevokeToken)
+	}
+
+	r.GET("/health", h.HealthCheck)
+}
@@ -1,36 +1,38 @@
 package handlers
 
 import (
-	"log"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/eryalito/vigo-bus-core/internal/config"
-	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/planner"
 	"github.com/eryalito/vigo-bus-core/internal/utils"
-	"github.com/eryalito/vigo-bus-core/internal/vitrasa"
 	"github.com/eryalito/vigo-bus-core/pkg/api"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxReachableMinutes is the highest time budget that can be requested for reachable stops
+const maxReachableMinutes = 120
+
 // ListStops godoc
 // @Summary List all of the stops
 // @Description Provide a list of all the stops
 // @Tags Bus
 // @Produce  json
 // @Success 200 {array} api.Stop
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/stops [get]
-func ListStops(c *gin.Context) {
-	sdb_conn, err := sqlite.NewBusConnector()
+func (h *Handler) ListStops(c *gin.Context) {
+	stops, err := h.Stops.GetStops()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	stops, err := sdb_conn.GetStops()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -44,24 +46,22 @@ func ListStops(c *gin.Context) {
 // @Produce  json
 // @Param stop_number path int true "Stop Number"
 // @Success 200 {object} api.Stop
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/stops/{stop_number} [get]
-func GetStop(c *gin.Context) {
+func (h *Handler) GetStop(c *gin.Context) {
 	stopNumber := c.Param("stop_number")
 	stopNumberInt, err := strconv.Atoi(stopNumber)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stop number"})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid stop number"))
 		return
 	}
 
-	sdb_conn, err := sqlite.NewBusConnector()
+	stop, err := h.Stops.GetStopByNumber(stopNumberInt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	stop, err := sdb_conn.GetStopByNumber(stopNumberInt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -70,28 +70,39 @@ func GetStop(c *gin.Context) {
 
 // FindStop godoc
 // @Summary Find a stop by text in its name
-// @Description Provide a list of stops that match the text in their name
+// @Description Provide a list of stops that match the text in their name, ordered by relevance. Case, accents and small typos are ignored
 // @Tags Bus
 // @Produce  json
 // @Param text query string true "Text to search for in stop name"
+// @Param limit query int false "Limit of stops to return, default 0 (no limit)"
+// @Param offset query int false "Number of stops to skip, default 0"
 // @Success 200 {array} api.Stop
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/stops/find [get]
-func FindStops(c *gin.Context) {
+func (h *Handler) FindStops(c *gin.Context) {
 	text := c.Query("text")
 	if text == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing text query parameter"})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Missing text query parameter"))
 		return
 	}
 
-	sdb_conn, err := sqlite.NewBusConnector()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid limit"))
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid offset"))
 		return
 	}
 
-	stops, err := sdb_conn.FindStopsByText(text)
+	stops, err := h.Stops.FindStopsByText(text, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -100,50 +111,43 @@ func FindStops(c *gin.Context) {
 
 // FindStopsByLocation godoc
 // @Summary Find a stop by its location
-// @Description Provide a list of stops in a given radius around a location
+// @Description Provide a list of stops in a given radius around a location, sorted from nearest to farthest, with their distance, bearing and walking time
 // @Tags Bus
 // @Produce  json
 // @Param lat query float64 true "Latitude"
 // @Param lon query float64 true "Longitude"
 // @Param radius query float64 true "Radius in meters"
-// @Success 200 {array} api.Stop
+// @Param limit query int false "Limit of stops to return, default 0 (no limit)"
+// @Success 200 {array} api.NearbyStop
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
 // @Router /api/stops/find/location [get]
-func FindStopsByLocation(c *gin.Context) {
-	latStr := c.Query("lat")
-	lonStr := c.Query("lon")
-	radiusStr := c.Query("radius")
-	if latStr == "" || lonStr == "" || radiusStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing lat, lon, or radius query parameters"})
+func (h *Handler) FindStopsByLocation(c *gin.Context) {
+	if c.Query("lat") == "" || c.Query("lon") == "" || c.Query("radius") == "" {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Missing lat, lon, or radius query parameters"))
 		return
 	}
 
-	lat, err := strconv.ParseFloat(latStr, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lat query parameter"})
+	location, ok := parseLocation(c)
+	if !ok {
 		return
 	}
 
-	lon, err := strconv.ParseFloat(lonStr, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lon query parameter"})
+	radius, ok := parseRadius(c)
+	if !ok {
 		return
 	}
 
-	radius, err := strconv.ParseFloat(radiusStr, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid radius query parameter"})
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid limit query parameter"))
 		return
 	}
 
-	sdb_conn, err := sqlite.NewBusConnector()
+	stops, err := h.Stops.FindStopsByLocation(location.Lat, location.Lon, radius, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	stops, err := sdb_conn.FindStopsByLocation(lat, lon, radius)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -157,36 +161,29 @@ func FindStopsByLocation(c *gin.Context) {
 // @Produce  json
 // @Param stop_number path int true "Stop Number"
 // @Success 200 {object} api.StopSchedule
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Failure 502 {object} api.Problem
 // @Router /api/stops/{stop_number}/schedule [get]
-func GetStopSchedule(c *gin.Context) {
+func (h *Handler) GetStopSchedule(c *gin.Context) {
 	stopNumber := c.Param("stop_number")
 	stopNumberInt, err := strconv.Atoi(stopNumber)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stop number"})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid stop number"))
 		return
 	}
 
-	sdb_conn, err := sqlite.NewBusConnector()
+	stop, err := h.Stops.GetStopByNumber(stopNumberInt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	stop, err := sdb_conn.GetStopByNumber(stopNumberInt)
+	schedule, err := h.Vitrasa.GetSchedules(stop.StopNumber)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if stop == (api.Stop{}) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stop not found"})
-		return
-	}
-
-	vitrasa_client := vitrasa.NewVitrasaClient()
-	schedule, err := vitrasa_client.GetSchedules(stop.StopNumber)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(&middleware.HTTPError{Status: http.StatusBadGateway, Code: api.ProblemUpstreamUnavailable, Message: "Failed to retrieve schedules", Err: err})
 		return
 	}
 
@@ -206,52 +203,35 @@ func GetStopSchedule(c *gin.Context) {
 // @Param radius query float64 true "Radius in meters"
 // @Param limit query int false "Limit of stops to return, default 9"
 // @Success 200 {object} api.NearbyStops
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Failure 502 {object} api.Problem
 // @Router /api/stops/find/location/image [get]
-func GetNearbyStopsImage(c *gin.Context) {
-	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude"})
+func (h *Handler) GetNearbyStopsImage(c *gin.Context) {
+	location, ok := parseLocation(c)
+	if !ok {
 		return
 	}
+	lat, lon := location.Lat, location.Lon
 
-	lon, err := strconv.ParseFloat(c.Query("lon"), 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid longitude"})
-		return
-	}
-
-	radius, err := strconv.ParseFloat(c.Query("radius"), 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid radius"})
+	radius, ok := parseRadius(c)
+	if !ok {
 		return
 	}
 
 	limit, err := strconv.Atoi(c.DefaultQuery("limit", "9"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid limit"))
 		return
 	}
 
-	sdb_conn, err := sqlite.NewBusConnector()
+	stops, err := h.Stops.FindStopsByLocation(lat, lon, radius, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	stops, err := sdb_conn.FindStopsByLocation(lat, lon, radius)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Sort the stops by distance
-	utils.SortStopsByDistance(lat, lon, stops)
-
-	// Truncate the stops to the limit
-	if len(stops) > limit {
-		stops = stops[:limit]
-	}
-
 	// Create the image
 	img, err := utils.GenerateImageWithMarkers(config.GoogleMapsAPIKey, struct {
 		Lat float64 `json:"lat"`
@@ -261,16 +241,14 @@ func GetNearbyStopsImage(c *gin.Context) {
 		Lon: lon,
 	}, stops)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create image"})
+		c.Error(&middleware.HTTPError{Status: http.StatusBadGateway, Code: api.ProblemUpstreamUnavailable, Message: "Failed to create image", Err: err})
 		return
 	}
 
 	// Encode the image to base64
 	encodedImage, err := utils.PngToBase64(img)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create image"})
+		c.Error(fmt.Errorf("failed to encode image: %v", err))
 		return
 	}
 
@@ -291,3 +269,157 @@ func GetNearbyStopsImage(c *gin.Context) {
 	// Return the NearbyStops object
 	c.JSON(http.StatusOK, nearbyStops)
 }
+
+// GetReachableStops godoc
+// @Summary Get the stops reachable from a stop within a time budget
+// @Description Provide the stops that can be reached by bus from a stop within the given minutes, with their estimated travel times. Bus speed, dwell, wait and transfer penalty default to the server configuration
+// @Tags Bus
+// @Produce  json
+// @Param stop_number path int true "Stop Number"
+// @Param minutes query int true "Time budget in minutes"
+// @Param max_transfers query int false "Maximum number of transfers, default 2"
+// @Param bus_speed query float64 false "Average bus speed in km/h"
+// @Param dwell query int false "Seconds a bus spends at each stop"
+// @Param wait query int false "Average seconds spent waiting for a bus"
+// @Param transfer_penalty query int false "Extra seconds added every time the rider changes buses"
+// @Param hull query bool false "Include the GeoJSON polygon enclosing the reachable stops, default false"
+// @Success 200 {object} api.Reachability
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /api/stops/{stop_number}/reachable [get]
+func (h *Handler) GetReachableStops(c *gin.Context) {
+	stopNumber := c.Param("stop_number")
+	stopNumberInt, err := strconv.Atoi(stopNumber)
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidStopNumber, "Invalid stop number"))
+		return
+	}
+
+	minutes, err := strconv.Atoi(c.Query("minutes"))
+	if err != nil || minutes <= 0 || minutes > maxReachableMinutes {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid minutes query parameter"))
+		return
+	}
+
+	maxTransfers, err := strconv.Atoi(c.DefaultQuery("max_transfers", "2"))
+	if err != nil || maxTransfers < 0 || maxTransfers > maxPlanTransfers {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid max_transfers query parameter"))
+		return
+	}
+
+	params := planner.DefaultParams()
+	if busSpeedStr := c.Query("bus_speed"); busSpeedStr != "" {
+		busSpeed, err := strconv.ParseFloat(busSpeedStr, 64)
+		if err != nil || busSpeed <= 0 {
+			c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid bus_speed query parameter"))
+			return
+		}
+		params.BusSpeed = busSpeed / 3.6
+	}
+	for name, param := range map[string]*time.Duration{
+		"dwell":            &params.Dwell,
+		"wait":             &params.Wait,
+		"transfer_penalty": &params.TransferPenalty,
+	} {
+		valueStr := c.Query(name)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil || value < 0 {
+			c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid "+name+" query parameter"))
+			return
+		}
+		*param = time.Duration(value) * time.Second
+	}
+
+	includeHull, err := strconv.ParseBool(c.DefaultQuery("hull", "false"))
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid hull query parameter"))
+		return
+	}
+
+	stop, err := h.Stops.GetStopByNumber(stopNumberInt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	n, err := h.getNetwork()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	stops, ok := n.Reachable(stop.ID, time.Duration(minutes)*time.Minute, maxTransfers, params)
+	if !ok {
+		c.Error(middleware.NewHTTPError(http.StatusNotFound, api.ProblemStopNotFound, "Stop not found"))
+		return
+	}
+	if stops == nil {
+		stops = []api.ReachableStop{}
+	}
+
+	reachability := api.Reachability{
+		Origin:  stop,
+		Minutes: minutes,
+		Stops:   stops,
+	}
+
+	if includeHull {
+		points := []geo.Point{{stop.Location.Lon, stop.Location.Lat}}
+		for _, reachable := range stops {
+			points = append(points, geo.Point{reachable.Location.Lon, reachable.Location.Lat})
+		}
+		if ring := geo.ConvexHull(points); ring != nil {
+			hull := api.Polygon{Type: "Polygon", Coordinates: [][][2]float64{{}}}
+			for _, point := range ring {
+				hull.Coordinates[0] = append(hull.Coordinates[0], point)
+			}
+			reachability.Hull = &hull
+		}
+	}
+
+	c.JSON(http.StatusOK, reachability)
+}
+
+// parseLocation reads the lat and lon query parameters, reporting the error to the client if they
+// are not a valid location
+func parseLocation(c *gin.Context) (api.Coordinates, bool) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || !validLatitude(lat) {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidCoordinates, "Invalid latitude, expected a number between -90 and 90"))
+		return api.Coordinates{}, false
+	}
+
+	lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+	if err != nil || !validLongitude(lon) {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidCoordinates, "Invalid longitude, expected a number between -180 and 180"))
+		return api.Coordinates{}, false
+	}
+
+	return api.Coordinates{Lat: lat, Lon: lon}, true
+}
+
+// parseRadius reads the radius query parameter in meters, reporting the error to the client if it
+// is not a positive number
+func parseRadius(c *gin.Context) (float64, bool) {
+	radius, err := strconv.ParseFloat(c.Query("radius"), 64)
+	if err != nil || !(radius > 0) || math.IsInf(radius, 1) {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid radius, expected a positive number of meters"))
+		return 0, false
+	}
+	return radius, true
+}
+
+// validLatitude tells whether a latitude is within range, which NaN is not
+func validLatitude(lat float64) bool {
+	return lat >= -90 && lat <= 90
+}
+
+// validLongitude tells whether a longitude is within range, which NaN is not
+func validLongitude(lon float64) bool {
+	return lon >= -180 && lon <= 180
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+func TestListStops(t *testing.T) {
+	s := newTestServer(t)
+
+	var stops []api.Stop
+	s.expect(http.StatusOK, http.MethodGet, "/api/stops", "", &stops)
+	if len(stops) != 3 {
+		t.Fatalf("expected 3 stops, got %d", len(stops))
+	}
+}
+
+func TestGetStop(t *testing.T) {
+	s := newTestServer(t)
+
+	var stop api.Stop
+	s.expect(http.StatusOK, http.MethodGet, "/api/stops/101", "", &stop)
+	if stop.StopNumber != 101 || stop.Name != "Praza de España" {
+		t.Fatalf("expected stop 101, got %+v", stop)
+	}
+
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidStopNumber, http.MethodGet, "/api/stops/abc", "")
+	s.expectProblem(http.StatusNotFound, api.ProblemStopNotFound, http.MethodGet, "/api/stops/999", "")
+}
+
+func TestFindStopsByLocation(t *testing.T) {
+	s := newTestServer(t)
+
+	var stops []api.NearbyStop
+	s.expect(http.StatusOK, http.MethodGet, "/api/stops/find/location?lat=0&lon=0&radius=100&limit=2", "", &stops)
+	if len(stops) != 2 {
+		t.Fatalf("expected 2 stops, got %d", len(stops))
+	}
+}
+
+func TestInvalidLocation(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		query string
+		code  api.ProblemCode
+	}{
+		{"lat=0&lon=0", api.ProblemInvalidParameter},
+		{"lat=abc&lon=0&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=500&lon=0&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=NaN&lon=0&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=0&lon=-181&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=0&lon=Inf&radius=100", api.ProblemInvalidCoordinates},
+		{"lat=0&lon=0&radius=-1", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=0", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=NaN", api.ProblemInvalidParameter},
+		{"lat=0&lon=0&radius=Inf", api.ProblemInvalidParameter},
+	}
+	for _, path := range []string{"/api/stops/find/location", "/api/stops/find/location/image"} {
+		for _, test := range tests {
+			s.expectProblem(http.StatusBadRequest, test.code, http.MethodGet, path+"?"+test.query, "")
+		}
+	}
+}
+
+func TestStopsRequireToken(t *testing.T) {
+	s := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/stops", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 without a token, got %d", w.Code)
+	}
+}
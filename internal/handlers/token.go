@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/providers"
+	"github.com/eryalito/vigo-bus-core/internal/tokens"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTokenNameLength is the highest number of characters of the name of an API token
+const maxTokenNameLength = 64
+
+// CreateToken godoc
+// @Summary Create an API token
+// @Description Create an API token for a client with the given scopes and optional expiry, bound to the users of some identity providers or to all of them. The token is only returned in this response, as only its hash is stored
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param token body api.TokenRequest true "Token"
+// @Success 201 {object} api.CreatedToken
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /admin/tokens [post]
+func (h *Handler) CreateToken(c *gin.Context) {
+	var request api.TokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Invalid body, expected a JSON object with a name and scopes"))
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxTokenNameLength {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Name must have between 1 and 64 characters"))
+		return
+	}
+	if len(request.Scopes) == 0 {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidScope, "At least one scope must be granted"))
+		return
+	}
+	if err := tokens.ValidateScopes(request.Scopes); err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidScope, err.Error()))
+		return
+	}
+	for _, provider := range request.Providers {
+		if !providers.Supported(provider) {
+			c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemUnknownProvider, fmt.Sprintf("Unknown provider %s, see /api/providers for the supported ones", provider)))
+			return
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidBody, "Expiry must be in the future"))
+		return
+	}
+
+	if request.Providers == nil {
+		request.Providers = []api.ProviderType{}
+	}
+
+	secret, err := tokens.Generate()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	token := api.Token{
+		Name:      name,
+		Scopes:    request.Scopes,
+		Providers: request.Providers,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		ExpiresAt: request.ExpiresAt,
+	}
+	if err := h.Tokens.InsertToken(&token, tokens.Hash(secret)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, api.CreatedToken{Token: token, Secret: secret})
+}
+
+// ListTokens godoc
+// @Summary List the API tokens
+// @Description List every API token, including the revoked and expired ones, without their secrets
+// @Tags Admin
+// @Produce  json
+// @Success 200 {array} api.Token
+// @Failure 403 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /admin/tokens [get]
+func (h *Handler) ListTokens(c *gin.Context) {
+	list, err := h.Tokens.ListTokens()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// RevokeToken godoc
+// @Summary Revoke an API token
+// @Description Revoke an API token, rejecting the requests made with it from then on. Revoking a token again has no effect
+// @Tags Admin
+// @Param id path int true "Token ID"
+// @Success 204
+// @Failure 400 {object} api.Problem
+// @Failure 403 {object} api.Problem
+// @Failure 404 {object} api.Problem
+// @Failure 500 {object} api.Problem
+// @Router /admin/tokens/{id} [delete]
+func (h *Handler) RevokeToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(middleware.NewHTTPError(http.StatusBadRequest, api.ProblemInvalidParameter, "Invalid token ID"))
+		return
+	}
+
+	if err := h.Tokens.RevokeToken(id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
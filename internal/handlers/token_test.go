@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/tokens"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// createToken creates an API token with the root token and returns it along with its secret
+func (s *testServer) createToken(body string) api.CreatedToken {
+	s.t.Helper()
+
+	var token api.CreatedToken
+	s.expect(http.StatusCreated, http.MethodPost, "/admin/tokens", body, &token)
+	return token
+}
+
+func TestTokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	stops := s.as(s.createToken(`{"name":"stops","scopes":["stops:read"]}`).Secret)
+	admin := s.as(s.createToken(`{"name":"admin","scopes":["admin"]}`).Secret)
+
+	stops.expect(http.StatusOK, http.MethodGet, "/api/stops", "", nil)
+	stops.expect(http.StatusOK, http.MethodGet, "/api/providers", "", nil)
+	stops.expectProblem(http.StatusForbidden, api.ProblemInsufficientScope, http.MethodGet, "/api/departures/nearby?lat=0&lon=0&radius=100", "")
+	stops.expectProblem(http.StatusForbidden, api.ProblemInsufficientScope, http.MethodPost, "/api/users/telegram/1", "")
+	stops.expectProblem(http.StatusForbidden, api.ProblemInsufficientScope, http.MethodGet, "/admin/tokens", "")
+
+	// The admin scope grants every other scope
+	admin.expect(http.StatusOK, http.MethodGet, "/api/stops", "", nil)
+	admin.createUser("telegram", "1")
+	admin.expect(http.StatusOK, http.MethodGet, "/admin/tokens", "", nil)
+
+	s.as("").expectProblem(http.StatusForbidden, api.ProblemForbidden, http.MethodGet, "/api/stops", "")
+	s.as("vbc_unknown").expectProblem(http.StatusForbidden, api.ProblemForbidden, http.MethodGet, "/api/stops", "")
+}
+
+func TestInactiveTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	// Tokens can't be created already expired, so the expired one is stored directly
+	expiredAt := time.Now().Add(-time.Minute)
+	expired := &api.Token{Name: "expired", Scopes: []api.Scope{api.ScopeAdmin}, ExpiresAt: &expiredAt}
+	if err := s.handler.Tokens.InsertToken(expired, tokens.Hash("vbc_expired")); err != nil {
+		t.Fatal(err)
+	}
+	s.as("vbc_expired").expectProblem(http.StatusForbidden, api.ProblemForbidden, http.MethodGet, "/api/stops", "")
+
+	token := s.createToken(`{"name":"revoked","scopes":["stops:read"]}`)
+	s.as(token.Secret).expect(http.StatusOK, http.MethodGet, "/api/stops", "", nil)
+	s.expect(http.StatusNoContent, http.MethodDelete, fmt.Sprintf("/admin/tokens/%d", token.ID), "", nil)
+	s.as(token.Secret).expectProblem(http.StatusForbidden, api.ProblemForbidden, http.MethodGet, "/api/stops", "")
+}
+
+func TestTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+	token := s.createToken(`{"name":" bot ","scopes":["stops:read","users:write"],"providers":["telegram"],"expires_at":"` + expiresAt + `"}`)
+	if token.Secret == "" || token.Name != "bot" || len(token.Scopes) != 2 || len(token.Providers) != 1 || token.ExpiresAt == nil {
+		t.Fatalf("expected the created token, got %+v", token)
+	}
+
+	var list []api.Token
+	s.expect(http.StatusOK, http.MethodGet, "/admin/tokens", "", &list)
+	if len(list) != 1 || list[0].ID != token.ID || list[0].RevokedAt != nil {
+		t.Fatalf("expected the created token to be listed, got %+v", list)
+	}
+
+	// Revoking a token again has no effect
+	path := fmt.Sprintf("/admin/tokens/%d", token.ID)
+	s.expect(http.StatusNoContent, http.MethodDelete, path, "", nil)
+	s.expect(http.StatusNoContent, http.MethodDelete, path, "", nil)
+	s.expect(http.StatusOK, http.MethodGet, "/admin/tokens", "", &list)
+	if len(list) != 1 || list[0].RevokedAt == nil {
+		t.Fatalf("expected the token to be revoked, got %+v", list)
+	}
+
+	s.expectProblem(http.StatusNotFound, api.ProblemTokenNotFound, http.MethodDelete, "/admin/tokens/999", "")
+	s.expectProblem(http.StatusBadRequest, api.ProblemInvalidParameter, http.MethodDelete, "/admin/tokens/abc", "")
+}
+
+func TestCreateTokenErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		body string
+		code api.ProblemCode
+	}{
+		{`{"scopes":["stops:read"]}`, api.ProblemInvalidBody},
+		{`{"name":"  ","scopes":["stops:read"]}`, api.ProblemInvalidBody},
+		{`{"name":"bot","scopes":[]}`, api.ProblemInvalidScope},
+		{`{"name":"bot","scopes":["stops:write"]}`, api.ProblemInvalidScope},
+		{`{"name":"bot","scopes":["stops:read"],"providers":["myspace"]}`, api.ProblemUnknownProvider},
+		{`{"name":"bot","scopes":["stops:read"],"expires_at":"2020-01-01T00:00:00Z"}`, api.ProblemInvalidBody},
+	}
+	for _, test := range tests {
+		s.expectProblem(http.StatusBadRequest, test.code, http.MethodPost, "/admin/tokens", test.body)
+	}
+}
+
+func TestTokenProviders(t *testing.T) {
+	s := newTestServer(t)
+	telegram := s.as(s.createToken(`{"name":"telegram","scopes":["users:write"],"providers":["telegram"]}`).Secret)
+	unbound := s.as(s.createToken(`{"name":"unbound","scopes":["users:write"]}`).Secret)
+
+	telegram.createUser("telegram", "1")
+	telegram.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", nil)
+	telegram.expectProblem(http.StatusForbidden, api.ProblemProviderNotAllowed, http.MethodPost, "/api/users/discord/123456789012345678", "")
+	telegram.expectProblem(http.StatusForbidden, api.ProblemProviderNotAllowed, http.MethodGet, "/api/users/discord/123456789012345678", "")
+
+	unbound.createUser("discord", "123456789012345678")
+	unbound.expect(http.StatusOK, http.MethodGet, "/api/users/telegram/1", "", nil)
+}
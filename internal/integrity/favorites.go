@@ -0,0 +1,123 @@
+package integrity
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// replacementRadius is the distance in meters around a missing stop where a replacement is looked for
+const replacementRadius = 500
+
+// CheckFavorites lists the identities whose favorite stops no longer exist in the stops dataset,
+// suggesting a replacement for each of them. The dataset, if given, tells where the removed stops
+// were and what they were called
+func CheckFavorites(stops storage.StopRepository, identities storage.IdentityRepository, dataset storage.ReloadableDataset) (api.FavoritesIntegrity, error) {
+	report := api.FavoritesIntegrity{CheckedAt: time.Now(), Dangling: []api.DanglingFavorites{}}
+
+	allStops, err := stops.GetStops()
+	if err != nil {
+		return report, err
+	}
+	existing := make(map[int]bool, len(allStops))
+	for _, stop := range allStops {
+		existing[stop.StopNumber] = true
+	}
+
+	allIdentities, err := identities.ListIdentities()
+	if err != nil {
+		return report, err
+	}
+	report.Identities = len(allIdentities)
+
+	removals := make(map[int]api.DatasetChange)
+	if dataset != nil {
+		changes, err := dataset.DatasetChanges(0, time.Time{})
+		if err != nil {
+			return report, err
+		}
+		// The changes come oldest first, so the last removal of every stop is kept
+		for _, change := range changes.Changes {
+			if change.Entity == api.DatasetEntityStop && change.Action == api.DatasetChangeRemoved {
+				removals[change.StopNumber] = change
+			}
+		}
+	}
+
+	// Every missing stop is looked up once even if many identities have it as a favorite
+	missingStops := make(map[int]api.MissingFavorite)
+	for _, identity := range allIdentities {
+		dangling := api.DanglingFavorites{ID: identity.ID, UUID: identity.UUID, Provider: identity.Provider}
+		for _, favorite := range identity.FavoriteStops {
+			if existing[favorite.StopNumber] {
+				continue
+			}
+
+			missing, ok := missingStops[favorite.StopNumber]
+			if !ok {
+				missing = missingFavorite(stops, favorite.StopNumber, removals)
+				missingStops[favorite.StopNumber] = missing
+			}
+			dangling.Missing = append(dangling.Missing, missing)
+		}
+
+		if len(dangling.Missing) > 0 {
+			report.Dangling = append(report.Dangling, dangling)
+		}
+	}
+
+	return report, nil
+}
+
+// missingFavorite describes a missing stop and suggests the nearest stop to where it was, or the
+// stop with the closest name when no stop is near enough
+func missingFavorite(stops storage.StopRepository, stopNumber int, removals map[int]api.DatasetChange) api.MissingFavorite {
+	missing := api.MissingFavorite{StopNumber: stopNumber}
+
+	removal, ok := removals[stopNumber]
+	if !ok {
+		return missing
+	}
+	missing.PreviousName = removal.PreviousName
+	missing.PreviousLocation = removal.PreviousLocation
+	missing.RemovedInVersion = removal.Version
+
+	if location := removal.PreviousLocation; location != nil {
+		nearby, err := stops.FindStopsByLocation(location.Lat, location.Lon, replacementRadius, 1)
+		if err != nil {
+			log.Printf("Failed to find a replacement for stop %d: %v", stopNumber, err)
+		} else if len(nearby) > 0 {
+			missing.Replacement = &nearby[0].Stop
+			missing.ReplacementDistance = nearby[0].Distance
+			return missing
+		}
+	}
+
+	// Stop names usually are a street followed by a number, so only the street is looked for
+	if street, _, _ := strings.Cut(removal.PreviousName, ","); street != "" {
+		matches, err := stops.FindStopsByText(street, 1, 0)
+		if err != nil {
+			log.Printf("Failed to find a replacement for stop %d: %v", stopNumber, err)
+		} else if len(matches) > 0 {
+			missing.Replacement = &matches[0]
+		}
+	}
+
+	return missing
+}
+
+// LogFavorites checks the favorite stops and logs how many identities have missing ones
+func LogFavorites(stops storage.StopRepository, identities storage.IdentityRepository, dataset storage.ReloadableDataset) {
+	report, err := CheckFavorites(stops, identities, dataset)
+	if err != nil {
+		log.Printf("Failed to check favorite stops: %v", err)
+		return
+	}
+
+	if len(report.Dangling) > 0 {
+		log.Printf("Found %d of %d identities with favorite stops that no longer exist", len(report.Dangling), report.Identities)
+	}
+}
@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var (
+	// ErrNotObject is returned when the metadata is not a JSON object
+	ErrNotObject = errors.New("metadata must be a JSON object")
+
+	// ErrTooLarge is returned when the encoded metadata is bigger than the limit
+	ErrTooLarge = errors.New("metadata is too large")
+
+	// ErrSchemaViolation is returned when the metadata doesn't match the configured JSON Schema
+	ErrSchemaViolation = errors.New("metadata doesn't match the schema")
+)
+
+// Validator checks the metadata of the users against a size limit and, optionally, a JSON Schema
+type Validator struct {
+	// MaxBytes is the size limit of the encoded metadata
+	MaxBytes int
+
+	// schema is the JSON Schema the metadata must match, if any
+	schema *jsonschema.Schema
+}
+
+// NewValidator creates a Validator with the given size limit and the JSON Schema at the given path,
+// if it isn't empty
+func NewValidator(schemaPath string, maxBytes int) (*Validator, error) {
+	v := &Validator{MaxBytes: maxBytes}
+	if schemaPath == "" {
+		return v, nil
+	}
+
+	schema, err := jsonschema.Compile(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile metadata schema: %v", err)
+	}
+	v.schema = schema
+	return v, nil
+}
+
+// Validate checks that the metadata is within the size limit and matches the schema
+func (v *Validator) Validate(metadata map[string]any) error {
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %v", err)
+	}
+	if len(encoded) > v.MaxBytes {
+		return fmt.Errorf("%w, the limit is %d bytes", ErrTooLarge, v.MaxBytes)
+	}
+
+	if v.schema == nil {
+		return nil
+	}
+
+	// The schema is checked against the metadata as it will be stored
+	var value any
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return fmt.Errorf("failed to decode metadata: %v", err)
+	}
+	err = v.schema.Validate(value)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return fmt.Errorf("%w: %s", ErrSchemaViolation, strings.Join(violations(validationErr), "; "))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to validate metadata: %v", err)
+	}
+	return nil
+}
+
+// violations lists the innermost errors of a schema validation, along with where they happened,
+// leaving out the location of the schema file
+func violations(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{location + " " + err.Message}
+	}
+
+	var messages []string
+	for _, cause := range err.Causes {
+		messages = append(messages, violations(cause)...)
+	}
+	return messages
+}
+
+// Parse decodes a JSON object
+func Parse(body []byte) (map[string]any, error) {
+	var metadata map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if err := decoder.Decode(&metadata); err != nil || metadata == nil || decoder.More() {
+		return nil, ErrNotObject
+	}
+	return metadata, nil
+}
+
+// MergePatch applies a JSON merge patch to the metadata following RFC 7396: the keys of the patch
+// replace the ones of the metadata, objects are merged recursively and null values remove the keys
+func MergePatch(metadata, patch map[string]any) map[string]any {
+	return mergePatch(metadata, patch).(map[string]any)
+}
+
+// mergePatch applies a JSON merge patch to a value
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, _ := target.(map[string]any)
+	merged := make(map[string]any, len(targetObject)+len(patchObject))
+	for key, value := range targetObject {
+		merged[key] = value
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergePatch(merged[key], value)
+	}
+	return merged
+}
@@ -1,26 +1,102 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/eryalito/vigo-bus-core/internal/config"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/tokens"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		c.AbortWithStatus(http.StatusForbidden)
-		return
+// tokenKey is the context key holding the API token of the request
+const tokenKey = "token"
+
+// rootToken is the API token of the requests authenticated with the token set in the configuration
+var rootToken = api.Token{Name: "root", Scopes: []api.Scope{api.ScopeAdmin}}
+
+// NewAuthMiddleware creates a middleware that authenticates the requests with a bearer token. It is
+// either the token set in the configuration, which grants every scope, or an API token of the store
+// that is neither revoked nor expired
+func NewAuthMiddleware(store storage.TokenRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+			AbortWithProblem(c, http.StatusForbidden, api.ProblemForbidden, "Invalid or missing token")
+			return
+		}
+
+		secret := strings.TrimPrefix(authHeader, "Bearer ")
+		if config.Token != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(config.Token)) == 1 {
+			root := rootToken
+			c.Set(tokenKey, &root)
+			c.Next()
+			return
+		}
+
+		token, err := store.GetTokenByHash(tokens.Hash(secret))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if token == nil || !tokens.Active(token, time.Now()) {
+			AbortWithProblem(c, http.StatusForbidden, api.ProblemForbidden, "Invalid or missing token")
+			return
+		}
+
+		c.Set(tokenKey, token)
+		c.Next()
 	}
+}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if config.Token != token {
-		c.AbortWithStatus(http.StatusForbidden)
+// RequireScope creates a middleware that rejects the requests whose API token doesn't grant the scope
+func RequireScope(scope api.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := Token(c)
+		if token == nil || !tokens.Allows(token, scope) {
+			auditDenial(c, token, fmt.Sprintf("missing scope %s", scope))
+			AbortWithProblem(c, http.StatusForbidden, api.ProblemInsufficientScope, fmt.Sprintf("Token lacks the %s scope", scope))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireProvider rejects the requests to the users of a provider the API token isn't bound to
+func RequireProvider(c *gin.Context) {
+	provider := api.ProviderType(c.Param("provider"))
+
+	token := Token(c)
+	if token == nil || !tokens.AllowsProvider(token, provider) {
+		auditDenial(c, token, fmt.Sprintf("not bound to provider %s", provider))
+		AbortWithProblem(c, http.StatusForbidden, api.ProblemProviderNotAllowed, fmt.Sprintf("Token can't access the users of provider %s", provider))
 		return
 	}
 
 	c.Next()
 }
+
+// auditDenial logs a request rejected because of the permissions of its API token. The route is
+// logged instead of the path so the UUIDs of the users are left out
+func auditDenial(c *gin.Context, token *api.Token, reason string) {
+	client := "unauthenticated client"
+	if token != nil {
+		client = fmt.Sprintf("token %d (%s)", token.ID, token.Name)
+	}
+	log.Printf("Audit: denied %s %s to %s from %s, %s, request %s", c.Request.Method, c.FullPath(), client, c.ClientIP(), reason, RequestID(c))
+}
+
+// Token returns the API token that authenticated the request, or nil if it wasn't authenticated
+func Token(c *gin.Context) *api.Token {
+	token, _ := c.Value(tokenKey).(*api.Token)
+	return token
+}
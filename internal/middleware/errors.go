@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of the error responses
+const problemContentType = "application/problem+json"
+
+// HTTPError is an error caused by the request or an external service, reported with the given
+// HTTP status, problem code and message. The underlying error, if any, is only logged
+type HTTPError struct {
+	Status  int
+	Code    api.ProblemCode
+	Message string
+	Err     error
+}
+
+// NewHTTPError creates an HTTPError with the given status, code and message
+func NewHTTPError(status int, code api.ProblemCode, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+// Error returns the message of the error along with the underlying one
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// notFoundErrors maps the not-found errors of the repositories to the problems returned to the client
+var notFoundErrors = map[error]*HTTPError{
+	storage.ErrStopNotFound:  NewHTTPError(http.StatusNotFound, api.ProblemStopNotFound, "Stop not found"),
+	storage.ErrLineNotFound:  NewHTTPError(http.StatusNotFound, api.ProblemLineNotFound, "Line not found"),
+	storage.ErrTokenNotFound: NewHTTPError(http.StatusNotFound, api.ProblemTokenNotFound, "Token not found"),
+}
+
+// ErrorMiddleware writes the last error added to the context by the handler as a problem response,
+// with a 404 status for missing stops, lines and tokens, the status of HTTPErrors and 500 for the rest.
+// Unexpected errors are logged along with the request ID instead of being sent to the client
+func ErrorMiddleware(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last().Err
+	httpErr := httpError(err)
+	if httpErr.Status >= http.StatusInternalServerError {
+		log.Printf("Request %s failed: %v", RequestID(c), err)
+	}
+
+	AbortWithProblem(c, httpErr.Status, httpErr.Code, httpErr.Message)
+}
+
+// httpError maps an error to the HTTPError reported to the client
+func httpError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	for notFound, httpErr := range notFoundErrors {
+		if errors.Is(err, notFound) {
+			return httpErr
+		}
+	}
+
+	return NewHTTPError(http.StatusInternalServerError, api.ProblemInternalError, "Internal error, quote the request ID when reporting it")
+}
+
+// AbortWithProblem stops the request with a problem response
+func AbortWithProblem(c *gin.Context, status int, code api.ProblemCode, detail string) {
+	problem := api.NewProblem(status, code, detail)
+	problem.Instance = c.Request.URL.Path
+	problem.RequestID = RequestID(c)
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
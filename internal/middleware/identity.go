@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/eryalito/vigo-bus-core/internal/providers"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdentityMiddleware rejects the requests whose provider path parameter is not supported or
+// whose uuid path parameter doesn't follow the format of the provider
+func IdentityMiddleware(c *gin.Context) {
+	err := providers.Validate(c.Param("provider"), c.Param("uuid"))
+	if errors.Is(err, providers.ErrUnknownProvider) {
+		AbortWithProblem(c, http.StatusBadRequest, api.ProblemUnknownProvider, "Unknown provider, see /api/providers for the supported ones")
+		return
+	}
+	if err != nil {
+		AbortWithProblem(c, http.StatusBadRequest, api.ProblemInvalidUUID, err.Error())
+		return
+	}
+
+	c.Next()
+}
@@ -6,6 +6,8 @@ import (
 
 	"fmt"
 
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
@@ -35,7 +37,7 @@ func RateLimiterMiddleware(r rate.Limit, b int) gin.HandlerFunc {
 		limiter := getLimiter(ip)
 
 		if !limiter.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			AbortWithProblem(c, http.StatusTooManyRequests, api.ProblemRateLimited, "Too many requests")
 			return
 		}
 
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header that carries the ID of every request
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key holding the ID of the request
+const requestIDKey = "request_id"
+
+// validRequestID matches the request IDs accepted from the clients
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIDMiddleware assigns an ID to every request, reusing the one sent by the client if valid,
+// and returns it in the X-Request-ID header
+func RequestIDMiddleware(c *gin.Context) {
+	id := c.GetHeader(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+
+	c.Set(requestIDKey, id)
+	c.Header(RequestIDHeader, id)
+	c.Next()
+}
+
+// RequestID returns the ID of the request
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,250 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// fileNamePattern matches the migration file names, such as 0001_create_identities.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered change to the schema of a database that can be undone
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status tells whether a migration has been applied to the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts the migrations of a database, recording the applied
+// ones in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// lock is run at the start of every migration transaction to stop other processes
+	// from migrating the same database at the same time
+	lock string
+}
+
+// New creates a Migrator with the migrations found in the file system. Every migration is made
+// of a NNNN_name.up.sql and a NNNN_name.down.sql file
+func New(db *sql.DB, fsys fs.FS, lock string) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, lock: lock}, nil
+}
+
+// load reads the migrations from the file system sorted by version
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// createTable creates the schema_migrations table if it doesn't exist
+func (m *Migrator) createTable() error {
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TEXT NOT NULL
+    );`
+	if _, err := m.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// Status retrieves every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		applied[version], _ = time.Parse(time.RFC3339, appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order, returning how many were applied
+func (m *Migrator) Up() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+
+		applied, err := m.run(status.Migration, true)
+		if err != nil {
+			return count, err
+		}
+		if applied {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Down reverts the given number of applied migrations, newest first, returning how many were reverted
+func (m *Migrator) Down(steps int) (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(statuses) - 1; i >= 0 && count < steps; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+
+		reverted, err := m.run(statuses[i].Migration, false)
+		if err != nil {
+			return count, err
+		}
+		if reverted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// run applies or reverts a migration in a transaction, returning false if another process
+// already did it
+func (m *Migrator) run(migration Migration, up bool) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if m.lock != "" {
+		if _, err := tx.Exec(m.lock); err != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("failed to lock schema_migrations table: %v", err)
+		}
+	}
+
+	var applied int
+	if err := tx.QueryRow(`SELECT count(*) FROM schema_migrations WHERE version = $1`, migration.Version).Scan(&applied); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	if (applied > 0) == up {
+		tx.Rollback()
+		return false, nil
+	}
+
+	query, record := migration.Down, `DELETE FROM schema_migrations WHERE version = $1`
+	args := []any{migration.Version}
+	if up {
+		query, record = migration.Up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`
+		args = append(args, migration.Name, time.Now().UTC().Format(time.RFC3339))
+	}
+
+	if _, err := tx.Exec(query); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to run migration %d_%s: %v", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to record migration %d_%s: %v", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if up {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	} else {
+		log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+	}
+	return true, nil
+}
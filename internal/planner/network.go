@@ -0,0 +1,248 @@
+package planner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/config"
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// maxFootpathDistance is the longest walk in meters precomputed between two stops
+const maxFootpathDistance = 500
+
+// Params are the assumptions used to estimate travel times over the network
+type Params struct {
+	// BusSpeed is the average speed of a bus between two stops in meters per second
+	BusSpeed float64
+
+	// Dwell is the time a bus spends at each stop
+	Dwell time.Duration
+
+	// Wait is the average time spent waiting for a bus when boarding it
+	Wait time.Duration
+
+	// TransferPenalty is the extra time added every time the rider changes buses
+	TransferPenalty time.Duration
+
+	// MaxAccessWalk is the longest walk in meters from the origin to the first stop and from the last stop to the destination
+	MaxAccessWalk float64
+
+	// MaxTransferWalk is the longest walk in meters between two stops when changing buses
+	MaxTransferWalk float64
+}
+
+// DefaultParams returns the parameters set in the configuration
+func DefaultParams() Params {
+	return Params{
+		BusSpeed:        config.Planner.BusSpeed / 3.6,
+		Dwell:           time.Duration(config.Planner.Dwell) * time.Second,
+		Wait:            time.Duration(config.Planner.Wait) * time.Second,
+		TransferPenalty: time.Duration(config.Planner.TransferPenalty) * time.Second,
+		MaxAccessWalk:   1000,
+		MaxTransferWalk: 300,
+	}
+}
+
+// rideTime returns the seconds needed to ride a route between two positions
+func (p Params) rideTime(r *route, from, to int) float64 {
+	return (r.distances[to]-r.distances[from])/p.BusSpeed + float64(to-from)*p.Dwell.Seconds()
+}
+
+// route is a line route with its stops as indexes of the network stops
+type route struct {
+	line      api.Line
+	direction int
+	stops     []int
+
+	// distances holds the distance in meters from the first stop of the route to every stop
+	distances []float64
+}
+
+// routeStop is a stop position within a route
+type routeStop struct {
+	route    int
+	position int
+}
+
+// footpath is a walk from a stop to a nearby one
+type footpath struct {
+	to       int
+	distance float64
+}
+
+// Network is the graph of stops and line routes used to plan trips
+type Network struct {
+	stops      []api.Stop
+	stopIndex  map[int]int
+	routes     []route
+	stopRoutes [][]routeStop
+	footpaths  [][]footpath
+}
+
+// LoadNetwork builds the network from the stops and lines repositories
+func LoadNetwork(stopRepository storage.StopRepository, lineRepository storage.LineRepository) (*Network, error) {
+	stops, err := stopRepository.GetStops()
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := lineRepository.GetLines()
+	if err != nil {
+		return nil, err
+	}
+
+	lineStops, err := lineRepository.GetLineStops()
+	if err != nil {
+		return nil, err
+	}
+
+	lineRoutes, err := lineRepository.GetLineRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	network := NewNetwork(stops, lines, lineStops, lineRoutes)
+	if len(network.routes) == 0 {
+		return nil, fmt.Errorf("no line routes found")
+	}
+
+	return network, nil
+}
+
+// NewNetwork builds the network from the stops and line routes. Lines without a known stop order
+// get an approximated one in both directions, chaining their stops from one end to the other
+func NewNetwork(stops []api.Stop, lines []api.Line, lineStops map[int][]api.Stop, lineRoutes []api.LineRoute) *Network {
+	n := &Network{
+		stops:      stops,
+		stopIndex:  make(map[int]int, len(stops)),
+		stopRoutes: make([][]routeStop, len(stops)),
+		footpaths:  make([][]footpath, len(stops)),
+	}
+
+	for i, stop := range stops {
+		n.stopIndex[stop.ID] = i
+	}
+
+	routed := make(map[int]bool)
+	for _, lineRoute := range lineRoutes {
+		routed[lineRoute.Line.ID] = true
+		n.addRoute(lineRoute)
+	}
+
+	for _, line := range lines {
+		if routed[line.ID] || len(lineStops[line.ID]) < 2 {
+			continue
+		}
+
+		ordered := approximateOrder(lineStops[line.ID])
+		n.addRoute(api.LineRoute{Line: line, Direction: 0, Stops: ordered})
+
+		reversed := make([]api.Stop, len(ordered))
+		for i, stop := range ordered {
+			reversed[len(ordered)-1-i] = stop
+		}
+		n.addRoute(api.LineRoute{Line: line, Direction: 1, Stops: reversed})
+	}
+
+	n.buildFootpaths()
+
+	return n
+}
+
+// addRoute adds a line route to the network, skipping the stops that are not in it
+func (n *Network) addRoute(lineRoute api.LineRoute) {
+	r := route{line: lineRoute.Line, direction: lineRoute.Direction}
+	for _, stop := range lineRoute.Stops {
+		index, ok := n.stopIndex[stop.ID]
+		if !ok {
+			continue
+		}
+
+		distance := 0.0
+		if len(r.stops) > 0 {
+			previous := n.stops[r.stops[len(r.stops)-1]]
+			distance = r.distances[len(r.distances)-1] + geo.Distance(previous.Location.Lat, previous.Location.Lon, stop.Location.Lat, stop.Location.Lon)
+		}
+		r.stops = append(r.stops, index)
+		r.distances = append(r.distances, distance)
+	}
+
+	if len(r.stops) < 2 {
+		return
+	}
+
+	n.routes = append(n.routes, r)
+	for position, stop := range r.stops {
+		n.stopRoutes[stop] = append(n.stopRoutes[stop], routeStop{route: len(n.routes) - 1, position: position})
+	}
+}
+
+// buildFootpaths precomputes the walks between every pair of stops closer than maxFootpathDistance
+func (n *Network) buildFootpaths() {
+	// Sorting by latitude allows to only compare the stops within the latitude range of the walk
+	order := make([]int, len(n.stops))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return n.stops[order[i]].Location.Lat < n.stops[order[j]].Location.Lat
+	})
+
+	latRange := maxFootpathDistance / geo.EarthRadius * 180 / math.Pi
+	for i, a := range order {
+		for _, b := range order[i+1:] {
+			stopA, stopB := n.stops[a], n.stops[b]
+			if stopB.Location.Lat-stopA.Location.Lat > latRange {
+				break
+			}
+
+			distance := geo.Distance(stopA.Location.Lat, stopA.Location.Lon, stopB.Location.Lat, stopB.Location.Lon)
+			if distance <= maxFootpathDistance {
+				n.footpaths[a] = append(n.footpaths[a], footpath{to: b, distance: distance})
+				n.footpaths[b] = append(n.footpaths[b], footpath{to: a, distance: distance})
+			}
+		}
+	}
+}
+
+// approximateOrder sorts the stops of a line whose order is unknown, starting at the stop farthest
+// from the center of the line and moving every time to the nearest stop not visited yet
+func approximateOrder(stops []api.Stop) []api.Stop {
+	var centerLat, centerLon float64
+	for _, stop := range stops {
+		centerLat += stop.Location.Lat / float64(len(stops))
+		centerLon += stop.Location.Lon / float64(len(stops))
+	}
+
+	current, farthest := 0, -1.0
+	for i, stop := range stops {
+		if distance := geo.Distance(centerLat, centerLon, stop.Location.Lat, stop.Location.Lon); distance > farthest {
+			current, farthest = i, distance
+		}
+	}
+
+	visited := make([]bool, len(stops))
+	ordered := make([]api.Stop, 0, len(stops))
+	for len(ordered) < len(stops) {
+		visited[current] = true
+		ordered = append(ordered, stops[current])
+
+		next, nearest := -1, math.Inf(1)
+		for i, stop := range stops {
+			if visited[i] {
+				continue
+			}
+			if distance := geo.Distance(stops[current].Location.Lat, stops[current].Location.Lon, stop.Location.Lat, stop.Location.Lon); distance < nearest {
+				next, nearest = i, distance
+			}
+		}
+		current = next
+	}
+
+	return ordered
+}
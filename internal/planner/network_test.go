@@ -0,0 +1,163 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// testParams are round numbers to make the expected times easy to follow
+var testParams = Params{
+	BusSpeed:        5,
+	Dwell:           20 * time.Second,
+	Wait:            5 * time.Minute,
+	TransferPenalty: 2 * time.Minute,
+	MaxAccessWalk:   1000,
+	MaxTransferWalk: 300,
+}
+
+func testStop(id int, lat, lon float64) api.Stop {
+	return api.Stop{ID: id, StopNumber: id, Location: api.Coordinates{Lat: lat, Lon: lon}}
+}
+
+// newTestNetwork builds a network with two lines going east, C1 through stops 1, 2 and 3 and L5
+// through stops 4, 5 and 6, where 3 and 4 are a short walk apart. Stops are about 1650 meters apart
+// along each line. Line 10 has no known order and goes through stops 7, 8 and 9 further north
+func newTestNetwork() *Network {
+	stops := []api.Stop{
+		testStop(1, 42.2, -8.70),
+		testStop(2, 42.2, -8.68),
+		testStop(3, 42.2, -8.66),
+		testStop(4, 42.201, -8.66),
+		testStop(5, 42.201, -8.64),
+		testStop(6, 42.201, -8.62),
+		testStop(7, 42.25, -8.70),
+		testStop(8, 42.25, -8.69),
+		testStop(9, 42.25, -8.68),
+	}
+	c1 := api.Line{ID: 1, Name: "C1"}
+	l5 := api.Line{ID: 2, Name: "L5"}
+	l10 := api.Line{ID: 3, Name: "10"}
+
+	lineStops := map[int][]api.Stop{
+		c1.ID:  {stops[0], stops[1], stops[2]},
+		l5.ID:  {stops[3], stops[4], stops[5]},
+		l10.ID: {stops[7], stops[8], stops[6]},
+	}
+	lineRoutes := []api.LineRoute{
+		{Line: c1, Direction: 0, Stops: lineStops[c1.ID]},
+		{Line: l5, Direction: 0, Stops: lineStops[l5.ID]},
+	}
+
+	return NewNetwork(stops, []api.Line{c1, l5, l10}, lineStops, lineRoutes)
+}
+
+// routeStopIDs returns the IDs of the stops of every route of a line by direction
+func routeStopIDs(n *Network, lineID int) map[int][]int {
+	ids := make(map[int][]int)
+	for _, r := range n.routes {
+		if r.line.ID != lineID {
+			continue
+		}
+		for _, stop := range r.stops {
+			ids[r.direction] = append(ids[r.direction], n.stops[stop].ID)
+		}
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewNetwork(t *testing.T) {
+	n := newTestNetwork()
+
+	tests := []struct {
+		name   string
+		lineID int
+		want   map[int][]int
+	}{
+		{"known order", 1, map[int][]int{0: {1, 2, 3}}},
+		{"known order in a single direction", 2, map[int][]int{0: {4, 5, 6}}},
+		{"approximated order in both directions", 3, map[int][]int{0: {7, 8, 9}, 1: {9, 8, 7}}},
+	}
+	for _, test := range tests {
+		got := routeStopIDs(n, test.lineID)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: expected routes %v, got %v", test.name, test.want, got)
+			continue
+		}
+
+		// The approximated order may start at either end of the line
+		if len(got) == 2 && equalIDs(got[0], test.want[1]) {
+			got[0], got[1] = got[1], got[0]
+		}
+		for direction, want := range test.want {
+			if !equalIDs(got[direction], want) {
+				t.Errorf("%s: expected direction %d to be %v, got %v", test.name, direction, want, got[direction])
+			}
+		}
+	}
+}
+
+func TestApproximateOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		stops []api.Stop
+		want  [][]int
+	}{
+		{
+			name:  "shuffled stops along a street",
+			stops: []api.Stop{testStop(2, 42.2, -8.69), testStop(4, 42.2, -8.67), testStop(1, 42.2, -8.70), testStop(3, 42.2, -8.68)},
+			want:  [][]int{{1, 2, 3, 4}, {4, 3, 2, 1}},
+		},
+		{
+			name:  "two stops",
+			stops: []api.Stop{testStop(1, 42.2, -8.70), testStop(2, 42.21, -8.70)},
+			want:  [][]int{{1, 2}, {2, 1}},
+		},
+	}
+	for _, test := range tests {
+		var got []int
+		for _, stop := range approximateOrder(test.stops) {
+			got = append(got, stop.ID)
+		}
+
+		// Either end of the line is a valid start
+		if !equalIDs(got, test.want[0]) && !equalIDs(got, test.want[1]) {
+			t.Errorf("%s: expected %v in either direction, got %v", test.name, test.want[0], got)
+		}
+	}
+}
+
+func TestFootpaths(t *testing.T) {
+	n := newTestNetwork()
+
+	for i, paths := range n.footpaths {
+		var ids []int
+		for _, path := range paths {
+			ids = append(ids, n.stops[path.to].ID)
+		}
+
+		var want []int
+		switch n.stops[i].ID {
+		case 3:
+			want = []int{4}
+		case 4:
+			want = []int{3}
+		}
+		if !equalIDs(ids, want) {
+			t.Errorf("expected footpaths from stop %d to %v, got %v", n.stops[i].ID, want, ids)
+		}
+	}
+}
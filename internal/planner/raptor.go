@@ -0,0 +1,332 @@
+package planner
+
+import (
+	"math"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// labelKind tells how a stop was reached in a round
+type labelKind int
+
+const (
+	labelAccess labelKind = iota
+	labelRide
+	labelWalk
+)
+
+// label records how a stop was reached in a round so the itinerary can be rebuilt
+type label struct {
+	kind labelKind
+
+	// from is the stop where the bus was boarded for rides, or where the walk started for walks
+	from int
+
+	// route, boardPos and alightPos describe the ride
+	route     int
+	boardPos  int
+	alightPos int
+}
+
+// search holds the state of a RAPTOR search over a frequency-based timetable, where every
+// round adds one more bus ride. Arrivals are measured in seconds since the search starts
+type search struct {
+	n      *Network
+	params Params
+
+	// arrivals and labels hold, for every round, the arrival at each stop and how it was reached
+	arrivals [][]float64
+	labels   [][]label
+
+	// best holds the earliest arrival at each stop over all the rounds
+	best []float64
+
+	// limit discards every arrival later than it
+	limit float64
+}
+
+// newSearch prepares a search of up to the given number of rides
+func (n *Network) newSearch(rides int, params Params) *search {
+	s := &search{
+		n:        n,
+		params:   params,
+		arrivals: make([][]float64, rides+1),
+		labels:   make([][]label, rides+1),
+		best:     make([]float64, len(n.stops)),
+		limit:    math.Inf(1),
+	}
+	for k := range s.arrivals {
+		s.arrivals[k] = make([]float64, len(n.stops))
+		s.labels[k] = make([]label, len(n.stops))
+		for i := range s.arrivals[k] {
+			s.arrivals[k][i] = math.Inf(1)
+		}
+	}
+	for i := range s.best {
+		s.best[i] = math.Inf(1)
+	}
+
+	return s
+}
+
+// access sets the arrival at a stop before taking any bus
+func (s *search) access(stop int, arrival float64) {
+	if arrival < s.best[stop] {
+		s.arrivals[0][stop] = arrival
+		s.best[stop] = arrival
+		s.labels[0][stop] = label{kind: labelAccess}
+	}
+}
+
+// reached reports whether a stop was reached in a round
+func (s *search) reached(k, stop int) bool {
+	return !math.IsInf(s.arrivals[k][stop], 1)
+}
+
+// improve records a new arrival at a stop in a round if it is earlier than the best one so far,
+// returning whether the stop has to be marked because it was reached for the first time in the round
+func (s *search) improve(k, stop int, arrival float64, l label) bool {
+	if arrival >= s.best[stop] || arrival >= s.limit {
+		return false
+	}
+
+	first := !s.reached(k, stop)
+	s.arrivals[k][stop] = arrival
+	s.best[stop] = arrival
+	s.labels[k][stop] = l
+	return first
+}
+
+// round rides every route that goes through the stops marked in the previous round and then walks
+// to the stops nearby, returning the stops whose arrival improved
+func (s *search) round(k int, marked []int) []int {
+	n, params := s.n, s.params
+
+	// Every route is scanned from the first position where one of the marked stops is
+	queue := make(map[int]int)
+	for _, stop := range marked {
+		for _, rs := range n.stopRoutes[stop] {
+			if position, ok := queue[rs.route]; !ok || rs.position < position {
+				queue[rs.route] = rs.position
+			}
+		}
+	}
+
+	boardPenalty := params.Wait.Seconds()
+	if k > 1 {
+		boardPenalty += params.TransferPenalty.Seconds()
+	}
+
+	var improved []int
+	for routeIndex, start := range queue {
+		r := &n.routes[routeIndex]
+		boarded, boardTime := -1, math.Inf(1)
+		for position := start; position < len(r.stops); position++ {
+			stop := r.stops[position]
+			if boarded >= 0 {
+				arrival := boardTime + params.rideTime(r, boarded, position)
+				l := label{kind: labelRide, from: r.stops[boarded], route: routeIndex, boardPos: boarded, alightPos: position}
+				if s.improve(k, stop, arrival, l) {
+					improved = append(improved, stop)
+				}
+			}
+
+			if !s.reached(k-1, stop) {
+				continue
+			}
+			board := s.arrivals[k-1][stop] + boardPenalty
+			if boarded < 0 || board < boardTime+params.rideTime(r, boarded, position) {
+				boarded, boardTime = position, board
+			}
+		}
+	}
+
+	// Walk from the stops reached by bus to the ones nearby to change buses
+	rideArrivals := make(map[int]float64, len(improved))
+	for _, stop := range improved {
+		rideArrivals[stop] = s.arrivals[k][stop]
+	}
+	for stop, arrival := range rideArrivals {
+		for _, path := range n.footpaths[stop] {
+			if path.distance > params.MaxTransferWalk {
+				continue
+			}
+			walked := arrival + path.distance/geo.WalkingSpeed
+			if s.improve(k, path.to, walked, label{kind: labelWalk, from: stop}) {
+				improved = append(improved, path.to)
+			}
+		}
+	}
+
+	return improved
+}
+
+// Plan finds the fastest itineraries between two locations using at most maxTransfers bus changes.
+// It follows the RAPTOR algorithm, returning an itinerary for each number of rides that arrives
+// earlier than the ones with fewer rides
+func (n *Network) Plan(from, to api.Coordinates, departAt time.Time, maxTransfers int, params Params) []api.Itinerary {
+	s := n.newSearch(maxTransfers+1, params)
+
+	var itineraries []api.Itinerary
+
+	// Walking all the way is an option when the destination is close enough
+	directDistance := geo.Distance(from.Lat, from.Lon, to.Lat, to.Lon)
+	if directDistance <= 2*params.MaxAccessWalk {
+		s.limit = directDistance / geo.WalkingSpeed
+		itineraries = append(itineraries, n.walkingItinerary(from, to, departAt, directDistance))
+	}
+
+	// Round 0 walks from the origin to every stop close enough
+	var marked []int
+	egress := make([]float64, len(n.stops))
+	for i, stop := range n.stops {
+		if distance := geo.Distance(from.Lat, from.Lon, stop.Location.Lat, stop.Location.Lon); distance <= params.MaxAccessWalk {
+			s.access(i, distance/geo.WalkingSpeed)
+			marked = append(marked, i)
+		}
+		egress[i] = math.Inf(1)
+		if distance := geo.Distance(stop.Location.Lat, stop.Location.Lon, to.Lat, to.Lon); distance <= params.MaxAccessWalk {
+			egress[i] = distance
+		}
+	}
+
+	for k := 1; k < len(s.arrivals) && len(marked) > 0; k++ {
+		marked = s.round(k, marked)
+
+		// Check whether getting off at any of the stops reaches the destination earlier
+		target, targetStop := math.Inf(1), -1
+		for _, stop := range marked {
+			if arrival := s.arrivals[k][stop] + egress[stop]/geo.WalkingSpeed; arrival < target {
+				target, targetStop = arrival, stop
+			}
+		}
+		if targetStop >= 0 && target < s.limit {
+			s.limit = target
+			itineraries = append(itineraries, n.buildItinerary(from, to, departAt, k, targetStop, s, egress[targetStop]))
+		}
+	}
+
+	return itineraries
+}
+
+// walkingItinerary builds an itinerary that walks straight from the origin to the destination
+func (n *Network) walkingItinerary(from, to api.Coordinates, departAt time.Time, distance float64) api.Itinerary {
+	leg := walkLeg(api.Place{Location: from}, api.Place{Location: to}, departAt, 0, distance)
+	return api.Itinerary{
+		Departure:       departAt,
+		Arrival:         leg.Arrival,
+		Duration:        leg.Duration,
+		WalkingDistance: math.Round(distance),
+		Legs:            []api.Leg{leg},
+		TransferStops:   []api.Stop{},
+	}
+}
+
+// buildItinerary follows the labels back from the last stop of a round to rebuild the itinerary
+func (n *Network) buildItinerary(from, to api.Coordinates, departAt time.Time, round, lastStop int, s *search, egressDistance float64) api.Itinerary {
+	arrivalAtStop := s.arrivals[round][lastStop]
+	legs := []api.Leg{walkLeg(n.stopPlace(lastStop), api.Place{Location: to}, departAt, arrivalAtStop, egressDistance)}
+
+	stop, k := lastStop, round
+	for {
+		l := s.labels[k][stop]
+		switch l.kind {
+		case labelAccess:
+			origin := n.stops[stop]
+			distance := geo.Distance(from.Lat, from.Lon, origin.Location.Lat, origin.Location.Lon)
+			legs = append(legs, walkLeg(api.Place{Location: from}, n.stopPlace(stop), departAt, 0, distance))
+		case labelWalk:
+			start, end := n.stops[l.from], n.stops[stop]
+			distance := geo.Distance(start.Location.Lat, start.Location.Lon, end.Location.Lat, end.Location.Lon)
+			legs = append(legs, walkLeg(n.stopPlace(l.from), n.stopPlace(stop), departAt, s.arrivals[k][l.from], distance))
+		case labelRide:
+			legs = append(legs, n.busLeg(l, departAt, s.arrivals[k][stop], s.params))
+			k--
+		}
+
+		if l.kind == labelAccess {
+			break
+		}
+		stop = l.from
+	}
+
+	// The legs were collected from the destination backwards
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+
+	itinerary := api.Itinerary{
+		Departure:     departAt,
+		Arrival:       legs[len(legs)-1].Arrival,
+		Legs:          legs,
+		TransferStops: []api.Stop{},
+	}
+	itinerary.Duration = minutes(itinerary.Arrival.Sub(departAt).Seconds())
+
+	for _, leg := range legs {
+		switch leg.Mode {
+		case api.LegModeWalk:
+			itinerary.WalkingDistance += leg.Distance
+		case api.LegModeBus:
+			if itinerary.Transfers++; itinerary.Transfers > 1 {
+				itinerary.TransferStops = append(itinerary.TransferStops, *leg.From.Stop)
+			}
+		}
+	}
+	itinerary.Transfers--
+
+	return itinerary
+}
+
+// busLeg builds the leg of a ride that gets off at the given time
+func (n *Network) busLeg(l label, departAt time.Time, alightTime float64, params Params) api.Leg {
+	r := &n.routes[l.route]
+	boardTime := alightTime - params.rideTime(r, l.boardPos, l.alightPos)
+	line := r.line
+
+	leg := api.Leg{
+		Mode:      api.LegModeBus,
+		From:      n.stopPlace(r.stops[l.boardPos]),
+		To:        n.stopPlace(r.stops[l.alightPos]),
+		Line:      &line,
+		Departure: departAt.Add(seconds(boardTime)),
+		Arrival:   departAt.Add(seconds(alightTime)),
+		Duration:  minutes(alightTime - boardTime),
+		Distance:  math.Round(r.distances[l.alightPos] - r.distances[l.boardPos]),
+	}
+	for _, stop := range r.stops[l.boardPos : l.alightPos+1] {
+		leg.Stops = append(leg.Stops, n.stops[stop])
+	}
+
+	return leg
+}
+
+// walkLeg builds a walking leg that starts the given seconds after the departure
+func walkLeg(from, to api.Place, departAt time.Time, start, distance float64) api.Leg {
+	duration := distance / geo.WalkingSpeed
+	return api.Leg{
+		Mode:      api.LegModeWalk,
+		From:      from,
+		To:        to,
+		Departure: departAt.Add(seconds(start)),
+		Arrival:   departAt.Add(seconds(start + duration)),
+		Duration:  minutes(duration),
+		Distance:  math.Round(distance),
+	}
+}
+
+// stopPlace returns the place of a stop of the network
+func (n *Network) stopPlace(index int) api.Place {
+	stop := n.stops[index]
+	return api.Place{Location: stop.Location, Stop: &stop}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+func minutes(s float64) int {
+	return int(math.Round(s / 60))
+}
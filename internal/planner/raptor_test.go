@@ -0,0 +1,154 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// legSummary is the part of a leg checked by the tests
+type legSummary struct {
+	mode api.LegMode
+	line string
+	from int
+	to   int
+}
+
+func summarize(legs []api.Leg) []legSummary {
+	summaries := make([]legSummary, 0, len(legs))
+	for _, leg := range legs {
+		summary := legSummary{mode: leg.Mode}
+		if leg.Line != nil {
+			summary.line = leg.Line.Name
+		}
+		if leg.From.Stop != nil {
+			summary.from = leg.From.Stop.ID
+		}
+		if leg.To.Stop != nil {
+			summary.to = leg.To.Stop.ID
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func TestPlan(t *testing.T) {
+	n := newTestNetwork()
+	departAt := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	location := func(stop int) api.Coordinates {
+		return n.stops[n.stopIndex[stop]].Location
+	}
+
+	tests := []struct {
+		name         string
+		from, to     api.Coordinates
+		maxTransfers int
+
+		// want holds the legs of every itinerary found, from fewer to more rides
+		want      [][]legSummary
+		transfers []int
+	}{
+		{
+			name:         "single line",
+			from:         location(1),
+			to:           location(3),
+			maxTransfers: 2,
+			want: [][]legSummary{{
+				{mode: api.LegModeWalk, to: 1},
+				{mode: api.LegModeBus, line: "C1", from: 1, to: 3},
+				{mode: api.LegModeWalk, from: 3},
+			}},
+			transfers: []int{0},
+		},
+		{
+			name:         "transfer walking between stops",
+			from:         location(1),
+			to:           location(6),
+			maxTransfers: 1,
+			want: [][]legSummary{{
+				{mode: api.LegModeWalk, to: 1},
+				{mode: api.LegModeBus, line: "C1", from: 1, to: 3},
+				{mode: api.LegModeWalk, from: 3, to: 4},
+				{mode: api.LegModeBus, line: "L5", from: 4, to: 6},
+				{mode: api.LegModeWalk, from: 6},
+			}},
+			transfers: []int{1},
+		},
+		{
+			name:         "transfer over the limit",
+			from:         location(1),
+			to:           location(6),
+			maxTransfers: 0,
+		},
+		{
+			name:         "against the direction of the route",
+			from:         location(3),
+			to:           location(1),
+			maxTransfers: 2,
+		},
+		{
+			name:         "walking is faster",
+			from:         location(1),
+			to:           api.Coordinates{Lat: 42.2, Lon: -8.695},
+			maxTransfers: 2,
+			want:         [][]legSummary{{{mode: api.LegModeWalk}}},
+			transfers:    []int{0},
+		},
+	}
+	for _, test := range tests {
+		itineraries := n.Plan(test.from, test.to, departAt, test.maxTransfers, testParams)
+		if len(itineraries) != len(test.want) {
+			t.Errorf("%s: expected %d itineraries, got %d", test.name, len(test.want), len(itineraries))
+			continue
+		}
+
+		for i, itinerary := range itineraries {
+			legs := summarize(itinerary.Legs)
+			if len(legs) != len(test.want[i]) {
+				t.Errorf("%s: expected legs %+v, got %+v", test.name, test.want[i], legs)
+				continue
+			}
+			for j := range legs {
+				if legs[j] != test.want[i][j] {
+					t.Errorf("%s: expected leg %d to be %+v, got %+v", test.name, j, test.want[i][j], legs[j])
+				}
+			}
+			if itinerary.Transfers != test.transfers[i] || len(itinerary.TransferStops) != test.transfers[i] {
+				t.Errorf("%s: expected %d transfers, got %d at %+v", test.name, test.transfers[i], itinerary.Transfers, itinerary.TransferStops)
+			}
+			if !itinerary.Arrival.Equal(itinerary.Legs[len(itinerary.Legs)-1].Arrival) {
+				t.Errorf("%s: expected the itinerary to arrive with its last leg at %s, got %s", test.name, itinerary.Legs[len(itinerary.Legs)-1].Arrival, itinerary.Arrival)
+			}
+		}
+	}
+}
+
+func TestPlanTimes(t *testing.T) {
+	n := newTestNetwork()
+	departAt := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	from, to := n.stops[0].Location, n.stops[2].Location
+
+	itineraries := n.Plan(from, to, departAt, 0, testParams)
+	if len(itineraries) != 1 || len(itineraries[0].Legs) != 3 {
+		t.Fatalf("expected a single itinerary riding C1, got %+v", itineraries)
+	}
+
+	// The bus is boarded after the average wait and stops once at stop 2 before getting off
+	distance := geo.Distance(from.Lat, from.Lon, n.stops[1].Location.Lat, n.stops[1].Location.Lon) +
+		geo.Distance(n.stops[1].Location.Lat, n.stops[1].Location.Lon, to.Lat, to.Lon)
+	board := departAt.Add(testParams.Wait)
+	arrival := board.Add(seconds(distance/testParams.BusSpeed + 2*testParams.Dwell.Seconds()))
+
+	bus := itineraries[0].Legs[1]
+	if !bus.Departure.Equal(board) || !bus.Arrival.Equal(arrival) {
+		t.Errorf("expected the bus from %s to %s, got from %s to %s", board, arrival, bus.Departure, bus.Arrival)
+	}
+	if len(bus.Stops) != 3 {
+		t.Errorf("expected the bus to go through 3 stops, got %+v", bus.Stops)
+	}
+	if !itineraries[0].Arrival.Equal(arrival) {
+		t.Errorf("expected to arrive at %s, got %s", arrival, itineraries[0].Arrival)
+	}
+}
@@ -0,0 +1,64 @@
+package planner
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// Reachable finds the stops that can be reached from a stop within the time budget using at most
+// maxTransfers bus changes, sorted by travel time. It returns false when the stop is not in the network
+func (n *Network) Reachable(stopID int, budget time.Duration, maxTransfers int, params Params) ([]api.ReachableStop, bool) {
+	origin, ok := n.stopIndex[stopID]
+	if !ok {
+		return nil, false
+	}
+
+	s := n.newSearch(maxTransfers+1, params)
+	s.limit = math.Nextafter(budget.Seconds(), math.Inf(1))
+
+	// Round 0 starts at the stop and the ones close enough to walk to
+	s.access(origin, 0)
+	marked := []int{origin}
+	for _, path := range n.footpaths[origin] {
+		if path.distance <= params.MaxTransferWalk && path.distance/geo.WalkingSpeed < s.limit {
+			s.access(path.to, path.distance/geo.WalkingSpeed)
+			marked = append(marked, path.to)
+		}
+	}
+
+	for k := 1; k < len(s.arrivals) && len(marked) > 0; k++ {
+		marked = s.round(k, marked)
+	}
+
+	var reachable []api.ReachableStop
+	for i, arrival := range s.best {
+		if i == origin || math.IsInf(arrival, 1) {
+			continue
+		}
+
+		// The round where the best arrival was found is the number of rides taken
+		rides := 0
+		for k := range s.arrivals {
+			if s.arrivals[k][i] == arrival {
+				rides = k
+				break
+			}
+		}
+
+		reachable = append(reachable, api.ReachableStop{
+			Stop:      n.stops[i],
+			Minutes:   int(math.Ceil(arrival / 60)),
+			Transfers: max(rides-1, 0),
+		})
+	}
+
+	sort.SliceStable(reachable, func(i, j int) bool {
+		return reachable[i].Minutes < reachable[j].Minutes
+	})
+
+	return reachable, true
+}
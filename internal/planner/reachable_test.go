@@ -0,0 +1,94 @@
+package planner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReachable(t *testing.T) {
+	n := newTestNetwork()
+
+	// reached is a stop with the minutes to reach it and the transfers made
+	type reached struct {
+		stop, minutes, transfers int
+	}
+
+	tests := []struct {
+		name         string
+		stopID       int
+		budget       time.Duration
+		maxTransfers int
+		want         []reached
+	}{
+		{
+			name:         "first stop only",
+			stopID:       1,
+			budget:       15 * time.Minute,
+			maxTransfers: 1,
+			want:         []reached{{2, 11, 0}},
+		},
+		{
+			name:         "walking to the stop of another line",
+			stopID:       1,
+			budget:       20 * time.Minute,
+			maxTransfers: 1,
+			want:         []reached{{2, 11, 0}, {3, 17, 0}, {4, 19, 0}},
+		},
+		{
+			name:         "no transfers allowed",
+			stopID:       1,
+			budget:       40 * time.Minute,
+			maxTransfers: 0,
+			want:         []reached{{2, 11, 0}, {3, 17, 0}, {4, 19, 0}},
+		},
+		{
+			name:         "changing buses",
+			stopID:       1,
+			budget:       40 * time.Minute,
+			maxTransfers: 1,
+			want:         []reached{{2, 11, 0}, {3, 17, 0}, {4, 19, 0}, {5, 31, 1}, {6, 37, 1}},
+		},
+		{
+			name:         "boarding at a stop nearby",
+			stopID:       3,
+			budget:       40 * time.Minute,
+			maxTransfers: 0,
+			want:         []reached{{4, 2, 0}, {5, 13, 0}, {6, 19, 0}},
+		},
+		{
+			name:         "end of the line",
+			stopID:       6,
+			budget:       time.Hour,
+			maxTransfers: 2,
+		},
+	}
+	for _, test := range tests {
+		stops, ok := n.Reachable(test.stopID, test.budget, test.maxTransfers, testParams)
+		if !ok {
+			t.Errorf("%s: expected stop %d to be in the network", test.name, test.stopID)
+			continue
+		}
+
+		got := make([]reached, 0, len(stops))
+		for _, stop := range stops {
+			got = append(got, reached{stop.Stop.ID, stop.Minutes, stop.Transfers})
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: expected %+v, got %+v", test.name, test.want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestReachableUnknownStop(t *testing.T) {
+	n := newTestNetwork()
+
+	if _, ok := n.Reachable(999, time.Hour, 1, testParams); ok {
+		t.Error("expected an unknown stop not to be in the network")
+	}
+}
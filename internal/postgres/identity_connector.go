@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/eryalito/vigo-bus-core/internal/migrate"
+	"github.com/eryalito/vigo-bus-core/internal/sqlstore"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+var _ storage.IdentityDatabase = (*IdentityConnector)(nil)
+
+// IdentityConnector is a struct that holds the identity database connection
+type IdentityConnector struct {
+	*sqlstore.IdentityStore
+}
+
+// identityMaxOpenConns is the size of the identity database connection pool of every replica
+const identityMaxOpenConns = 10
+
+// dialect is how the identity store talks to PostgreSQL. Accounts are locked with a row lock, as
+// replicas run their transactions concurrently
+var dialect = sqlstore.Dialect{
+	Placeholder:       sqlstore.DollarPlaceholder,
+	LockAccount:       `SELECT a.id FROM accounts a JOIN identities i ON i.account_id = a.id WHERE i.id = ? FOR UPDATE OF a`,
+	IsUniqueViolation: isUniqueViolation,
+}
+
+// NewIdentityConnector creates a new IdentityConnector given the database URL
+func NewIdentityConnector(url string) (*IdentityConnector, error) {
+	db, err := openDatabase(url, identityMaxOpenConns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdentityConnector{IdentityStore: &sqlstore.IdentityStore{DB: db, Dialect: dialect}}, nil
+}
+
+// identityMigrations holds the schema changes of the identity database
+//
+//go:embed migrations/identity/*.sql
+var identityMigrations embed.FS
+
+// Migrator returns the migrator of the identity database schema
+func (c *IdentityConnector) Migrator() (*migrate.Migrator, error) {
+	migrations, err := fs.Sub(identityMigrations, "migrations/identity")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+	return migrate.New(c.DB, migrations, `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`)
+}
+
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// isUniqueViolation tells whether an error was caused by a unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
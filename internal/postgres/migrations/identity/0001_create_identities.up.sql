@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS identities (
+    id SERIAL PRIMARY KEY,
+    uuid TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    metadata TEXT
+);
+
+CREATE TABLE IF NOT EXISTS favorite_stops (
+    identity_id INTEGER REFERENCES identities(id),
+    stop_number INTEGER
+);
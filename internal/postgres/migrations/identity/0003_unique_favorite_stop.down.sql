@@ -0,0 +1 @@
+DROP INDEX favorite_stops_identity_stop;
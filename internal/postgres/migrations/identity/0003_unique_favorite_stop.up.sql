@@ -0,0 +1,4 @@
+DELETE FROM favorite_stops a USING favorite_stops b
+WHERE a.ctid > b.ctid AND a.identity_id = b.identity_id AND a.stop_number = b.stop_number;
+
+CREATE UNIQUE INDEX favorite_stops_identity_stop ON favorite_stops (identity_id, stop_number);
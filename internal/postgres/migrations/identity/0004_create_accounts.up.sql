@@ -0,0 +1,20 @@
+CREATE TABLE accounts (
+    id SERIAL PRIMARY KEY,
+    created_at TEXT NOT NULL
+);
+
+-- Every existing identity gets its own account, with the same ID
+INSERT INTO accounts (id, created_at)
+SELECT id, to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') FROM identities;
+SELECT setval(pg_get_serial_sequence('accounts', 'id'), COALESCE(max(id), 0) + 1, false) FROM accounts;
+
+ALTER TABLE identities ADD COLUMN account_id INTEGER REFERENCES accounts(id);
+UPDATE identities SET account_id = id;
+CREATE INDEX identities_account ON identities (account_id);
+
+CREATE TABLE link_codes (
+    code TEXT PRIMARY KEY,
+    identity_id INTEGER NOT NULL REFERENCES identities(id),
+    expires_at TEXT NOT NULL
+);
+CREATE INDEX link_codes_identity ON link_codes (identity_id);
@@ -0,0 +1,8 @@
+-- The identities waiting to be purged can't be told apart anymore, so they are purged right away
+DELETE FROM favorite_stops WHERE identity_id IN (SELECT id FROM identities WHERE deleted_at IS NOT NULL);
+DELETE FROM link_codes WHERE identity_id IN (SELECT id FROM identities WHERE deleted_at IS NOT NULL);
+DELETE FROM identities WHERE deleted_at IS NOT NULL;
+DELETE FROM accounts WHERE id NOT IN (SELECT account_id FROM identities);
+
+DROP INDEX identities_deleted_at;
+ALTER TABLE identities DROP COLUMN deleted_at;
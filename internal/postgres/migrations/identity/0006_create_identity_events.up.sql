@@ -0,0 +1,8 @@
+CREATE TABLE identity_events (
+    id SERIAL PRIMARY KEY,
+    identity_id INTEGER NOT NULL REFERENCES identities(id),
+    action TEXT NOT NULL,
+    detail TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+CREATE INDEX identity_events_identity ON identity_events (identity_id);
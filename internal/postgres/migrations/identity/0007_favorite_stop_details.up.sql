@@ -0,0 +1,10 @@
+ALTER TABLE favorite_stops ADD COLUMN alias TEXT NOT NULL DEFAULT '';
+ALTER TABLE favorite_stops ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE favorite_stops ADD COLUMN lines TEXT NOT NULL DEFAULT '[]';
+
+-- The existing favorites keep the order in which they were added
+UPDATE favorite_stops SET position = ordered.position
+FROM (
+    SELECT ctid, row_number() OVER (PARTITION BY identity_id ORDER BY ctid) - 1 AS position FROM favorite_stops
+) ordered
+WHERE favorite_stops.ctid = ordered.ctid;
@@ -0,0 +1,2 @@
+DROP TABLE favorite_routes;
+DROP TABLE favorite_lines;
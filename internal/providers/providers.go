@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var (
+	// ErrUnknownProvider is returned when the identity provider is not supported
+	ErrUnknownProvider = errors.New("unknown provider")
+
+	// ErrInvalidUUID is returned when the UUID doesn't follow the format of its provider
+	ErrInvalidUUID = errors.New("invalid uuid")
+)
+
+// provider is a supported identity provider along with the rule its UUIDs must follow
+type provider struct {
+	api.Provider
+	pattern *regexp.Regexp
+}
+
+// newProvider creates a provider whose UUIDs must match the pattern
+func newProvider(providerType api.ProviderType, name, format, pattern string) provider {
+	return provider{
+		Provider: api.Provider{Type: providerType, Name: name, UUIDFormat: format, UUIDPattern: pattern},
+		pattern:  regexp.MustCompile(pattern),
+	}
+}
+
+// registry holds the supported identity providers
+var registry = []provider{
+	newProvider(api.ProviderTypeTelegram, "Telegram", "Numeric user or chat ID", `^-?[0-9]{1,20}$`),
+	newProvider(api.ProviderTypeDiscord, "Discord", "Numeric snowflake user ID", `^[0-9]{17,20}$`),
+	newProvider(api.ProviderTypeMatrix, "Matrix", "Fully qualified user ID, such as @rider:matrix.org", `^@[a-z0-9._=/+-]+:[A-Za-z0-9.-]+(:[0-9]{1,5})?$`),
+	newProvider(api.ProviderTypeWeb, "Web", "RFC 4122 UUID", `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+	newProvider(api.ProviderTypeWhatsApp, "WhatsApp", "Phone number in international format, without the leading +", `^[1-9][0-9]{6,14}$`),
+}
+
+// List returns the supported identity providers
+func List() []api.Provider {
+	providers := make([]api.Provider, len(registry))
+	for i, p := range registry {
+		providers[i] = p.Provider
+	}
+	return providers
+}
+
+// Supported tells whether the provider is supported
+func Supported(providerType api.ProviderType) bool {
+	for _, p := range registry {
+		if p.Type == providerType {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the provider is supported and the UUID follows its format
+func Validate(providerType, uuid string) error {
+	for _, p := range registry {
+		if string(p.Type) != providerType {
+			continue
+		}
+		if !p.pattern.MatchString(uuid) {
+			return fmt.Errorf("%w for provider %s: %s", ErrInvalidUUID, providerType, p.UUIDFormat)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownProvider, providerType)
+}
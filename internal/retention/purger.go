@@ -0,0 +1,50 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+)
+
+// Purger deletes for good the users that were deleted longer ago than the retention period
+type Purger struct {
+	// Identities is the repository of users that gets purged
+	Identities storage.IdentityRepository
+
+	// Retention is how long the deleted users are kept before being purged
+	Retention time.Duration
+}
+
+// NewPurger creates a Purger that keeps the deleted users for the given retention period
+func NewPurger(identities storage.IdentityRepository, retention time.Duration) *Purger {
+	return &Purger{Identities: identities, Retention: retention}
+}
+
+// Run purges the deleted users right away and then every interval, until the context is done
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(); err != nil {
+			log.Printf("Failed to purge deleted users: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes for good the users deleted before the retention period, returning how many were purged
+func (p *Purger) Purge() (int, error) {
+	count, err := p.Identities.PurgeDeletedIdentities(time.Now().Add(-p.Retention))
+	if count > 0 {
+		log.Printf("Purged %d deleted users", count)
+	}
+	return count, err
+}
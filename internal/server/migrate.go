@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+)
+
+// Migrate runs the migrate subcommand over the identity database: up applies every pending
+// migration, down [steps] reverts the given number of migrations, one by default, and status
+// lists the migrations and whether they are applied
+func Migrate(args []string) error {
+	identity, err := openIdentityDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open identity database: %v", err)
+	}
+	defer identity.Close()
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(identity)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrateDown(identity, steps)
+	case "status":
+		return migrateStatus(identity)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// migrateUp applies the pending migrations of the identity database
+func migrateUp(identity storage.IdentityDatabase) error {
+	migrator, err := identity.Migrator()
+	if err != nil {
+		return err
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		return fmt.Errorf("failed to migrate identity database: %v", err)
+	}
+	return nil
+}
+
+// migrateDown reverts the given number of migrations of the identity database
+func migrateDown(identity storage.IdentityDatabase, steps int) error {
+	migrator, err := identity.Migrator()
+	if err != nil {
+		return err
+	}
+
+	if _, err := migrator.Down(steps); err != nil {
+		return fmt.Errorf("failed to revert identity database migrations: %v", err)
+	}
+	return nil
+}
+
+// migrateStatus prints the migrations of the identity database and when they were applied
+func migrateStatus(identity storage.IdentityDatabase) error {
+	migrator, err := identity.Migrator()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/config"
+	"github.com/eryalito/vigo-bus-core/internal/dataset"
+	"github.com/eryalito/vigo-bus-core/internal/handlers"
+	"github.com/eryalito/vigo-bus-core/internal/integrity"
+	"github.com/eryalito/vigo-bus-core/internal/metadata"
+	"github.com/eryalito/vigo-bus-core/internal/middleware"
+	"github.com/eryalito/vigo-bus-core/internal/postgres"
+	"github.com/eryalito/vigo-bus-core/internal/retention"
+	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"golang.org/x/time/rate"
+)
+
+// shutdownTimeout is how long the in-flight requests are waited for when the server stops
+const shutdownTimeout = 10 * time.Second
+
+// purgeInterval is how often the users deleted before the retention period are purged
+const purgeInterval = time.Hour
+
+// Server is the application, holding the database connections shared by every request
+type Server struct {
+	// Bus is the connection to the stops and lines database, reloaded when the file is replaced
+	Bus *sqlite.ReloadableBusConnector
+
+	// Identity is the connection to the users database
+	Identity storage.IdentityDatabase
+
+	// Router is the HTTP router with every endpoint registered
+	Router *gin.Engine
+}
+
+// New opens the databases set in the configuration and registers the endpoints
+func New() (*Server, error) {
+	validator, err := metadata.NewValidator(config.Metadata.SchemaPath, config.Metadata.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	bus, err := sqlite.NewReloadableBusConnector(config.StopsDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stops database: %v", err)
+	}
+
+	identity, err := openIdentityDatabase()
+	if err != nil {
+		bus.Close()
+		return nil, fmt.Errorf("failed to open identity database: %v", err)
+	}
+
+	if config.AutoMigrate {
+		if err := migrateUp(identity); err != nil {
+			bus.Close()
+			identity.Close()
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		Bus:      bus,
+		Identity: identity,
+		Router:   gin.Default(),
+	}
+
+	h := handlers.NewHandler(bus, bus, identity)
+	h.Dataset = bus
+	h.Tokens = identity
+	h.Metadata = validator
+	bus.OnReload(h.ResetNetwork)
+	bus.OnReload(func() {
+		go integrity.LogFavorites(bus, identity, bus)
+	})
+	s.registerRoutes(h)
+
+	return s, nil
+}
+
+// openIdentityDatabase opens the users database with the configured driver
+func openIdentityDatabase() (storage.IdentityDatabase, error) {
+	switch config.IdentityDBDriver {
+	case "sqlite":
+		return sqlite.NewIdentityConnector(config.IdentityDBPath)
+	case "postgres":
+		return postgres.NewIdentityConnector(config.IdentityDBURL)
+	default:
+		return nil, fmt.Errorf("unknown identity database driver %q", config.IdentityDBDriver)
+	}
+}
+
+// registerRoutes adds every endpoint to the router
+func (s *Server) registerRoutes(h *handlers.Handler) {
+	r := s.Router
+
+	// Swagger endpoint (no auth middleware)
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Identify every request and map the errors left by the handlers to problem responses
+	r.Use(middleware.RequestIDMiddleware)
+	r.Use(middleware.ErrorMiddleware)
+
+	// Apply rate limiter middleware to all routes
+	r.Use(middleware.RateLimiterMiddleware(rate.Limit(config.RateLimiter.Limit), config.RateLimiter.Burst))
+
+	// API endpoints, authenticated with the configured token or an API token with the scope of each group
+	handlers.RegisterRoutes(r, h, middleware.NewAuthMiddleware(s.Identity))
+}
+
+// Run serves the requests on the configured port until the process is interrupted,
+// then waits for the in-flight requests to finish
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Addr:    ":" + config.Port,
+		Handler: s.Router,
+	}
+
+	go integrity.LogFavorites(s.Bus, s.Identity, s.Bus)
+
+	if config.StopsDBWatch > 0 {
+		go s.Bus.Watch(ctx, time.Duration(config.StopsDBWatch)*time.Second)
+	}
+
+	go retention.NewPurger(s.Identity, time.Duration(config.UserRetention)*24*time.Hour).Run(ctx, purgeInterval)
+
+	if config.Dataset.UpdateInterval > 0 {
+		go dataset.NewUpdater(config.Dataset.URL, config.Dataset.MoveThreshold, s.Bus).Run(ctx, time.Duration(config.Dataset.UpdateInterval)*time.Second)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down server: %v", err)
+	}
+
+	return nil
+}
+
+// Close closes the database connections
+func (s *Server) Close() error {
+	return errors.Join(s.Bus.Close(), s.Identity.Close())
+}
@@ -2,32 +2,75 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"math"
+	"log"
 
-	"github.com/eryalito/vigo-bus-core/internal/config"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
 	"github.com/eryalito/vigo-bus-core/pkg/api"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var (
+	_ storage.StopRepository = (*BusConnector)(nil)
+	_ storage.LineRepository = (*BusConnector)(nil)
+)
+
+var (
+	// ErrStopNotFound is returned when no stop has the requested stop number
+	ErrStopNotFound = storage.ErrStopNotFound
+
+	// ErrLineNotFound is returned when no line has the requested name
+	ErrLineNotFound = storage.ErrLineNotFound
+)
+
 // BusConnector is a struct that holds the database connection
 type BusConnector struct {
 	DB *sql.DB
+
+	// searchEnabled tells whether the full-text search index is available
+	searchEnabled bool
+
+	// locationIndexEnabled tells whether the spatial index is available
+	locationIndexEnabled bool
 }
 
+// busMaxOpenConns is the size of the bus database connection pool
+const busMaxOpenConns = 8
+
 // NewBusConnector initializes a new database given a path
-func NewBusConnector() (*BusConnector, error) {
-	db, err := sql.Open("sqlite3", config.StopsDBPath)
+func NewBusConnector(path string) (*BusConnector, error) {
+	db, err := openDatabase(path, busMaxOpenConns)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, err
 	}
 
 	connector := &BusConnector{DB: db}
 	if err := connector.initialize(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize database: %v", err)
 	}
 
+	// Only a build without FTS5 falls back to plain text matching, any other failure would
+	// silently change the search results
+	err = connector.buildSearchIndex()
+	switch {
+	case errors.Is(err, errSearchUnavailable):
+		log.Printf("Full-text search unavailable, falling back to plain text matching: %v", err)
+	case err != nil:
+		db.Close()
+		return nil, fmt.Errorf("failed to build search index: %v", err)
+	default:
+		connector.searchEnabled = true
+	}
+
+	if err := connector.buildLocationIndex(); err != nil {
+		log.Printf("Spatial index unavailable, falling back to full table scans: %v", err)
+	} else {
+		connector.locationIndexEnabled = true
+	}
+
 	return connector, nil
 }
 
@@ -58,6 +101,17 @@ func (c *BusConnector) initialize() error {
         FOREIGN KEY (stop_id) REFERENCES stops(id)
     );`
 
+	createLineStopSequencesTable := `
+    CREATE TABLE IF NOT EXISTS line_stop_sequences (
+        line_id INTEGER,
+        direction INTEGER,
+        sequence INTEGER,
+        stop_id INTEGER,
+        PRIMARY KEY (line_id, direction, sequence),
+        FOREIGN KEY (line_id) REFERENCES lines(id),
+        FOREIGN KEY (stop_id) REFERENCES stops(id)
+    );`
+
 	_, err := c.DB.Exec(createLinesTable)
 	if err != nil {
 		return fmt.Errorf("failed to create lines table: %v", err)
@@ -73,6 +127,44 @@ func (c *BusConnector) initialize() error {
 		return fmt.Errorf("failed to create line_stops table: %v", err)
 	}
 
+	_, err = c.DB.Exec(createLineStopSequencesTable)
+	if err != nil {
+		return fmt.Errorf("failed to create line_stop_sequences table: %v", err)
+	}
+
+	return c.createDatasetTables()
+}
+
+// validate checks the integrity of the database and that it holds stops and lines
+func (c *BusConnector) validate() error {
+	var result string
+	if err := c.DB.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return fmt.Errorf("failed to check database integrity: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("database integrity check failed: %s", result)
+	}
+
+	for _, table := range []string{"stops", "lines"} {
+		var count int
+		if err := c.DB.QueryRow(`SELECT count(*) FROM ` + table).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count %s: %v", table, err)
+		}
+		if count == 0 {
+			return fmt.Errorf("no %s found", table)
+		}
+	}
+
+	return nil
+}
+
+// dropIndexes deletes the full-text search and spatial indexes, which are built again when the database is opened
+func (c *BusConnector) dropIndexes() error {
+	for _, table := range []string{"stops_search_vocab", "stops_search", "stops_location"} {
+		if _, err := c.DB.Exec(`DROP TABLE IF EXISTS ` + table); err != nil {
+			return fmt.Errorf("failed to drop %s table: %v", table, err)
+		}
+	}
 	return nil
 }
 
@@ -119,6 +211,17 @@ func (c *BusConnector) AddStopToLine(lineID, stopID int) error {
 	return nil
 }
 
+// AddStopToLineRoute adds a stop at the given position of a line route in the line_stop_sequences table
+func (c *BusConnector) AddStopToLineRoute(lineID, direction, sequence, stopID int) error {
+	insertQuery := `INSERT INTO line_stop_sequences (line_id, direction, sequence, stop_id) VALUES (?, ?, ?, ?)`
+	_, err := c.DB.Exec(insertQuery, lineID, direction, sequence, stopID)
+	if err != nil {
+		return fmt.Errorf("failed to add stop to line route: %v", err)
+	}
+
+	return nil
+}
+
 // GetLines retrieves all lines from the lines table
 func (c *BusConnector) GetLines() ([]api.Line, error) {
 	query := `SELECT id, name FROM lines`
@@ -140,19 +243,80 @@ func (c *BusConnector) GetLines() ([]api.Line, error) {
 	return lines, nil
 }
 
-// GetLineByName retrieves a line from the lines table by name
+// GetLineByName retrieves a line from the lines table by name, or ErrLineNotFound if it doesn't exist
 func (c *BusConnector) GetLineByName(name string) (api.Line, error) {
 	query := `SELECT id, name FROM lines WHERE name = ?`
 	row := c.DB.QueryRow(query, name)
 
 	var line api.Line
 	if err := row.Scan(&line.ID, &line.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return api.Line{}, fmt.Errorf("%w: %s", ErrLineNotFound, name)
+		}
 		return api.Line{}, fmt.Errorf("failed to scan row: %v", err)
 	}
 
 	return line, nil
 }
 
+// GetLineStops retrieves the stops served by every line, indexed by line ID
+func (c *BusConnector) GetLineStops() (map[int][]api.Stop, error) {
+	query := `
+    SELECT ls.line_id, s.id, s.stop_number, s.stop_id, s.name, s.lat, s.lon
+    FROM line_stops ls
+    JOIN stops s ON s.id = ls.stop_id
+    ORDER BY ls.line_id, s.id`
+	rows, err := c.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query line stops: %v", err)
+	}
+	defer rows.Close()
+
+	lineStops := make(map[int][]api.Stop)
+	for rows.Next() {
+		var lineID int
+		var stop api.Stop
+		if err := rows.Scan(&lineID, &stop.ID, &stop.StopNumber, &stop.StopID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		lineStops[lineID] = append(lineStops[lineID], stop)
+	}
+
+	return lineStops, nil
+}
+
+// GetLineRoutes retrieves the ordered stops of every line route stored in the line_stop_sequences table
+func (c *BusConnector) GetLineRoutes() ([]api.LineRoute, error) {
+	query := `
+    SELECT l.id, l.name, seq.direction, s.id, s.stop_number, s.stop_id, s.name, s.lat, s.lon
+    FROM line_stop_sequences seq
+    JOIN lines l ON l.id = seq.line_id
+    JOIN stops s ON s.id = seq.stop_id
+    ORDER BY seq.line_id, seq.direction, seq.sequence`
+	rows, err := c.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query line routes: %v", err)
+	}
+	defer rows.Close()
+
+	var routes []api.LineRoute
+	for rows.Next() {
+		var line api.Line
+		var direction int
+		var stop api.Stop
+		if err := rows.Scan(&line.ID, &line.Name, &direction, &stop.ID, &stop.StopNumber, &stop.StopID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		if len(routes) == 0 || routes[len(routes)-1].Line.ID != line.ID || routes[len(routes)-1].Direction != direction {
+			routes = append(routes, api.LineRoute{Line: line, Direction: direction})
+		}
+		routes[len(routes)-1].Stops = append(routes[len(routes)-1].Stops, stop)
+	}
+
+	return routes, nil
+}
+
 // GetStops retrieves all stops from the stops table
 func (c *BusConnector) GetStops() ([]api.Stop, error) {
 	query := `SELECT id, stop_number, stop_id, name, lat, lon FROM stops`
@@ -174,23 +338,31 @@ func (c *BusConnector) GetStops() ([]api.Stop, error) {
 	return stops, nil
 }
 
-// GetStopByNumber retrieves a stop from the stops table by stop number
+// GetStopByNumber retrieves a stop from the stops table by stop number, or ErrStopNotFound if it doesn't exist
 func (c *BusConnector) GetStopByNumber(stopNumber int) (api.Stop, error) {
 	query := `SELECT id, stop_number, stop_id, name, lat, lon FROM stops WHERE stop_number = ?`
 	row := c.DB.QueryRow(query, stopNumber)
 
 	var stop api.Stop
 	if err := row.Scan(&stop.ID, &stop.StopNumber, &stop.StopID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon); err != nil {
+		if err == sql.ErrNoRows {
+			return api.Stop{}, fmt.Errorf("%w: %d", ErrStopNotFound, stopNumber)
+		}
 		return api.Stop{}, fmt.Errorf("failed to scan row: %v", err)
 	}
 
 	return stop, nil
 }
 
-// FindStopsByText retrieves a stop from the stops table by text matching a stop name
-func (c *BusConnector) FindStopsByText(text string) ([]api.Stop, error) {
-	query := `SELECT id, stop_number, stop_id, name, lat, lon FROM stops WHERE name LIKE ?`
-	rows, err := c.DB.Query(query, "%"+text+"%")
+// FindStopsByText retrieves stops from the stops table whose name matches the text, ordered by relevance.
+// Matching ignores case and diacritics and tolerates prefixes and small typos. A limit of 0 returns every match
+func (c *BusConnector) FindStopsByText(text string, limit, offset int) ([]api.Stop, error) {
+	if c.searchEnabled {
+		return c.searchStops(text, limit, offset)
+	}
+
+	query := `SELECT id, stop_number, stop_id, name, lat, lon FROM stops WHERE name LIKE ? ORDER BY name LIMIT ? OFFSET ?`
+	rows, err := c.DB.Query(query, "%"+text+"%", sqlLimit(limit), offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query stops: %v", err)
 	}
@@ -208,30 +380,19 @@ func (c *BusConnector) FindStopsByText(text string) ([]api.Stop, error) {
 	return stops, nil
 }
 
-// FindStopsByLocation retrieves stops from the stops table within a given radius around a location in meters
-func (c *BusConnector) FindStopsByLocation(lat, lon, radius float64) ([]api.Stop, error) {
-	query := `SELECT id, stop_number, stop_id, name, lat, lon FROM stops`
-	rows, err := c.DB.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query stops: %v", err)
+// FindStopsByLocation retrieves stops from the stops table within a given radius around a location in meters,
+// sorted from nearest to farthest. A limit of 0 returns every stop within the radius
+func (c *BusConnector) FindStopsByLocation(lat, lon, radius float64, limit int) ([]api.NearbyStop, error) {
+	if c.locationIndexEnabled {
+		return c.nearestStops(lat, lon, radius, limit)
 	}
-	defer rows.Close()
 
-	var stops []api.Stop
-	for rows.Next() {
-		var stop api.Stop
-		if err := rows.Scan(&stop.ID, &stop.StopNumber, &stop.StopID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
-		}
-
-		// Calculate the distance using the Haversine formula
-		distance := haversine(lat, lon, stop.Location.Lat, stop.Location.Lon)
-		if distance <= radius {
-			stops = append(stops, stop)
-		}
+	stops, err := c.GetStops()
+	if err != nil {
+		return nil, err
 	}
 
-	return stops, nil
+	return storage.NearestStops(lat, lon, radius, limit, stops), nil
 }
 
 // Close closes the database connection
@@ -241,20 +402,3 @@ func (c *BusConnector) Close() error {
 	}
 	return nil
 }
-
-// Haversine formula to calculate the distance between two points in meters
-func haversine(lat1, lon1, lat2, lon2 float64) float64 {
-	const R = 6371000 // Radius of the Earth in meters
-	lat1Rad := lat1 * math.Pi / 180
-	lon1Rad := lon1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	lon2Rad := lon2 * math.Pi / 180
-
-	dlat := lat2Rad - lat1Rad
-	dlon := lon2Rad - lon1Rad
-
-	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dlon/2)*math.Sin(dlon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-
-	return R * c
-}
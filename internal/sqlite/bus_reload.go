@@ -0,0 +1,368 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var (
+	_ storage.StopRepository = (*ReloadableBusConnector)(nil)
+	_ storage.LineRepository = (*ReloadableBusConnector)(nil)
+)
+
+// ErrInvalidDataset is returned when a reloaded or updated database file fails the integrity and content checks
+var ErrInvalidDataset = storage.ErrInvalidDataset
+
+// ReloadableBusConnector serves the stops and lines from a snapshot of the database at a path,
+// replacing it with a new snapshot whenever the file is reloaded. Every query holds a read lock,
+// so the swap waits for the in-flight ones and the old snapshot is never closed under them
+type ReloadableBusConnector struct {
+	path string
+
+	mu       sync.RWMutex
+	current  *BusConnector
+	snapshot string
+	loadedAt time.Time
+
+	// modTime and size identify the version of the file that was last loaded
+	modTime time.Time
+	size    int64
+
+	// reloadMu makes the reloads run one at a time
+	reloadMu sync.Mutex
+	onReload []func()
+
+	// updateMu makes the updates run one at a time
+	updateMu sync.Mutex
+}
+
+// datasetVersionsLimit is the number of latest dataset versions described by Dataset
+const datasetVersionsLimit = 10
+
+// NewReloadableBusConnector loads the bus database at the given path
+func NewReloadableBusConnector(path string) (*ReloadableBusConnector, error) {
+	c := &ReloadableBusConnector{path: path}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OnReload registers a function that is called after every successful reload
+func (c *ReloadableBusConnector) OnReload(f func()) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	c.onReload = append(c.onReload, f)
+}
+
+// Reload copies the database file to a new snapshot, validates it and switches to it. The current
+// snapshot keeps serving the requests if the new one can't be loaded
+func (c *ReloadableBusConnector) Reload() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat stops database: %v", err)
+	}
+
+	// SQLite can't open the file while it is being replaced, so a private copy is served instead
+	snapshot, err := copySnapshot(c.path)
+	if err != nil {
+		return err
+	}
+
+	connector, err := NewBusConnector(snapshot)
+	if err != nil {
+		removeSnapshot(snapshot)
+		return err
+	}
+
+	if err := connector.validate(); err != nil {
+		connector.Close()
+		removeSnapshot(snapshot)
+		return fmt.Errorf("%w: %v", ErrInvalidDataset, err)
+	}
+
+	c.mu.Lock()
+	previous, previousSnapshot := c.current, c.snapshot
+	c.current, c.snapshot, c.loadedAt = connector, snapshot, time.Now()
+	c.modTime, c.size = info.ModTime(), info.Size()
+	c.mu.Unlock()
+
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			log.Printf("Failed to close previous stops database: %v", err)
+		}
+		removeSnapshot(previousSnapshot)
+		log.Printf("Reloaded stops database from %s", c.path)
+	}
+
+	for _, f := range c.onReload {
+		f()
+	}
+	return nil
+}
+
+// Watch checks the database file every interval and reloads it when it is replaced, until the
+// context is done. Failed reloads are logged and retried only when the file changes again
+func (c *ReloadableBusConnector) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var failedModTime time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(c.path)
+		if err != nil {
+			continue
+		}
+
+		c.mu.RLock()
+		changed := !info.ModTime().Equal(c.modTime) || info.Size() != c.size
+		c.mu.RUnlock()
+		if !changed || info.ModTime().Equal(failedModTime) {
+			continue
+		}
+
+		if err := c.Reload(); err != nil {
+			log.Printf("Failed to reload stops database: %v", err)
+			failedModTime = info.ModTime()
+		}
+	}
+}
+
+// Dataset retrieves the size of the loaded dataset and when it was loaded
+func (c *ReloadableBusConnector) Dataset() (api.Dataset, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	dataset := api.Dataset{LoadedAt: c.loadedAt}
+	if err := c.current.DB.QueryRow(`SELECT count(*) FROM stops`).Scan(&dataset.Stops); err != nil {
+		return api.Dataset{}, fmt.Errorf("failed to count stops: %v", err)
+	}
+	if err := c.current.DB.QueryRow(`SELECT count(*) FROM lines`).Scan(&dataset.Lines); err != nil {
+		return api.Dataset{}, fmt.Errorf("failed to count lines: %v", err)
+	}
+
+	versions, err := c.current.GetDatasetVersions(datasetVersionsLimit)
+	if err != nil {
+		return api.Dataset{}, err
+	}
+	dataset.Versions = versions
+	if len(versions) > 0 {
+		dataset.Version = versions[0].Version
+	}
+
+	return dataset, nil
+}
+
+// DatasetChanges retrieves the changes made by the imports after the given version, or after the given time when it isn't zero
+func (c *ReloadableBusConnector) DatasetChanges(sinceVersion int, sinceTime time.Time) (api.DatasetChanges, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	versions, err := c.current.GetDatasetVersions(1)
+	if err != nil {
+		return api.DatasetChanges{}, err
+	}
+
+	changes, err := c.current.GetDatasetChanges(sinceVersion, sinceTime)
+	if err != nil {
+		return api.DatasetChanges{}, err
+	}
+
+	result := api.DatasetChanges{Changes: changes}
+	if len(versions) > 0 {
+		result.Version = versions[0].Version
+	}
+	return result, nil
+}
+
+// Update applies a change to a copy of the database file and, when apply reports that something
+// changed, replaces the file with the copy and reloads it. The file is replaced atomically, so the
+// watcher never sees it half written
+func (c *ReloadableBusConnector) Update(apply func(*BusConnector) (bool, error)) (bool, error) {
+	c.updateMu.Lock()
+	defer c.updateMu.Unlock()
+
+	// The copy is made next to the file so it can be renamed over it
+	copyPath, err := copySnapshotTo(c.path, filepath.Dir(c.path))
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := updateCopy(copyPath, apply)
+	if err != nil || !changed {
+		removeSnapshot(copyPath)
+		return false, err
+	}
+
+	if err := os.Rename(copyPath, c.path); err != nil {
+		removeSnapshot(copyPath)
+		return false, fmt.Errorf("failed to replace stops database: %v", err)
+	}
+
+	return true, c.Reload()
+}
+
+// updateCopy opens a copy of the database, applies a change to it and validates the result
+func updateCopy(path string, apply func(*BusConnector) (bool, error)) (bool, error) {
+	db, err := openDatabase(path, 1)
+	if err != nil {
+		return false, err
+	}
+
+	connector := &BusConnector{DB: db}
+	defer connector.Close()
+
+	if err := connector.initialize(); err != nil {
+		return false, fmt.Errorf("failed to initialize database: %v", err)
+	}
+
+	// The snapshots build their own indexes, but stale ones left in the file would be reused
+	if err := connector.dropIndexes(); err != nil {
+		return false, err
+	}
+
+	changed, err := apply(connector)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	if err := connector.validate(); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidDataset, err)
+	}
+
+	return true, nil
+}
+
+// GetStops retrieves all stops
+func (c *ReloadableBusConnector) GetStops() ([]api.Stop, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.GetStops()
+}
+
+// GetStopByNumber retrieves a stop by the number provided by the bus company
+func (c *ReloadableBusConnector) GetStopByNumber(stopNumber int) (api.Stop, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.GetStopByNumber(stopNumber)
+}
+
+// FindStopsByText retrieves the stops whose name matches the text, ordered by relevance
+func (c *ReloadableBusConnector) FindStopsByText(text string, limit, offset int) ([]api.Stop, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.FindStopsByText(text, limit, offset)
+}
+
+// FindStopsByLocation retrieves the stops within radius meters of a location, nearest first
+func (c *ReloadableBusConnector) FindStopsByLocation(lat, lon, radius float64, limit int) ([]api.NearbyStop, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.FindStopsByLocation(lat, lon, radius, limit)
+}
+
+// GetLines retrieves all lines
+func (c *ReloadableBusConnector) GetLines() ([]api.Line, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.GetLines()
+}
+
+// GetLineByName retrieves a line by the name provided by the bus company
+func (c *ReloadableBusConnector) GetLineByName(name string) (api.Line, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.GetLineByName(name)
+}
+
+// GetLineStops retrieves the stops served by every line, indexed by line ID
+func (c *ReloadableBusConnector) GetLineStops() (map[int][]api.Stop, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.GetLineStops()
+}
+
+// GetLineRoutes retrieves the ordered stops of every line route with a known order
+func (c *ReloadableBusConnector) GetLineRoutes() ([]api.LineRoute, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current.GetLineRoutes()
+}
+
+// Close closes the database connection and removes the snapshot
+func (c *ReloadableBusConnector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.current.Close()
+	removeSnapshot(c.snapshot)
+	return err
+}
+
+// copySnapshot copies the database file to a new temporary file, returning its path
+func copySnapshot(path string) (string, error) {
+	return copySnapshotTo(path, "")
+}
+
+// copySnapshotTo copies the database file to a new temporary file in the given directory, returning its path
+func copySnapshotTo(path, dir string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open stops database: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(dir, "stops-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create stops database snapshot: %v", err)
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to copy stops database: %v", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to write stops database snapshot: %v", err)
+	}
+
+	return dst.Name(), nil
+}
+
+// removeSnapshot deletes a snapshot along with the write-ahead log files SQLite keeps next to it
+func removeSnapshot(path string) {
+	for _, name := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s: %v", name, err)
+		}
+	}
+}
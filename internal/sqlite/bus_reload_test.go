@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadInvalidDataset(t *testing.T) {
+	dir := t.TempDir()
+
+	// An empty file is opened as a database without any stop
+	empty := filepath.Join(dir, "empty.db")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		invalid bool
+	}{
+		{"database without stops", empty, true},
+		{"missing file", filepath.Join(dir, "missing.db"), false},
+	}
+	for _, test := range tests {
+		_, err := NewReloadableBusConnector(test.path)
+		if err == nil {
+			t.Errorf("%s: expected the database not to load", test.name)
+			continue
+		}
+		if errors.Is(err, ErrInvalidDataset) != test.invalid {
+			t.Errorf("%s: expected ErrInvalidDataset to be %v, got %v", test.name, test.invalid, err)
+		}
+	}
+}
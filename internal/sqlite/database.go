@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// busyTimeout is how long a connection waits for a lock held by another one before failing
+	busyTimeout = 5 * time.Second
+
+	// connMaxIdleTime is how long an unused connection is kept open in the pool
+	connMaxIdleTime = 10 * time.Minute
+)
+
+// openDatabase opens a pool of connections to the SQLite database at the given path, using
+// write-ahead logging so readers don't block the writer and waiting on locks instead of failing
+func openDatabase(path string, maxOpenConns int) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", "on")
+	params.Set("_synchronous", "NORMAL")
+	// Taking the write lock when the transaction starts avoids deadlocks between transactions upgrading their locks
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+	db.SetConnMaxIdleTime(connMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	return db, nil
+}
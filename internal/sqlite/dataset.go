@@ -0,0 +1,517 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// createDatasetTables creates the tables that record the imports of the dataset and what they changed
+func (c *BusConnector) createDatasetTables() error {
+	createDatasetVersionsTable := `
+    CREATE TABLE IF NOT EXISTS dataset_versions (
+        version INTEGER PRIMARY KEY AUTOINCREMENT,
+        source TEXT NOT NULL,
+        imported_at TEXT NOT NULL,
+        stops_added INTEGER NOT NULL,
+        stops_updated INTEGER NOT NULL,
+        stops_removed INTEGER NOT NULL,
+        lines_added INTEGER NOT NULL,
+        lines_removed INTEGER NOT NULL
+    );`
+
+	createDatasetChangesTable := `
+    CREATE TABLE IF NOT EXISTS dataset_changes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        version INTEGER NOT NULL,
+        entity TEXT NOT NULL,
+        action TEXT NOT NULL,
+        stop_number INTEGER,
+        line TEXT,
+        name TEXT,
+        previous_name TEXT,
+        lat REAL,
+        lon REAL,
+        previous_lat REAL,
+        previous_lon REAL,
+        FOREIGN KEY (version) REFERENCES dataset_versions(version)
+    );`
+
+	if _, err := c.DB.Exec(createDatasetVersionsTable); err != nil {
+		return fmt.Errorf("failed to create dataset_versions table: %v", err)
+	}
+
+	if _, err := c.DB.Exec(createDatasetChangesTable); err != nil {
+		return fmt.Errorf("failed to create dataset_changes table: %v", err)
+	}
+
+	return nil
+}
+
+// GetDatasetVersions retrieves the latest imports of the dataset, newest first
+func (c *BusConnector) GetDatasetVersions(limit int) ([]api.DatasetVersion, error) {
+	query := `
+    SELECT version, source, imported_at, stops_added, stops_updated, stops_removed, lines_added, lines_removed
+    FROM dataset_versions
+    ORDER BY version DESC
+    LIMIT ?`
+	rows, err := c.DB.Query(query, sqlLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dataset versions: %v", err)
+	}
+	defer rows.Close()
+
+	versions := []api.DatasetVersion{}
+	for rows.Next() {
+		var version api.DatasetVersion
+		var importedAt string
+		if err := rows.Scan(&version.Version, &version.Source, &importedAt, &version.StopsAdded, &version.StopsUpdated, &version.StopsRemoved, &version.LinesAdded, &version.LinesRemoved); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		version.ImportedAt, _ = time.Parse(time.RFC3339, importedAt)
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// GetDatasetChanges retrieves the changes made by the imports after the given version, or after the
+// given time when it isn't zero, oldest first
+func (c *BusConnector) GetDatasetChanges(sinceVersion int, sinceTime time.Time) ([]api.DatasetChange, error) {
+	query := `
+    SELECT c.version, v.imported_at, c.entity, c.action, c.stop_number, c.line, c.name, c.previous_name, c.lat, c.lon, c.previous_lat, c.previous_lon
+    FROM dataset_changes c
+    JOIN dataset_versions v ON v.version = c.version
+    WHERE c.version > ? AND v.imported_at > ?
+    ORDER BY c.id`
+	since := ""
+	if !sinceTime.IsZero() {
+		since = sinceTime.UTC().Format(time.RFC3339)
+	}
+	rows, err := c.DB.Query(query, sinceVersion, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dataset changes: %v", err)
+	}
+	defer rows.Close()
+
+	changes := []api.DatasetChange{}
+	for rows.Next() {
+		var change api.DatasetChange
+		var importedAt string
+		var stopNumber sql.NullInt64
+		var line, name, previousName sql.NullString
+		var lat, lon, previousLat, previousLon sql.NullFloat64
+		if err := rows.Scan(&change.Version, &importedAt, &change.Entity, &change.Action, &stopNumber, &line, &name, &previousName, &lat, &lon, &previousLat, &previousLon); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		change.ImportedAt, _ = time.Parse(time.RFC3339, importedAt)
+		change.StopNumber = int(stopNumber.Int64)
+		change.Line, change.Name, change.PreviousName = line.String, name.String, previousName.String
+		if lat.Valid && lon.Valid {
+			change.Location = &api.Coordinates{Lat: lat.Float64, Lon: lon.Float64}
+		}
+		if previousLat.Valid && previousLon.Valid {
+			change.PreviousLocation = &api.Coordinates{Lat: previousLat.Float64, Lon: previousLon.Float64}
+		}
+		if change.Location != nil && change.PreviousLocation != nil {
+			change.Distance = math.Round(geo.Distance(change.PreviousLocation.Lat, change.PreviousLocation.Lon, change.Location.Lat, change.Location.Lon))
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// datasetImport holds the state of an import while it is applied in a transaction
+type datasetImport struct {
+	tx      *sql.Tx
+	changes []api.DatasetChange
+	version api.DatasetVersion
+
+	// moveThreshold is the distance in meters a stop has to move to be reported as moved
+	moveThreshold float64
+}
+
+// ImportDataset replaces the stops and lines with the ones of the dataset, keeping the IDs of the stops
+// and lines that still exist so the line routes remain valid. The changes are recorded as a new dataset
+// version, returning false without recording anything when the dataset matches the current data.
+// Stops that move farther than moveThreshold meters are reported as moved
+func (c *BusConnector) ImportDataset(source string, stops []storage.DatasetStop, moveThreshold float64) (bool, error) {
+	if len(stops) == 0 {
+		return false, fmt.Errorf("dataset has no stops")
+	}
+
+	current, err := c.GetStops()
+	if err != nil {
+		return false, err
+	}
+
+	lines, err := c.GetLines()
+	if err != nil {
+		return false, err
+	}
+
+	currentLines, err := c.stopLineNames()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	imp := &datasetImport{
+		tx:            tx,
+		version:       api.DatasetVersion{Source: source, ImportedAt: time.Now().UTC()},
+		moveThreshold: moveThreshold,
+	}
+	if err := imp.apply(current, lines, currentLines, stops); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if len(imp.changes) == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if err := imp.record(); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return true, nil
+}
+
+// stopLineNames retrieves the sorted names of the lines that serve every stop, indexed by stop number
+func (c *BusConnector) stopLineNames() (map[int][]string, error) {
+	query := `
+    SELECT s.stop_number, l.name
+    FROM line_stops ls
+    JOIN stops s ON s.id = ls.stop_id
+    JOIN lines l ON l.id = ls.line_id
+    ORDER BY s.stop_number, l.name`
+	rows, err := c.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query line stops: %v", err)
+	}
+	defer rows.Close()
+
+	stopLines := make(map[int][]string)
+	for rows.Next() {
+		var stopNumber int
+		var line string
+		if err := rows.Scan(&stopNumber, &line); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		stopLines[stopNumber] = append(stopLines[stopNumber], line)
+	}
+
+	return stopLines, nil
+}
+
+// apply diffs the dataset against the current stops and lines and writes the differences
+func (imp *datasetImport) apply(current []api.Stop, lines []api.Line, currentLines map[int][]string, stops []storage.DatasetStop) error {
+	// The dataset may repeat a stop, in which case the last occurrence wins
+	byNumber := make(map[int]storage.DatasetStop, len(stops))
+	for _, stop := range stops {
+		stop.Lines = slices.Clone(stop.Lines)
+		slices.Sort(stop.Lines)
+		stop.Lines = slices.Compact(stop.Lines)
+		byNumber[stop.StopNumber] = stop
+	}
+
+	lineIDs := make(map[string]int, len(lines))
+	for _, line := range lines {
+		lineIDs[line.Name] = line.ID
+	}
+
+	neededLines := make(map[string]bool)
+	for _, stop := range byNumber {
+		for _, line := range stop.Lines {
+			neededLines[line] = true
+		}
+	}
+
+	// Lines are added first so the new stops can be linked to them
+	for _, name := range sortedKeys(neededLines) {
+		if _, ok := lineIDs[name]; ok {
+			continue
+		}
+
+		result, err := imp.tx.Exec(`INSERT INTO lines (name) VALUES (?)`, name)
+		if err != nil {
+			return fmt.Errorf("failed to insert line: %v", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %v", err)
+		}
+		lineIDs[name] = int(id)
+		imp.change(api.DatasetChange{Entity: api.DatasetEntityLine, Action: api.DatasetChangeAdded, Line: name})
+	}
+
+	existing := make(map[int]api.Stop, len(current))
+	for _, stop := range current {
+		existing[stop.StopNumber] = stop
+	}
+
+	for _, stopNumber := range sortedKeys(byNumber) {
+		stop := byNumber[stopNumber]
+		previous, ok := existing[stopNumber]
+		if !ok {
+			if err := imp.insertStop(stop, lineIDs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		stop.ID = previous.ID
+		if err := imp.updateStop(previous, currentLines[stopNumber], stop, lineIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, stopNumber := range sortedKeys(existing) {
+		if _, ok := byNumber[stopNumber]; !ok {
+			if err := imp.removeStop(existing[stopNumber]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, line := range lines {
+		if neededLines[line.Name] {
+			continue
+		}
+		if err := imp.removeLine(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertStop adds a new stop and links it to its lines
+func (imp *datasetImport) insertStop(stop storage.DatasetStop, lineIDs map[string]int) error {
+	query := `INSERT INTO stops (stop_number, stop_id, name, lat, lon) VALUES (?, ?, ?, ?, ?)`
+	result, err := imp.tx.Exec(query, stop.StopNumber, stop.StopID, stop.Name, stop.Location.Lat, stop.Location.Lon)
+	if err != nil {
+		return fmt.Errorf("failed to insert stop: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %v", err)
+	}
+	stop.ID = int(id)
+
+	if err := imp.linkStop(stop, lineIDs); err != nil {
+		return err
+	}
+
+	location := api.Coordinates(stop.Location)
+	imp.change(api.DatasetChange{
+		Entity:     api.DatasetEntityStop,
+		Action:     api.DatasetChangeAdded,
+		StopNumber: stop.StopNumber,
+		Name:       stop.Name,
+		Location:   &location,
+	})
+	return nil
+}
+
+// updateStop writes the data of an existing stop and relinks its lines when any of them changed
+func (imp *datasetImport) updateStop(previous api.Stop, previousLines []string, stop storage.DatasetStop, lineIDs map[string]int) error {
+	sameData := previous.StopID == stop.StopID && previous.Name == stop.Name && previous.Location == stop.Location
+	sameLines := slices.Equal(previousLines, stop.Lines)
+	if sameData && sameLines {
+		return nil
+	}
+
+	if !sameData {
+		query := `UPDATE stops SET stop_id = ?, name = ?, lat = ?, lon = ? WHERE id = ?`
+		if _, err := imp.tx.Exec(query, stop.StopID, stop.Name, stop.Location.Lat, stop.Location.Lon, stop.ID); err != nil {
+			return fmt.Errorf("failed to update stop: %v", err)
+		}
+	}
+
+	if !sameLines {
+		if _, err := imp.tx.Exec(`DELETE FROM line_stops WHERE stop_id = ?`, stop.ID); err != nil {
+			return fmt.Errorf("failed to delete line stops: %v", err)
+		}
+		if err := imp.linkStop(stop, lineIDs); err != nil {
+			return err
+		}
+	}
+
+	// A stop is reported once for every way it changed that matters to the riders, and as
+	// updated when only its lines or internal ID changed or it moved just a few meters
+	location, previousLocation := api.Coordinates(stop.Location), api.Coordinates(previous.Location)
+	change := api.DatasetChange{
+		Entity:           api.DatasetEntityStop,
+		StopNumber:       stop.StopNumber,
+		Name:             stop.Name,
+		PreviousName:     previous.Name,
+		Location:         &location,
+		PreviousLocation: &previousLocation,
+	}
+
+	var actions []api.DatasetChangeAction
+	if previous.Name != stop.Name {
+		actions = append(actions, api.DatasetChangeRenamed)
+	}
+	if geo.Distance(previous.Location.Lat, previous.Location.Lon, stop.Location.Lat, stop.Location.Lon) > imp.moveThreshold {
+		actions = append(actions, api.DatasetChangeMoved)
+	}
+	if len(actions) == 0 {
+		actions = append(actions, api.DatasetChangeUpdated)
+	}
+
+	for _, action := range actions {
+		change.Action = action
+		imp.change(change)
+	}
+	imp.version.StopsUpdated++
+	return nil
+}
+
+// linkStop adds a stop to the line_stops table of every line that serves it
+func (imp *datasetImport) linkStop(stop storage.DatasetStop, lineIDs map[string]int) error {
+	for _, line := range stop.Lines {
+		query := `INSERT INTO line_stops (line_id, stop_id) VALUES (?, ?)`
+		if _, err := imp.tx.Exec(query, lineIDs[line], stop.ID); err != nil {
+			return fmt.Errorf("failed to add stop to line: %v", err)
+		}
+	}
+	return nil
+}
+
+// removeStop deletes a stop along with its place in the lines and their routes
+func (imp *datasetImport) removeStop(stop api.Stop) error {
+	queries := []string{
+		`DELETE FROM line_stop_sequences WHERE stop_id = ?`,
+		`DELETE FROM line_stops WHERE stop_id = ?`,
+		`DELETE FROM stops WHERE id = ?`,
+	}
+	for _, query := range queries {
+		if _, err := imp.tx.Exec(query, stop.ID); err != nil {
+			return fmt.Errorf("failed to remove stop: %v", err)
+		}
+	}
+
+	previousLocation := api.Coordinates(stop.Location)
+	imp.change(api.DatasetChange{
+		Entity:           api.DatasetEntityStop,
+		Action:           api.DatasetChangeRemoved,
+		StopNumber:       stop.StopNumber,
+		PreviousName:     stop.Name,
+		PreviousLocation: &previousLocation,
+	})
+	return nil
+}
+
+// removeLine deletes a line along with its stops and routes
+func (imp *datasetImport) removeLine(line api.Line) error {
+	queries := []string{
+		`DELETE FROM line_stop_sequences WHERE line_id = ?`,
+		`DELETE FROM line_stops WHERE line_id = ?`,
+		`DELETE FROM lines WHERE id = ?`,
+	}
+	for _, query := range queries {
+		if _, err := imp.tx.Exec(query, line.ID); err != nil {
+			return fmt.Errorf("failed to remove line: %v", err)
+		}
+	}
+
+	imp.change(api.DatasetChange{Entity: api.DatasetEntityLine, Action: api.DatasetChangeRemoved, Line: line.Name})
+	return nil
+}
+
+// change adds a change to the import and counts the added and removed records in the version summary
+func (imp *datasetImport) change(change api.DatasetChange) {
+	imp.changes = append(imp.changes, change)
+
+	v := &imp.version
+	switch {
+	case change.Entity == api.DatasetEntityStop && change.Action == api.DatasetChangeAdded:
+		v.StopsAdded++
+	case change.Entity == api.DatasetEntityStop && change.Action == api.DatasetChangeRemoved:
+		v.StopsRemoved++
+	case change.Entity == api.DatasetEntityLine && change.Action == api.DatasetChangeAdded:
+		v.LinesAdded++
+	case change.Entity == api.DatasetEntityLine && change.Action == api.DatasetChangeRemoved:
+		v.LinesRemoved++
+	}
+}
+
+// record inserts the new dataset version along with its changes
+func (imp *datasetImport) record() error {
+	v := imp.version
+	query := `
+    INSERT INTO dataset_versions (source, imported_at, stops_added, stops_updated, stops_removed, lines_added, lines_removed)
+    VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := imp.tx.Exec(query, v.Source, v.ImportedAt.Format(time.RFC3339), v.StopsAdded, v.StopsUpdated, v.StopsRemoved, v.LinesAdded, v.LinesRemoved)
+	if err != nil {
+		return fmt.Errorf("failed to insert dataset version: %v", err)
+	}
+	version, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %v", err)
+	}
+
+	query = `
+    INSERT INTO dataset_changes (version, entity, action, stop_number, line, name, previous_name, lat, lon, previous_lat, previous_lon)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, change := range imp.changes {
+		var lat, lon, previousLat, previousLon sql.NullFloat64
+		if change.Location != nil {
+			lat = sql.NullFloat64{Float64: change.Location.Lat, Valid: true}
+			lon = sql.NullFloat64{Float64: change.Location.Lon, Valid: true}
+		}
+		if change.PreviousLocation != nil {
+			previousLat = sql.NullFloat64{Float64: change.PreviousLocation.Lat, Valid: true}
+			previousLon = sql.NullFloat64{Float64: change.PreviousLocation.Lon, Valid: true}
+		}
+
+		_, err := imp.tx.Exec(query, version, change.Entity, change.Action, nullInt(change.StopNumber), nullString(change.Line),
+			nullString(change.Name), nullString(change.PreviousName), lat, lon, previousLat, previousLon)
+		if err != nil {
+			return fmt.Errorf("failed to insert dataset change: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of a map in increasing order, so imports always apply the changes in the same order
+func sortedKeys[K int | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// nullInt stores zero values as NULL
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
+
+// nullString stores empty values as NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/storage/storagetest"
+)
+
+func TestIdentityConnector(t *testing.T) {
+	storagetest.IdentityDatabase(t, func(t *testing.T) storage.IdentityDatabase {
+		c, err := NewIdentityConnector(filepath.Join(t.TempDir(), "identity.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+
+		migrator, err := c.Migrator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	})
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// createIndexChecksumsTable creates the table holding the checksum of the content every derived
+// index was built from, so an index is rebuilt whenever that content changes
+func (c *BusConnector) createIndexChecksumsTable() error {
+	createTable := `
+    CREATE TABLE IF NOT EXISTS index_checksums (
+        name TEXT PRIMARY KEY,
+        checksum TEXT NOT NULL
+    );`
+	if _, err := c.DB.Exec(createTable); err != nil {
+		return fmt.Errorf("failed to create index_checksums table: %v", err)
+	}
+	return nil
+}
+
+// indexChecksum retrieves the checksum of the content an index was built from, or an empty string
+// if the index was never built
+func (c *BusConnector) indexChecksum(name string) (string, error) {
+	if err := c.createIndexChecksumsTable(); err != nil {
+		return "", err
+	}
+
+	var checksum string
+	err := c.DB.QueryRow(`SELECT checksum FROM index_checksums WHERE name = ?`, name).Scan(&checksum)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get checksum of %s: %v", name, err)
+	}
+	return checksum, nil
+}
+
+// setIndexChecksum stores the checksum of the content an index was built from within a transaction
+func setIndexChecksum(tx *sql.Tx, name, checksum string) error {
+	query := `INSERT INTO index_checksums (name, checksum) VALUES (?, ?)
+        ON CONFLICT (name) DO UPDATE SET checksum = excluded.checksum`
+	if _, err := tx.Exec(query, name, checksum); err != nil {
+		return fmt.Errorf("failed to store checksum of %s: %v", name, err)
+	}
+	return nil
+}
+
+// contentChecksum returns the checksum of a sequence of rows, each written as tab-separated values
+func contentChecksum(rows [][]any) string {
+	hash := sha256.New()
+	for _, row := range rows {
+		for i, value := range row {
+			if i > 0 {
+				fmt.Fprint(hash, "\t")
+			}
+			fmt.Fprint(hash, value)
+		}
+		fmt.Fprint(hash, "\n")
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS favorite_stops;
+DROP TABLE IF EXISTS identities;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uuid TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    metadata TEXT
+);
+
+CREATE TABLE IF NOT EXISTS favorite_stops (
+    identity_id INTEGER,
+    stop_number INTEGER,
+    FOREIGN KEY(identity_id) REFERENCES identities(id)
+);
@@ -0,0 +1 @@
+DROP INDEX identities_provider_uuid;
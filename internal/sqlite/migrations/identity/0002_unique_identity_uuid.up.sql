@@ -0,0 +1,11 @@
+-- Merge the favorite stops of duplicated identities into the oldest one before removing the rest
+UPDATE favorite_stops SET identity_id = (
+    SELECT min(i.id) FROM identities i
+    JOIN identities d ON d.provider = i.provider AND d.uuid = i.uuid
+    WHERE d.id = favorite_stops.identity_id
+)
+WHERE identity_id IN (SELECT id FROM identities);
+
+DELETE FROM identities WHERE id NOT IN (SELECT min(id) FROM identities GROUP BY provider, uuid);
+
+CREATE UNIQUE INDEX identities_provider_uuid ON identities (provider, uuid);
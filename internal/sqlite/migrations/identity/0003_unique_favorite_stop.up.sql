@@ -0,0 +1,5 @@
+DELETE FROM favorite_stops WHERE rowid NOT IN (
+    SELECT min(rowid) FROM favorite_stops GROUP BY identity_id, stop_number
+);
+
+CREATE UNIQUE INDEX favorite_stops_identity_stop ON favorite_stops (identity_id, stop_number);
@@ -0,0 +1,6 @@
+DROP TABLE link_codes;
+
+DROP INDEX identities_account;
+ALTER TABLE identities DROP COLUMN account_id;
+
+DROP TABLE accounts;
@@ -0,0 +1,19 @@
+CREATE TABLE accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TEXT NOT NULL
+);
+
+-- Every existing identity gets its own account, with the same ID
+INSERT INTO accounts (id, created_at)
+SELECT id, strftime('%Y-%m-%dT%H:%M:%SZ', 'now') FROM identities;
+
+ALTER TABLE identities ADD COLUMN account_id INTEGER;
+UPDATE identities SET account_id = id;
+CREATE INDEX identities_account ON identities (account_id);
+
+CREATE TABLE link_codes (
+    code TEXT PRIMARY KEY,
+    identity_id INTEGER NOT NULL REFERENCES identities(id),
+    expires_at TEXT NOT NULL
+);
+CREATE INDEX link_codes_identity ON link_codes (identity_id);
@@ -0,0 +1,2 @@
+ALTER TABLE identities ADD COLUMN deleted_at TEXT;
+CREATE INDEX identities_deleted_at ON identities (deleted_at);
@@ -0,0 +1,3 @@
+ALTER TABLE favorite_stops DROP COLUMN lines;
+ALTER TABLE favorite_stops DROP COLUMN position;
+ALTER TABLE favorite_stops DROP COLUMN alias;
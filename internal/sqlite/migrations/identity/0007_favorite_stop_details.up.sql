@@ -0,0 +1,9 @@
+ALTER TABLE favorite_stops ADD COLUMN alias TEXT NOT NULL DEFAULT '';
+ALTER TABLE favorite_stops ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE favorite_stops ADD COLUMN lines TEXT NOT NULL DEFAULT '[]';
+
+-- The existing favorites keep the order in which they were added
+UPDATE favorite_stops SET position = (
+    SELECT count(*) FROM favorite_stops f
+    WHERE f.identity_id = favorite_stops.identity_id AND f.rowid < favorite_stops.rowid
+);
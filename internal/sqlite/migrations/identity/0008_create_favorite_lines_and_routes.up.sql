@@ -0,0 +1,14 @@
+CREATE TABLE favorite_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    identity_id INTEGER NOT NULL REFERENCES identities(id),
+    line_name TEXT NOT NULL
+);
+CREATE UNIQUE INDEX favorite_lines_identity_line ON favorite_lines (identity_id, line_name);
+
+CREATE TABLE favorite_routes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    identity_id INTEGER NOT NULL REFERENCES identities(id),
+    origin_stop_number INTEGER NOT NULL,
+    destination_stop_number INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX favorite_routes_identity_stops ON favorite_routes (identity_id, origin_stop_number, destination_stop_number);
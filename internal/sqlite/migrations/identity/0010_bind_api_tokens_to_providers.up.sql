@@ -0,0 +1,2 @@
+-- Existing tokens keep access to the users of every provider
+ALTER TABLE api_tokens ADD COLUMN providers TEXT NOT NULL DEFAULT '';
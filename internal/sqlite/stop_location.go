@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// buildLocationIndex creates the R*Tree spatial index over the stop locations and
+// fills it again whenever the locations changed since it was last built
+func (c *BusConnector) buildLocationIndex() error {
+	createLocationTable := `
+    CREATE VIRTUAL TABLE IF NOT EXISTS stops_location USING rtree(
+        id,
+        min_lat, max_lat,
+        min_lon, max_lon
+    );`
+
+	if _, err := c.DB.Exec(createLocationTable); err != nil {
+		return fmt.Errorf("failed to create stops_location table: %v", err)
+	}
+
+	// Stops moved by a new dataset leave the count unchanged, so the index is compared by content
+	rows, err := c.DB.Query(`SELECT id, lat, lon FROM stops WHERE lat IS NOT NULL AND lon IS NOT NULL ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to get stop locations: %v", err)
+	}
+	var locations [][]any
+	for rows.Next() {
+		var id int
+		var lat, lon float64
+		if err := rows.Scan(&id, &lat, &lon); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan stop location: %v", err)
+		}
+		locations = append(locations, []any{id, lat, lon})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get stop locations: %v", err)
+	}
+	checksum := contentChecksum(locations)
+
+	indexed, err := c.indexChecksum("stops_location")
+	if err != nil {
+		return err
+	}
+	if indexed == checksum {
+		return nil
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM stops_location`); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to clear stops_location table: %v", err)
+	}
+
+	insertQuery := `
+    INSERT INTO stops_location (id, min_lat, max_lat, min_lon, max_lon)
+    SELECT id, lat, lat, lon, lon FROM stops WHERE lat IS NOT NULL AND lon IS NOT NULL`
+	if _, err := tx.Exec(insertQuery); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to index stop locations: %v", err)
+	}
+
+	if err := setIndexChecksum(tx, "stops_location", checksum); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Indexed %d stop locations", len(locations))
+	return nil
+}
+
+// nearestStops retrieves the stops inside the bounding box of the radius from the spatial index
+// and keeps the ones within the radius, nearest first. A limit of 0 returns every stop
+func (c *BusConnector) nearestStops(lat, lon, radius float64, limit int) ([]api.NearbyStop, error) {
+	minLat, maxLat, minLon, maxLon := geo.BoundingBox(lat, lon, radius)
+
+	query := `
+    SELECT s.id, s.stop_number, s.stop_id, s.name, s.lat, s.lon
+    FROM stops_location l
+    JOIN stops s ON s.id = l.id
+    WHERE l.max_lat >= ? AND l.min_lat <= ? AND l.max_lon >= ? AND l.min_lon <= ?`
+	rows, err := c.DB.Query(query, minLat, maxLat, minLon, maxLon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stops: %v", err)
+	}
+	defer rows.Close()
+
+	var stops []api.Stop
+	for rows.Next() {
+		var stop api.Stop
+		if err := rows.Scan(&stop.ID, &stop.StopNumber, &stop.StopID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		stops = append(stops, stop)
+	}
+
+	return storage.NearestStops(lat, lon, radius, limit, stops), nil
+}
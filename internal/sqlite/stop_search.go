@@ -0,0 +1,268 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchSynonyms maps Spanish spellings and common abbreviations found in stop names
+// to the Galician form used by the index, so both languages match the same stops
+var searchSynonyms = map[string]string{
+	"plaza":     "praza",
+	"pza":       "praza",
+	"avda":      "avenida",
+	"avd":       "avenida",
+	"av":        "avenida",
+	"calle":     "rua",
+	"ctra":      "estrada",
+	"carretera": "estrada",
+}
+
+// errSearchUnavailable is returned when SQLite was built without the FTS5 module, which requires the sqlite_fts5 build tag
+var errSearchUnavailable = errors.New("sqlite was built without the fts5 module, build with -tags sqlite_fts5")
+
+// buildSearchIndex creates the full-text search index over the stop names and
+// fills it again whenever the stops changed since it was last built
+func (c *BusConnector) buildSearchIndex() error {
+	createSearchTable := `
+    CREATE VIRTUAL TABLE IF NOT EXISTS stops_search USING fts5(
+        name,
+        stop_number,
+        tokenize = 'unicode61 remove_diacritics 2',
+        prefix = '2 3'
+    );`
+
+	createSearchVocabTable := `
+    CREATE VIRTUAL TABLE IF NOT EXISTS stops_search_vocab USING fts5vocab(stops_search, 'row');`
+
+	if _, err := c.DB.Exec(createSearchTable); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return errSearchUnavailable
+		}
+		return fmt.Errorf("failed to create stops_search table: %v", err)
+	}
+
+	if _, err := c.DB.Exec(createSearchVocabTable); err != nil {
+		return fmt.Errorf("failed to create stops_search_vocab table: %v", err)
+	}
+
+	stops, err := c.GetStops()
+	if err != nil {
+		return err
+	}
+
+	// Stops renamed by a new dataset leave the count unchanged, so the index is compared by content
+	rows := make([][]any, len(stops))
+	for i, stop := range stops {
+		rows[i] = []any{stop.ID, stop.StopNumber, stop.Name}
+	}
+	slices.SortFunc(rows, func(a, b []any) int {
+		return a[0].(int) - b[0].(int)
+	})
+	checksum := contentChecksum(rows)
+
+	indexed, err := c.indexChecksum("stops_search")
+	if err != nil {
+		return err
+	}
+	if indexed == checksum {
+		return nil
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM stops_search`); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to clear stops_search table: %v", err)
+	}
+
+	insertQuery := `INSERT INTO stops_search (rowid, name, stop_number) VALUES (?, ?, ?)`
+	for _, stop := range stops {
+		name := strings.Join(searchTokens(stop.Name), " ")
+		if _, err := tx.Exec(insertQuery, stop.ID, name, strconv.Itoa(stop.StopNumber)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to index stop: %v", err)
+		}
+	}
+
+	if err := setIndexChecksum(tx, "stops_search", checksum); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Indexed %d stops for full-text search", len(stops))
+	return nil
+}
+
+// searchStops runs a ranked full-text query against the search index, expanding every
+// token of the text to the indexed terms it is a prefix of or a close misspelling of
+func (c *BusConnector) searchStops(text string, limit, offset int) ([]api.Stop, error) {
+	tokens := searchTokens(text)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	vocabulary, err := c.searchVocabulary()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, token := range tokens {
+		terms := []string{quoteSearchTerm(token) + "*"}
+		for _, term := range vocabulary {
+			if term != token && !strings.HasPrefix(term, token) && withinEditDistance(token, term, maxSearchEdits(token)) {
+				terms = append(terms, quoteSearchTerm(term))
+			}
+		}
+		groups = append(groups, "("+strings.Join(terms, " OR ")+")")
+	}
+
+	query := `
+    SELECT s.id, s.stop_number, s.stop_id, s.name, s.lat, s.lon
+    FROM stops_search
+    JOIN stops s ON s.id = stops_search.rowid
+    WHERE stops_search MATCH ?
+    ORDER BY stops_search.rank, s.name
+    LIMIT ? OFFSET ?`
+	rows, err := c.DB.Query(query, strings.Join(groups, " AND "), sqlLimit(limit), offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stops: %v", err)
+	}
+	defer rows.Close()
+
+	var stops []api.Stop
+	for rows.Next() {
+		var stop api.Stop
+		if err := rows.Scan(&stop.ID, &stop.StopNumber, &stop.StopID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		stops = append(stops, stop)
+	}
+
+	return stops, nil
+}
+
+// searchVocabulary retrieves every term stored in the search index
+func (c *BusConnector) searchVocabulary() ([]string, error) {
+	rows, err := c.DB.Query(`SELECT term FROM stops_search_vocab`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query search vocabulary: %v", err)
+	}
+	defer rows.Close()
+
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
+
+// searchTokens lowercases the text, removes its diacritics and splits it into words,
+// replacing the known synonyms with their indexed form
+func searchTokens(text string) []string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), strings.ToLower(text))
+	if err != nil {
+		folded = strings.ToLower(text)
+	}
+
+	var tokens []string
+	for _, word := range strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if synonym, ok := searchSynonyms[word]; ok {
+			word = synonym
+		}
+		tokens = append(tokens, word)
+	}
+
+	return tokens
+}
+
+// quoteSearchTerm escapes a term so it is read as a string by the FTS5 query parser
+func quoteSearchTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+// maxSearchEdits returns how many typos are tolerated for a token depending on its length.
+// Tokens with digits, such as stop numbers, must match exactly
+func maxSearchEdits(token string) int {
+	switch n := len([]rune(token)); {
+	case strings.IndexFunc(token, unicode.IsDigit) >= 0:
+		return 0
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// withinEditDistance reports whether the Levenshtein distance between a and b is at most maxEdits
+func withinEditDistance(a, b string, maxEdits int) bool {
+	if maxEdits == 0 {
+		return false
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > maxEdits || -diff > maxEdits {
+		return false
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > maxEdits {
+			return false
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)] <= maxEdits
+}
+
+// sqlLimit converts a limit where 0 means no limit into the value expected by SQLite
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
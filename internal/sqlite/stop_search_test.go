@@ -0,0 +1,98 @@
+//go:build sqlite_fts5
+
+package sqlite
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newSearchConnector creates a stops database with the given stop names and builds its search index
+func newSearchConnector(t *testing.T, names ...string) *BusConnector {
+	t.Helper()
+
+	c, err := NewBusConnector(filepath.Join(t.TempDir(), "stops.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	for i, name := range names {
+		query := `INSERT INTO stops (stop_number, stop_id, name, lat, lon) VALUES (?, ?, ?, 0, 0)`
+		if _, err := c.DB.Exec(query, 100+i, 100+i, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.buildSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.searchEnabled {
+		t.Fatal("expected full-text search to be enabled")
+	}
+	return c
+}
+
+func TestFindStopsByText(t *testing.T) {
+	c := newSearchConnector(t, "Praza de España", "Praza de América", "Policarpo Sanz, 40", "Rúa Urzaiz - Príncipe", "Avenida de Samil")
+
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"spanish spelling without accents", "Plaza Espana", []string{"Praza de España"}},
+		{"galician spelling with accents", "praza españa", []string{"Praza de España"}},
+		{"abbreviation", "avda samil", []string{"Avenida de Samil"}},
+		{"one typo", "Polcarpo", []string{"Policarpo Sanz, 40"}},
+		{"prefix", "Urza", []string{"Rúa Urzaiz - Príncipe"}},
+		{"ranked then sorted by name", "praza", []string{"Praza de América", "Praza de España"}},
+		{"every word must match", "praza samil", nil},
+		{"short words need an exact match", "rux", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stops, err := c.FindStopsByText(test.text, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, stop := range stops {
+				names = append(names, stop.Name)
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestFindStopsByTextPagination(t *testing.T) {
+	c := newSearchConnector(t, "Praza de España", "Praza de América", "Praza da Independencia")
+
+	tests := []struct {
+		limit, offset int
+		expected      []string
+	}{
+		{0, 0, []string{"Praza da Independencia", "Praza de América", "Praza de España"}},
+		{2, 0, []string{"Praza da Independencia", "Praza de América"}},
+		{2, 2, []string{"Praza de España"}},
+		{1, 1, []string{"Praza de América"}},
+		{0, 3, nil},
+	}
+	for _, test := range tests {
+		stops, err := c.FindStopsByText("praza", test.limit, test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, stop := range stops {
+			names = append(names, stop.Name)
+		}
+		if !slices.Equal(names, test.expected) {
+			t.Fatalf("limit %d offset %d: expected %v, got %v", test.limit, test.offset, test.expected, names)
+		}
+	}
+}
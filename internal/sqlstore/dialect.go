@@ -0,0 +1,63 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect holds what the SQL databases supported by the identity store don't have in common
+type Dialect struct {
+	// Placeholder returns the placeholder of the nth argument of a query, starting at 1
+	Placeholder func(n int) string
+
+	// LockAccount is the query that returns the account ID of the identity given as its only argument,
+	// keeping other transactions from changing the account until this one ends
+	LockAccount string
+
+	// IsUniqueViolation tells whether an error was caused by a unique constraint
+	IsUniqueViolation func(err error) bool
+}
+
+// QuestionPlaceholder is the placeholder of the databases that number the arguments of a query
+// by their order, such as SQLite
+func QuestionPlaceholder(int) string {
+	return "?"
+}
+
+// DollarPlaceholder is the placeholder of the databases that number the arguments of a query
+// explicitly, such as PostgreSQL
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// rebind replaces the ? placeholders of a query with the ones of the dialect. The queries of the
+// store don't have question marks anywhere else
+func (s *IdentityStore) rebind(query string) string {
+	if s.Dialect.Placeholder == nil || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var rebound strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			rebound.WriteRune(r)
+			continue
+		}
+		n++
+		rebound.WriteString(s.Dialect.Placeholder(n))
+	}
+	return rebound.String()
+}
+
+// lockAccount returns the account ID of an identity within a transaction, locking the account so
+// concurrent changes to its favorites and metadata wait for the transaction to end
+func (s *IdentityStore) lockAccount(tx *sql.Tx, identityID int) (int, error) {
+	var accountID int
+	if err := tx.QueryRow(s.rebind(s.Dialect.LockAccount), identityID).Scan(&accountID); err != nil {
+		return 0, fmt.Errorf("failed to lock account: %v", err)
+	}
+	return accountID, nil
+}
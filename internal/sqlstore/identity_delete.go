@@ -0,0 +1,83 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SoftDeleteIdentity marks an identity as deleted, hiding it until it is purged. Its favorites
+// are handed over to another identity of the account and its link codes are discarded
+func (s *IdentityStore) SoftDeleteIdentity(id int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	var heirID sql.NullInt64
+	query := `SELECT (
+            SELECT min(o.id) FROM identities o WHERE o.account_id = i.account_id AND o.id != i.id AND o.deleted_at IS NULL
+        ) FROM identities i WHERE i.id = ?`
+	if err := tx.QueryRow(s.rebind(query), id).Scan(&heirID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to get identity: %v", err)
+	}
+
+	if heirID.Valid {
+		for _, table := range favoriteTables {
+			query = `UPDATE ` + table + ` SET identity_id = ? WHERE identity_id = ?`
+			if _, err := tx.Exec(s.rebind(query), heirID.Int64, id); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to hand over favorites from %s: %v", table, err)
+			}
+		}
+	}
+
+	query = `DELETE FROM link_codes WHERE identity_id = ?`
+	if _, err := tx.Exec(s.rebind(query), id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete link codes: %v", err)
+	}
+
+	query = `UPDATE identities SET deleted_at = ? WHERE id = ?`
+	if _, err := tx.Exec(s.rebind(query), time.Now().UTC().Format(time.RFC3339), id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete identity: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedIdentities deletes for good the identities marked as deleted before the given time
+func (s *IdentityStore) PurgeDeletedIdentities(deletedBefore time.Time) (int, error) {
+	query := `SELECT id FROM identities WHERE deleted_at < ?`
+	rows, err := s.DB.Query(s.rebind(query), deletedBefore.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deleted identities: %v", err)
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan deleted identity: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get deleted identities: %v", err)
+	}
+
+	for i, id := range ids {
+		if err := s.DeleteIdentity(id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,39 @@
+package sqlstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// AddIdentityEvent records an action in the history of an identity
+func (s *IdentityStore) AddIdentityEvent(identityID int, event api.IdentityEvent) error {
+	query := `INSERT INTO identity_events (identity_id, action, detail, created_at) VALUES (?, ?, ?, ?)`
+	if _, err := s.DB.Exec(s.rebind(query), identityID, event.Action, event.Detail, event.CreatedAt.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to insert identity event: %v", err)
+	}
+	return nil
+}
+
+// ListIdentityEvents retrieves the history of an identity, oldest first
+func (s *IdentityStore) ListIdentityEvents(identityID int) ([]api.IdentityEvent, error) {
+	query := `SELECT action, detail, created_at FROM identity_events WHERE identity_id = ? ORDER BY id`
+	rows, err := s.DB.Query(s.rebind(query), identityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []api.IdentityEvent{}
+	for rows.Next() {
+		var event api.IdentityEvent
+		var createdAt string
+		if err := rows.Scan(&event.Action, &event.Detail, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan identity event: %v", err)
+		}
+		event.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,325 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// favoriteTables are the tables with the favorites of the identities, which are shared by their account
+var favoriteTables = []string{"favorite_stops", "favorite_lines", "favorite_routes"}
+
+// insertFavoriteLines inserts the favorite lines of an identity within a transaction
+func (s *IdentityStore) insertFavoriteLines(tx *sql.Tx, identityID int, favorites []api.FavoriteLine) error {
+	for _, favorite := range favorites {
+		query := `INSERT INTO favorite_lines (identity_id, line_name) VALUES (?, ?)`
+		if _, err := tx.Exec(s.rebind(query), identityID, favorite.Name); err != nil {
+			return fmt.Errorf("failed to insert favorite line: %v", err)
+		}
+	}
+	return nil
+}
+
+// insertFavoriteRoutes inserts the favorite routes of an identity within a transaction
+func (s *IdentityStore) insertFavoriteRoutes(tx *sql.Tx, identityID int, favorites []api.FavoriteRoute) error {
+	for _, favorite := range favorites {
+		query := `INSERT INTO favorite_routes (identity_id, origin_stop_number, destination_stop_number) VALUES (?, ?, ?)`
+		if _, err := tx.Exec(s.rebind(query), identityID, favorite.Origin.StopNumber, favorite.Destination.StopNumber); err != nil {
+			return fmt.Errorf("failed to insert favorite route: %v", err)
+		}
+	}
+	return nil
+}
+
+// getFavoriteLines retrieves the favorite lines of an account in the order they were added
+func (s *IdentityStore) getFavoriteLines(accountID int) ([]api.FavoriteLine, error) {
+	query := `SELECT f.line_name FROM favorite_lines f JOIN identities i ON i.id = f.identity_id
+        WHERE i.account_id = ? AND i.deleted_at IS NULL ORDER BY f.id`
+	rows, err := s.DB.Query(s.rebind(query), accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite lines: %v", err)
+	}
+	defer rows.Close()
+
+	var favorites []api.FavoriteLine
+	for rows.Next() {
+		var favorite api.FavoriteLine
+		if err := rows.Scan(&favorite.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan favorite line: %v", err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, nil
+}
+
+// getFavoriteRoutes retrieves the favorite routes of an account in the order they were added
+func (s *IdentityStore) getFavoriteRoutes(accountID int) ([]api.FavoriteRoute, error) {
+	query := `SELECT f.origin_stop_number, f.destination_stop_number FROM favorite_routes f JOIN identities i ON i.id = f.identity_id
+        WHERE i.account_id = ? AND i.deleted_at IS NULL ORDER BY f.id`
+	rows, err := s.DB.Query(s.rebind(query), accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite routes: %v", err)
+	}
+	defer rows.Close()
+
+	var favorites []api.FavoriteRoute
+	for rows.Next() {
+		var favorite api.FavoriteRoute
+		if err := rows.Scan(&favorite.Origin.StopNumber, &favorite.Destination.StopNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan favorite route: %v", err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, nil
+}
+
+// AddFavorite adds a stop at the end of the favorite stops of the account of an identity, unless
+// the account already has it. It returns false if the stop was already a favorite
+func (s *IdentityStore) AddFavorite(identityID, stopNumber int) (bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	accountID, err := s.lockAccount(tx, identityID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	// The arguments are cast as PostgreSQL can't tell their types from the select list
+	query := `INSERT INTO favorite_stops (identity_id, stop_number, position)
+        SELECT CAST(? AS INTEGER), CAST(? AS INTEGER), (
+            SELECT COALESCE(max(f.position) + 1, 0) FROM favorite_stops f JOIN identities i ON i.id = f.identity_id
+            WHERE i.account_id = ?
+        )
+        WHERE NOT EXISTS (
+            SELECT 1 FROM favorite_stops f JOIN identities i ON i.id = f.identity_id
+            WHERE i.account_id = ? AND f.stop_number = ?
+        )
+        ON CONFLICT DO NOTHING`
+	result, err := tx.Exec(s.rebind(query), identityID, stopNumber, accountID, accountID, stopNumber)
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to insert favorite stop: %v", err)
+	}
+
+	added, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return added > 0, nil
+}
+
+// RemoveFavorite removes a stop from the favorite stops of the account of an identity. It returns
+// false if the stop wasn't a favorite
+func (s *IdentityStore) RemoveFavorite(identityID, stopNumber int) (bool, error) {
+	query := `DELETE FROM favorite_stops WHERE stop_number = ? AND identity_id IN (
+            SELECT id FROM identities WHERE account_id = (SELECT account_id FROM identities WHERE id = ?)
+        )`
+	result, err := s.DB.Exec(s.rebind(query), stopNumber, identityID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete favorite stop: %v", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return removed > 0, nil
+}
+
+// UpdateFavoriteStop changes the alias or the line filter of a favorite stop of the account of an
+// identity, leaving the fields that are nil as they are. It returns false if the stop isn't a favorite
+func (s *IdentityStore) UpdateFavoriteStop(identityID, stopNumber int, update api.FavoriteStopUpdate) (bool, error) {
+	var alias, lines sql.NullString
+	if update.Alias != nil {
+		alias = sql.NullString{String: *update.Alias, Valid: true}
+	}
+	if update.Lines != nil {
+		encoded, err := storage.EncodeLineFilter(*update.Lines)
+		if err != nil {
+			return false, err
+		}
+		lines = sql.NullString{String: encoded, Valid: true}
+	}
+
+	query := `UPDATE favorite_stops SET alias = COALESCE(?, alias), lines = COALESCE(?, lines)
+        WHERE stop_number = ? AND identity_id IN (
+            SELECT id FROM identities WHERE account_id = (SELECT account_id FROM identities WHERE id = ?)
+        )`
+	result, err := s.DB.Exec(s.rebind(query), alias, lines, stopNumber, identityID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update favorite stop: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return updated > 0, nil
+}
+
+// ReorderFavoriteStops sets the order of the favorite stops of the account of an identity, or
+// returns ErrInvalidFavoriteOrder if the stop numbers don't list every favorite stop once
+func (s *IdentityStore) ReorderFavoriteStops(identityID int, stopNumbers []int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	accountID, err := s.lockAccount(tx, identityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := `SELECT f.stop_number FROM favorite_stops f JOIN identities i ON i.id = f.identity_id
+        WHERE i.account_id = ? AND i.deleted_at IS NULL`
+	rows, err := tx.Query(s.rebind(query), accountID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to get favorite stops: %v", err)
+	}
+
+	var current []int
+	for rows.Next() {
+		var stopNumber int
+		if err := rows.Scan(&stopNumber); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return fmt.Errorf("failed to scan favorite stop: %v", err)
+		}
+		current = append(current, stopNumber)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to get favorite stops: %v", err)
+	}
+
+	// Every favorite stop must be listed once, so both lists have the same stops once sorted
+	slices.Sort(current)
+	if !slices.Equal(current, slices.Sorted(slices.Values(stopNumbers))) {
+		tx.Rollback()
+		return storage.ErrInvalidFavoriteOrder
+	}
+
+	query = `UPDATE favorite_stops SET position = ? WHERE stop_number = ? AND identity_id IN (
+            SELECT id FROM identities WHERE account_id = ?
+        )`
+	for position, stopNumber := range stopNumbers {
+		if _, err := tx.Exec(s.rebind(query), position, stopNumber, accountID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to reorder favorite stops: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// AddFavoriteLine adds a line at the end of the favorite lines of the account of an identity, unless
+// the account already has it. It returns false if the line was already a favorite
+func (s *IdentityStore) AddFavoriteLine(identityID int, lineName string) (bool, error) {
+	query := `INSERT INTO favorite_lines (identity_id, line_name)
+        SELECT CAST(? AS INTEGER), CAST(? AS TEXT)
+        WHERE NOT EXISTS (
+            SELECT 1 FROM favorite_lines f JOIN identities i ON i.id = f.identity_id
+            WHERE i.account_id = (SELECT account_id FROM identities WHERE id = ?) AND f.line_name = ?
+        )`
+	return s.addFavorite(identityID, query, identityID, lineName, identityID, lineName)
+}
+
+// RemoveFavoriteLine removes a line from the favorite lines of the account of an identity. It
+// returns false if the line wasn't a favorite
+func (s *IdentityStore) RemoveFavoriteLine(identityID int, lineName string) (bool, error) {
+	query := `DELETE FROM favorite_lines WHERE line_name = ? AND identity_id IN (
+            SELECT id FROM identities WHERE account_id = (SELECT account_id FROM identities WHERE id = ?)
+        )`
+	result, err := s.DB.Exec(s.rebind(query), lineName, identityID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete favorite line: %v", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return removed > 0, nil
+}
+
+// AddFavoriteRoute adds a route between two stops at the end of the favorite routes of the account of
+// an identity, unless the account already has it. It returns false if the route was already a favorite
+func (s *IdentityStore) AddFavoriteRoute(identityID, origin, destination int) (bool, error) {
+	query := `INSERT INTO favorite_routes (identity_id, origin_stop_number, destination_stop_number)
+        SELECT CAST(? AS INTEGER), CAST(? AS INTEGER), CAST(? AS INTEGER)
+        WHERE NOT EXISTS (
+            SELECT 1 FROM favorite_routes f JOIN identities i ON i.id = f.identity_id
+            WHERE i.account_id = (SELECT account_id FROM identities WHERE id = ?) AND f.origin_stop_number = ? AND f.destination_stop_number = ?
+        )`
+	return s.addFavorite(identityID, query, identityID, origin, destination, identityID, origin, destination)
+}
+
+// RemoveFavoriteRoute removes a route between two stops from the favorite routes of the account of an
+// identity. It returns false if the route wasn't a favorite
+func (s *IdentityStore) RemoveFavoriteRoute(identityID, origin, destination int) (bool, error) {
+	query := `DELETE FROM favorite_routes WHERE origin_stop_number = ? AND destination_stop_number = ? AND identity_id IN (
+            SELECT id FROM identities WHERE account_id = (SELECT account_id FROM identities WHERE id = ?)
+        )`
+	result, err := s.DB.Exec(s.rebind(query), origin, destination, identityID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete favorite route: %v", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return removed > 0, nil
+}
+
+// addFavorite runs the insertion of a favorite while holding the lock of the account of the identity,
+// so concurrent insertions of the same favorite can't both find it missing. It returns false if
+// nothing was inserted
+func (s *IdentityStore) addFavorite(identityID int, query string, args ...any) (bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if _, err := s.lockAccount(tx, identityID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	result, err := tx.Exec(s.rebind(query), args...)
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to insert favorite: %v", err)
+	}
+
+	added, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return added > 0, nil
+}
@@ -0,0 +1,154 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// CreateLinkCode stores a one-time code that links another identity to the account of the identity,
+// replacing its previous codes and discarding the expired ones
+func (s *IdentityStore) CreateLinkCode(identityID int, code string, expiresAt time.Time) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	query := `DELETE FROM link_codes WHERE identity_id = ? OR expires_at < ?`
+	if _, err := tx.Exec(s.rebind(query), identityID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete link codes: %v", err)
+	}
+
+	query = `INSERT INTO link_codes (code, identity_id, expires_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(s.rebind(query), code, identityID, expiresAt.UTC().Format(time.RFC3339)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to insert link code: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// LinkIdentity consumes a link code and moves the account of the identity into the account of the
+// identity that created the code. Favorites already in the target account are dropped from the
+// moved one, and the metadata of both accounts is merged, keeping the values of the target account
+func (s *IdentityStore) LinkIdentity(identityID int, code string) (*api.Identity, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	// The code is deleted as it is read, so it can only be used once
+	var issuerID int
+	var expiresAt string
+	query := `DELETE FROM link_codes WHERE code = ? RETURNING identity_id, expires_at`
+	if err := tx.QueryRow(s.rebind(query), code).Scan(&issuerID, &expiresAt); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrInvalidLinkCode
+		}
+		return nil, fmt.Errorf("failed to get link code: %v", err)
+	}
+
+	if expires, _ := time.Parse(time.RFC3339, expiresAt); time.Now().After(expires) {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return nil, storage.ErrInvalidLinkCode
+	}
+
+	var targetAccountID, sourceAccountID int
+	var targetMetadata, sourceMetadata sql.NullString
+	query = `SELECT account_id, metadata FROM identities WHERE id = ?`
+	if err := tx.QueryRow(s.rebind(query), issuerID).Scan(&targetAccountID, &targetMetadata); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to get identity: %v", err)
+	}
+	if err := tx.QueryRow(s.rebind(query), identityID).Scan(&sourceAccountID, &sourceMetadata); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to get identity: %v", err)
+	}
+
+	if targetAccountID == sourceAccountID {
+		tx.Rollback()
+		return nil, storage.ErrAlreadyLinked
+	}
+
+	query = `DELETE FROM favorite_stops
+        WHERE identity_id IN (SELECT id FROM identities WHERE account_id = ?)
+        AND stop_number IN (
+            SELECT f.stop_number FROM favorite_stops f JOIN identities i ON i.id = f.identity_id WHERE i.account_id = ?
+        )`
+	if _, err := tx.Exec(s.rebind(query), sourceAccountID, targetAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to merge favorite stops: %v", err)
+	}
+
+	query = `DELETE FROM favorite_lines
+        WHERE identity_id IN (SELECT id FROM identities WHERE account_id = ?)
+        AND line_name IN (
+            SELECT f.line_name FROM favorite_lines f JOIN identities i ON i.id = f.identity_id WHERE i.account_id = ?
+        )`
+	if _, err := tx.Exec(s.rebind(query), sourceAccountID, targetAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to merge favorite lines: %v", err)
+	}
+
+	query = `DELETE FROM favorite_routes
+        WHERE identity_id IN (SELECT id FROM identities WHERE account_id = ?)
+        AND EXISTS (
+            SELECT 1 FROM favorite_routes f JOIN identities i ON i.id = f.identity_id WHERE i.account_id = ?
+            AND f.origin_stop_number = favorite_routes.origin_stop_number
+            AND f.destination_stop_number = favorite_routes.destination_stop_number
+        )`
+	if _, err := tx.Exec(s.rebind(query), sourceAccountID, targetAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to merge favorite routes: %v", err)
+	}
+
+	// The moved favorite stops go after the ones of the target account
+	query = `UPDATE favorite_stops SET position = position + (
+            SELECT COALESCE(max(f.position) + 1, 0) FROM favorite_stops f JOIN identities i ON i.id = f.identity_id WHERE i.account_id = ?
+        ) WHERE identity_id IN (SELECT id FROM identities WHERE account_id = ?)`
+	if _, err := tx.Exec(s.rebind(query), targetAccountID, sourceAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to merge favorite stops: %v", err)
+	}
+
+	query = `UPDATE identities SET account_id = ? WHERE account_id = ?`
+	if _, err := tx.Exec(s.rebind(query), targetAccountID, sourceAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to link identities: %v", err)
+	}
+
+	query = `UPDATE identities SET metadata = ? WHERE account_id = ?`
+	merged := storage.MergeMetadata(storage.DecodeMetadata(targetMetadata.String), storage.DecodeMetadata(sourceMetadata.String))
+	metadata, err := storage.EncodeMetadata(merged)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := tx.Exec(s.rebind(query), metadata, targetAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to merge metadata: %v", err)
+	}
+
+	query = `DELETE FROM accounts WHERE id = ?`
+	if _, err := tx.Exec(s.rebind(query), sourceAccountID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to delete account: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return s.GetIdentity(identityID)
+}
@@ -0,0 +1,54 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+)
+
+// UpdateMetadata replaces the metadata of the account of an identity with the result of calling
+// update with the current one, holding the lock of the account so concurrent updates aren't lost
+func (s *IdentityStore) UpdateMetadata(identityID int, update func(current map[string]any) (map[string]any, error)) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	accountID, err := s.lockAccount(tx, identityID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var current sql.NullString
+	query := `SELECT metadata FROM identities WHERE id = ?`
+	if err := tx.QueryRow(s.rebind(query), identityID).Scan(&current); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to get metadata: %v", err)
+	}
+
+	updated, err := update(storage.DecodeMetadata(current.String))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	metadata, err := storage.EncodeMetadata(updated)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query = `UPDATE identities SET metadata = ? WHERE account_id = ?`
+	if _, err := tx.Exec(s.rebind(query), metadata, accountID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update metadata: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,349 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var _ storage.IdentityRepository = (*IdentityStore)(nil)
+var _ storage.TokenRepository = (*IdentityStore)(nil)
+
+// IdentityStore implements the identity and token repositories on any SQL database with the
+// schema of the identity migrations, using its dialect for what is not standard SQL
+type IdentityStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// InsertIdentity inserts a new identity into the database, in a new account. A deleted identity with the
+// same UUID and provider that is waiting to be purged is deleted for good first
+func (s *IdentityStore) InsertIdentity(identity *api.Identity) error {
+	metadata, err := storage.EncodeMetadata(identity.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	// A deleted identity waiting to be purged is replaced by the new one
+	var deletedID int
+	query := `SELECT id FROM identities WHERE uuid = ? AND provider = ? AND deleted_at IS NOT NULL`
+	err = tx.QueryRow(s.rebind(query), identity.UUID, identity.Provider).Scan(&deletedID)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return fmt.Errorf("failed to get deleted identity: %v", err)
+	}
+	if err == nil {
+		if err := s.deleteIdentity(tx, deletedID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	var accountID int
+	query = `INSERT INTO accounts (created_at) VALUES (?) RETURNING id`
+	if err := tx.QueryRow(s.rebind(query), time.Now().UTC().Format(time.RFC3339)).Scan(&accountID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to insert account: %v", err)
+	}
+
+	var identityID int
+	query = `INSERT INTO identities (metadata, uuid, provider, account_id) VALUES (?, ?, ?, ?) RETURNING id`
+	if err := tx.QueryRow(s.rebind(query), metadata, identity.UUID, identity.Provider, accountID).Scan(&identityID); err != nil {
+		tx.Rollback()
+		if s.Dialect.IsUniqueViolation(err) {
+			return storage.ErrIdentityExists
+		}
+		return fmt.Errorf("failed to insert identity: %v", err)
+	}
+
+	if err := s.insertFavorites(tx, identityID, identity); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	identity.ID = identityID
+	identity.AccountID = accountID
+	return nil
+}
+
+// GetIdentity retrieves an identity by ID along with the favorites and the other identities of its account
+func (s *IdentityStore) GetIdentity(id int) (*api.Identity, error) {
+	query := `SELECT id, account_id, metadata, uuid, provider FROM identities WHERE id = ? AND deleted_at IS NULL`
+	row := s.DB.QueryRow(s.rebind(query), id)
+
+	var identity api.Identity
+	var metadata sql.NullString
+	if err := row.Scan(&identity.ID, &identity.AccountID, &metadata, &identity.UUID, &identity.Provider); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No identity found
+		}
+		return nil, fmt.Errorf("failed to get identity: %v", err)
+	}
+	identity.Metadata = storage.DecodeMetadata(metadata.String)
+
+	query = `SELECT f.stop_number, f.alias, f.lines FROM favorite_stops f JOIN identities i ON i.id = f.identity_id
+        WHERE i.account_id = ? AND i.deleted_at IS NULL ORDER BY f.position, f.stop_number`
+	rows, err := s.DB.Query(s.rebind(query), identity.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite stops: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var favorite api.FavoriteStop
+		var lines string
+		if err := rows.Scan(&favorite.StopNumber, &favorite.Alias, &lines); err != nil {
+			return nil, fmt.Errorf("failed to scan favorite stop: %v", err)
+		}
+		favorite.Position = len(identity.FavoriteStops)
+		favorite.Lines = storage.DecodeLineFilter(lines)
+		identity.FavoriteStops = append(identity.FavoriteStops, favorite)
+	}
+
+	identity.FavoriteLines, err = s.getFavoriteLines(identity.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.FavoriteRoutes, err = s.getFavoriteRoutes(identity.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT provider, uuid FROM identities WHERE account_id = ? AND id != ? AND deleted_at IS NULL ORDER BY id`
+	rows, err = s.DB.Query(s.rebind(query), identity.AccountID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked identities: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var linked api.LinkedIdentity
+		if err := rows.Scan(&linked.Provider, &linked.UUID); err != nil {
+			return nil, fmt.Errorf("failed to scan linked identity: %v", err)
+		}
+		identity.LinkedIdentities = append(identity.LinkedIdentities, linked)
+	}
+
+	return &identity, nil
+}
+
+// UpdateIdentity updates an existing identity in the database, replacing the favorites and the metadata of its account
+func (s *IdentityStore) UpdateIdentity(identity *api.Identity) error {
+	metadata, err := storage.EncodeMetadata(identity.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	query := `UPDATE identities SET uuid = ?, provider = ? WHERE id = ?`
+	if _, err := tx.Exec(s.rebind(query), identity.UUID, identity.Provider, identity.ID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update identity: %v", err)
+	}
+
+	query = `UPDATE identities SET metadata = ? WHERE account_id = (SELECT account_id FROM identities WHERE id = ?)`
+	if _, err := tx.Exec(s.rebind(query), metadata, identity.ID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update metadata: %v", err)
+	}
+
+	for _, table := range favoriteTables {
+		query = `DELETE FROM ` + table + ` WHERE identity_id IN (
+            SELECT id FROM identities WHERE account_id = (SELECT account_id FROM identities WHERE id = ?)
+        )`
+		if _, err := tx.Exec(s.rebind(query), identity.ID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to delete favorites from %s: %v", table, err)
+		}
+	}
+
+	if err := s.insertFavorites(tx, identity.ID, identity); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// insertFavorites inserts the favorite stops, lines and routes of an identity within a transaction
+func (s *IdentityStore) insertFavorites(tx *sql.Tx, identityID int, identity *api.Identity) error {
+	if err := s.insertFavoriteStops(tx, identityID, identity.FavoriteStops); err != nil {
+		return err
+	}
+	if err := s.insertFavoriteLines(tx, identityID, identity.FavoriteLines); err != nil {
+		return err
+	}
+	return s.insertFavoriteRoutes(tx, identityID, identity.FavoriteRoutes)
+}
+
+// insertFavoriteStops inserts the favorite stops of an identity within a transaction, numbering their
+// positions in the given order
+func (s *IdentityStore) insertFavoriteStops(tx *sql.Tx, identityID int, favorites []api.FavoriteStop) error {
+	for position, favorite := range favorites {
+		lines, err := storage.EncodeLineFilter(favorite.Lines)
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO favorite_stops (identity_id, stop_number, alias, position, lines) VALUES (?, ?, ?, ?, ?)`
+		if _, err := tx.Exec(s.rebind(query), identityID, favorite.StopNumber, favorite.Alias, position, lines); err != nil {
+			return fmt.Errorf("failed to insert favorite stop: %v", err)
+		}
+	}
+	return nil
+}
+
+// DeleteIdentity deletes an identity by ID for good, along with its history. Its favorites are
+// handed over to another identity of the account, and the account is deleted along with its last identity
+func (s *IdentityStore) DeleteIdentity(id int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if err := s.deleteIdentity(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// deleteIdentity deletes an identity by ID for good within a transaction
+func (s *IdentityStore) deleteIdentity(tx *sql.Tx, id int) error {
+	var accountID int
+	var heirID sql.NullInt64
+	query := `SELECT account_id, (
+            SELECT min(o.id) FROM identities o WHERE o.account_id = i.account_id AND o.id != i.id AND o.deleted_at IS NULL
+        ) FROM identities i WHERE i.id = ?`
+	if err := tx.QueryRow(s.rebind(query), id).Scan(&accountID, &heirID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get identity: %v", err)
+	}
+
+	for _, table := range favoriteTables {
+		query = `DELETE FROM ` + table + ` WHERE identity_id = ?`
+		args := []any{id}
+		if heirID.Valid {
+			query = `UPDATE ` + table + ` SET identity_id = ? WHERE identity_id = ?`
+			args = []any{heirID.Int64, id}
+		}
+		if _, err := tx.Exec(s.rebind(query), args...); err != nil {
+			return fmt.Errorf("failed to delete favorites from %s: %v", table, err)
+		}
+	}
+
+	query = `DELETE FROM link_codes WHERE identity_id = ?`
+	if _, err := tx.Exec(s.rebind(query), id); err != nil {
+		return fmt.Errorf("failed to delete link codes: %v", err)
+	}
+
+	query = `DELETE FROM identity_events WHERE identity_id = ?`
+	if _, err := tx.Exec(s.rebind(query), id); err != nil {
+		return fmt.Errorf("failed to delete identity events: %v", err)
+	}
+
+	query = `DELETE FROM identities WHERE id = ?`
+	if _, err := tx.Exec(s.rebind(query), id); err != nil {
+		return fmt.Errorf("failed to delete identity: %v", err)
+	}
+
+	query = `DELETE FROM accounts WHERE id = ? AND NOT EXISTS (SELECT 1 FROM identities WHERE account_id = ?)`
+	if _, err := tx.Exec(s.rebind(query), accountID, accountID); err != nil {
+		return fmt.Errorf("failed to delete account: %v", err)
+	}
+
+	return nil
+}
+
+// GetUserByUUID retrieves an identity by UUID and provider
+func (s *IdentityStore) GetUserByUUID(provider, uuid string) (*api.Identity, error) {
+	query := `SELECT id FROM identities WHERE uuid = ? AND provider = ? AND deleted_at IS NULL`
+	row := s.DB.QueryRow(s.rebind(query), uuid, provider)
+
+	var id int
+	if err := row.Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No identity found
+		}
+		return nil, fmt.Errorf("failed to get identity: %v", err)
+	}
+
+	return s.GetIdentity(id)
+}
+
+// ListIdentities retrieves every identity that is not deleted along with the favorite stops it added
+func (s *IdentityStore) ListIdentities() ([]api.Identity, error) {
+	rows, err := s.DB.Query(`SELECT id, account_id, metadata, uuid, provider FROM identities WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identities: %v", err)
+	}
+	defer rows.Close()
+
+	var identities []api.Identity
+	index := make(map[int]int)
+	for rows.Next() {
+		var identity api.Identity
+		var metadata sql.NullString
+		if err := rows.Scan(&identity.ID, &identity.AccountID, &metadata, &identity.UUID, &identity.Provider); err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %v", err)
+		}
+		identity.Metadata = storage.DecodeMetadata(metadata.String)
+		index[identity.ID] = len(identities)
+		identities = append(identities, identity)
+	}
+
+	rows, err = s.DB.Query(`SELECT identity_id, stop_number FROM favorite_stops`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite stops: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identityID int
+		var favorite api.FavoriteStop
+		if err := rows.Scan(&identityID, &favorite.StopNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan favorite stop: %v", err)
+		}
+		if i, ok := index[identityID]; ok {
+			identities[i].FavoriteStops = append(identities[i].FavoriteStops, favorite)
+		}
+	}
+
+	return identities, nil
+}
+
+// Close closes the database connection
+func (s *IdentityStore) Close() error {
+	if err := s.DB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %v", err)
+	}
+	return nil
+}
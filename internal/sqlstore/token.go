@@ -0,0 +1,88 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// InsertToken stores a new API token along with the hash of its secret, setting its ID
+func (s *IdentityStore) InsertToken(token *api.Token, hash string) error {
+	query := `INSERT INTO api_tokens (name, token_hash, scopes, providers, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
+	err := s.DB.QueryRow(s.rebind(query), token.Name, hash, storage.EncodeScopes(token.Scopes), storage.EncodeProviders(token.Providers),
+		token.CreatedAt.UTC().Format(time.RFC3339), storage.EncodeTime(token.ExpiresAt)).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert token: %v", err)
+	}
+	return nil
+}
+
+// GetTokenByHash retrieves an API token by the hash of its secret, or nil if it doesn't exist
+func (s *IdentityStore) GetTokenByHash(hash string) (*api.Token, error) {
+	query := `SELECT id, name, scopes, providers, created_at, expires_at, revoked_at FROM api_tokens WHERE token_hash = ?`
+	token, err := scanToken(s.DB.QueryRow(s.rebind(query), hash))
+	if err == sql.ErrNoRows {
+		return nil, nil // No token found
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %v", err)
+	}
+	return token, nil
+}
+
+// ListTokens retrieves every API token, including the revoked and expired ones, sorted by ID
+func (s *IdentityStore) ListTokens() ([]api.Token, error) {
+	query := `SELECT id, name, scopes, providers, created_at, expires_at, revoked_at FROM api_tokens ORDER BY id`
+	rows, err := s.DB.Query(s.rebind(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tokens: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []api.Token{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token: %v", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeToken marks an API token as revoked, keeping when it was first revoked
+func (s *IdentityStore) RevokeToken(id int) error {
+	query := `UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`
+	result, err := s.DB.Exec(s.rebind(query), time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if revoked == 0 {
+		return storage.ErrTokenNotFound
+	}
+	return nil
+}
+
+// scanToken reads an API token from a row with its ID, name, scopes, providers, creation, expiry and revocation
+func scanToken(row interface{ Scan(...any) error }) (*api.Token, error) {
+	var token api.Token
+	var scopes, providers, createdAt string
+	var expiresAt, revokedAt sql.NullString
+	if err := row.Scan(&token.ID, &token.Name, &scopes, &providers, &createdAt, &expiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	token.Scopes = storage.DecodeScopes(scopes)
+	token.Providers = storage.DecodeProviders(providers)
+	token.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	token.ExpiresAt = storage.DecodeTime(expiresAt)
+	token.RevokedAt = storage.DecodeTime(revokedAt)
+	return &token, nil
+}
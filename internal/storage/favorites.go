@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EncodeLineFilter converts the lines a user cares about at a favorite stop to the JSON text stored in the database
+func EncodeLineFilter(lines []string) (string, error) {
+	if lines == nil {
+		lines = []string{}
+	}
+
+	encoded, err := json.Marshal(lines)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode line filter: %v", err)
+	}
+	return string(encoded), nil
+}
+
+// DecodeLineFilter converts the JSON text stored in the database to the lines a user cares about at a favorite stop
+func DecodeLineFilter(stored string) []string {
+	var lines []string
+	if err := json.Unmarshal([]byte(stored), &lines); err != nil || lines == nil {
+		return []string{}
+	}
+	return lines
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var (
+	_ storage.StopRepository = (*BusStore)(nil)
+	_ storage.LineRepository = (*BusStore)(nil)
+)
+
+// BusStore keeps the stops and lines in memory
+type BusStore struct {
+	mu         sync.RWMutex
+	stops      []api.Stop
+	lines      []api.Line
+	lineStops  map[int][]api.Stop
+	lineRoutes []api.LineRoute
+}
+
+// NewBusStore creates an empty BusStore
+func NewBusStore() *BusStore {
+	return &BusStore{lineStops: make(map[int][]api.Stop)}
+}
+
+// AddStop adds a stop, assigning it the next ID when it has none
+func (s *BusStore) AddStop(stop api.Stop) api.Stop {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stop.ID == 0 {
+		stop.ID = len(s.stops) + 1
+	}
+	s.stops = append(s.stops, stop)
+	return stop
+}
+
+// AddLine adds a line served by the given stops, assigning it the next ID when it has none
+func (s *BusStore) AddLine(line api.Line, stops ...api.Stop) api.Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if line.ID == 0 {
+		line.ID = len(s.lines) + 1
+	}
+	s.lines = append(s.lines, line)
+	s.lineStops[line.ID] = append(s.lineStops[line.ID], stops...)
+	return line
+}
+
+// AddLineRoute adds the ordered stops of a line route
+func (s *BusStore) AddLineRoute(route api.LineRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lineRoutes = append(s.lineRoutes, route)
+}
+
+// GetStops retrieves all stops
+func (s *BusStore) GetStops() ([]api.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]api.Stop(nil), s.stops...), nil
+}
+
+// GetStopByNumber retrieves a stop by the number provided by the bus company
+func (s *BusStore) GetStopByNumber(stopNumber int) (api.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, stop := range s.stops {
+		if stop.StopNumber == stopNumber {
+			return stop, nil
+		}
+	}
+	return api.Stop{}, fmt.Errorf("%w: %d", storage.ErrStopNotFound, stopNumber)
+}
+
+// FindStopsByText retrieves the stops whose name contains the text ignoring case, sorted by name
+func (s *BusStore) FindStopsByText(text string, limit, offset int) ([]api.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stops []api.Stop
+	for _, stop := range s.stops {
+		if strings.Contains(strings.ToLower(stop.Name), strings.ToLower(text)) {
+			stops = append(stops, stop)
+		}
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Name < stops[j].Name
+	})
+
+	if offset >= len(stops) {
+		return nil, nil
+	}
+	stops = stops[offset:]
+	if limit > 0 && len(stops) > limit {
+		stops = stops[:limit]
+	}
+	return stops, nil
+}
+
+// FindStopsByLocation retrieves the stops within radius meters of a location, nearest first
+func (s *BusStore) FindStopsByLocation(lat, lon, radius float64, limit int) ([]api.NearbyStop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return storage.NearestStops(lat, lon, radius, limit, s.stops), nil
+}
+
+// GetLines retrieves all lines
+func (s *BusStore) GetLines() ([]api.Line, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]api.Line(nil), s.lines...), nil
+}
+
+// GetLineByName retrieves a line by the name provided by the bus company
+func (s *BusStore) GetLineByName(name string) (api.Line, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, line := range s.lines {
+		if line.Name == name {
+			return line, nil
+		}
+	}
+	return api.Line{}, fmt.Errorf("%w: %s", storage.ErrLineNotFound, name)
+}
+
+// GetLineStops retrieves the stops served by every line, indexed by line ID
+func (s *BusStore) GetLineStops() (map[int][]api.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lineStops := make(map[int][]api.Stop, len(s.lineStops))
+	for lineID, stops := range s.lineStops {
+		lineStops[lineID] = append([]api.Stop(nil), stops...)
+	}
+	return lineStops, nil
+}
+
+// GetLineRoutes retrieves the ordered stops of every line route
+func (s *BusStore) GetLineRoutes() ([]api.LineRoute, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]api.LineRoute(nil), s.lineRoutes...), nil
+}
@@ -0,0 +1,696 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var _ storage.IdentityRepository = (*IdentityStore)(nil)
+
+// IdentityStore keeps the users in memory. Every identity holds the favorites it added, and
+// the ones of its account are gathered when it is retrieved
+type IdentityStore struct {
+	mu            sync.Mutex
+	nextID        int
+	nextAccountID int
+	identities    map[int]api.Identity
+	linkCodes     map[string]linkCode
+
+	// deleted holds when the identities waiting to be purged were deleted
+	deleted map[int]time.Time
+	events  map[int][]api.IdentityEvent
+}
+
+// linkCode is a one-time code that links an identity to the account of another one
+type linkCode struct {
+	identityID int
+	expiresAt  time.Time
+}
+
+// NewIdentityStore creates an empty IdentityStore
+func NewIdentityStore() *IdentityStore {
+	return &IdentityStore{
+		nextID:        1,
+		nextAccountID: 1,
+		identities:    make(map[int]api.Identity),
+		linkCodes:     make(map[string]linkCode),
+		deleted:       make(map[int]time.Time),
+		events:        make(map[int][]api.IdentityEvent),
+	}
+}
+
+// InsertIdentity inserts a new identity in a new account along with its favorite stops, replacing a
+// deleted identity with the same UUID and provider. It returns ErrIdentityExists if one is not deleted
+func (s *IdentityStore) InsertIdentity(identity *api.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, existing := range s.identities {
+		if existing.Provider != identity.Provider || existing.UUID != identity.UUID {
+			continue
+		}
+		if !s.isDeleted(id) {
+			return storage.ErrIdentityExists
+		}
+		s.deleteIdentity(id)
+	}
+
+	identity.ID = s.nextID
+	identity.AccountID = s.nextAccountID
+	s.nextID++
+	s.nextAccountID++
+	stored := copyIdentity(*identity)
+	for i := range stored.FavoriteStops {
+		stored.FavoriteStops[i].Position = i
+	}
+	s.identities[identity.ID] = stored
+	return nil
+}
+
+// GetIdentity retrieves an identity by ID with the favorites of its account, or nil if it doesn't exist
+func (s *IdentityStore) GetIdentity(id int) (*api.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getIdentity(id), nil
+}
+
+// getIdentity retrieves an identity by ID with the favorites and the other identities of its account,
+// numbering the positions of the favorite stops in order
+func (s *IdentityStore) getIdentity(id int) *api.Identity {
+	identity, ok := s.identities[id]
+	if !ok || s.isDeleted(id) {
+		return nil
+	}
+
+	identity = copyIdentity(identity)
+	identity.FavoriteStops = nil
+	identity.FavoriteLines = nil
+	identity.FavoriteRoutes = nil
+	for _, other := range s.sortedIdentities() {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		other = copyIdentity(other)
+		identity.FavoriteStops = append(identity.FavoriteStops, other.FavoriteStops...)
+		identity.FavoriteLines = append(identity.FavoriteLines, other.FavoriteLines...)
+		identity.FavoriteRoutes = append(identity.FavoriteRoutes, other.FavoriteRoutes...)
+		if other.ID != id {
+			identity.LinkedIdentities = append(identity.LinkedIdentities, api.LinkedIdentity{Provider: other.Provider, UUID: other.UUID})
+		}
+	}
+
+	sort.SliceStable(identity.FavoriteStops, func(i, j int) bool {
+		a, b := identity.FavoriteStops[i], identity.FavoriteStops[j]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.StopNumber < b.StopNumber
+	})
+	for i := range identity.FavoriteStops {
+		identity.FavoriteStops[i].Position = i
+	}
+	return &identity
+}
+
+// isDeleted tells whether an identity is waiting to be purged
+func (s *IdentityStore) isDeleted(id int) bool {
+	_, ok := s.deleted[id]
+	return ok
+}
+
+// sortedIdentities returns the identities that are not deleted sorted by ID
+func (s *IdentityStore) sortedIdentities() []api.Identity {
+	identities := make([]api.Identity, 0, len(s.identities))
+	for _, identity := range s.identities {
+		if !s.isDeleted(identity.ID) {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].ID < identities[j].ID
+	})
+	return identities
+}
+
+// GetUserByUUID retrieves an identity by UUID and provider, or nil if it doesn't exist
+func (s *IdentityStore) GetUserByUUID(provider, uuid string) (*api.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, identity := range s.identities {
+		if string(identity.Provider) == provider && identity.UUID == uuid && !s.isDeleted(identity.ID) {
+			return s.getIdentity(identity.ID), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListIdentities retrieves every identity that is not deleted along with the favorite stops it added, sorted by ID
+func (s *IdentityStore) ListIdentities() ([]api.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identities := s.sortedIdentities()
+	for i, identity := range identities {
+		identities[i] = copyIdentity(identity)
+	}
+	return identities, nil
+}
+
+// UpdateIdentity updates an existing identity, replacing the favorites and the metadata of its account
+func (s *IdentityStore) UpdateIdentity(identity *api.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.identities[identity.ID]
+	if !ok {
+		return fmt.Errorf("identity %d not found", identity.ID)
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID == current.AccountID {
+			other.Metadata = identity.Metadata
+			other.FavoriteStops = nil
+			other.FavoriteLines = nil
+			other.FavoriteRoutes = nil
+			s.identities[id] = other
+		}
+	}
+
+	updated := copyIdentity(*identity)
+	for i := range updated.FavoriteStops {
+		updated.FavoriteStops[i].Position = i
+	}
+	updated.AccountID = current.AccountID
+	updated.LinkedIdentities = nil
+	s.identities[identity.ID] = updated
+	return nil
+}
+
+// AddFavorite adds a stop at the end of the favorite stops of the account of an identity. It returns
+// false if the stop was already a favorite
+func (s *IdentityStore) AddFavorite(identityID, stopNumber int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	position := 0
+	for _, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		for _, favorite := range other.FavoriteStops {
+			if favorite.StopNumber == stopNumber {
+				return false, nil
+			}
+			position = max(position, favorite.Position+1)
+		}
+	}
+
+	identity.FavoriteStops = append(identity.FavoriteStops, api.FavoriteStop{
+		Stop:     api.Stop{StopNumber: stopNumber},
+		Position: position,
+		Lines:    []string{},
+	})
+	s.identities[identityID] = identity
+	return true, nil
+}
+
+// RemoveFavorite removes a stop from the favorite stops of the account of an identity. It returns
+// false if the stop wasn't a favorite
+func (s *IdentityStore) RemoveFavorite(identityID, stopNumber int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		for i, favorite := range other.FavoriteStops {
+			if favorite.StopNumber == stopNumber {
+				other.FavoriteStops = append(other.FavoriteStops[:i:i], other.FavoriteStops[i+1:]...)
+				s.identities[id] = other
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// UpdateFavoriteStop changes the alias or the line filter of a favorite stop of the account of an
+// identity, leaving the fields that are nil as they are. It returns false if the stop isn't a favorite
+func (s *IdentityStore) UpdateFavoriteStop(identityID, stopNumber int, update api.FavoriteStopUpdate) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		for i, favorite := range other.FavoriteStops {
+			if favorite.StopNumber != stopNumber {
+				continue
+			}
+			if update.Alias != nil {
+				other.FavoriteStops[i].Alias = *update.Alias
+			}
+			if update.Lines != nil {
+				other.FavoriteStops[i].Lines = append([]string{}, *update.Lines...)
+			}
+			s.identities[id] = other
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReorderFavoriteStops sets the order of the favorite stops of the account of an identity, or
+// returns ErrInvalidFavoriteOrder if the stop numbers don't list every favorite stop once
+func (s *IdentityStore) ReorderFavoriteStops(identityID int, stopNumbers []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return fmt.Errorf("identity %d not found", identityID)
+	}
+
+	positions := make(map[int]int, len(stopNumbers))
+	for position, stopNumber := range stopNumbers {
+		positions[stopNumber] = position
+	}
+
+	count := 0
+	for _, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		for _, favorite := range other.FavoriteStops {
+			if _, ok := positions[favorite.StopNumber]; !ok {
+				return storage.ErrInvalidFavoriteOrder
+			}
+			count++
+		}
+	}
+	if count != len(stopNumbers) || len(positions) != len(stopNumbers) {
+		return storage.ErrInvalidFavoriteOrder
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		for i, favorite := range other.FavoriteStops {
+			other.FavoriteStops[i].Position = positions[favorite.StopNumber]
+		}
+		s.identities[id] = other
+	}
+	return nil
+}
+
+// AddFavoriteLine adds a line at the end of the favorite lines of the account of an identity. It
+// returns false if the line was already a favorite
+func (s *IdentityStore) AddFavoriteLine(identityID int, lineName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	for _, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		if slices.ContainsFunc(other.FavoriteLines, func(favorite api.FavoriteLine) bool {
+			return favorite.Name == lineName
+		}) {
+			return false, nil
+		}
+	}
+
+	identity.FavoriteLines = append(identity.FavoriteLines, api.FavoriteLine{Line: api.Line{Name: lineName}})
+	s.identities[identityID] = identity
+	return true, nil
+}
+
+// RemoveFavoriteLine removes a line from the favorite lines of the account of an identity. It
+// returns false if the line wasn't a favorite
+func (s *IdentityStore) RemoveFavoriteLine(identityID int, lineName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		index := slices.IndexFunc(other.FavoriteLines, func(favorite api.FavoriteLine) bool {
+			return favorite.Name == lineName
+		})
+		if index != -1 {
+			other.FavoriteLines = slices.Delete(other.FavoriteLines, index, index+1)
+			s.identities[id] = other
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddFavoriteRoute adds a route between two stops at the end of the favorite routes of the account of
+// an identity. It returns false if the route was already a favorite
+func (s *IdentityStore) AddFavoriteRoute(identityID, origin, destination int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	for _, other := range s.identities {
+		if other.AccountID == identity.AccountID && slices.ContainsFunc(other.FavoriteRoutes, isRoute(origin, destination)) {
+			return false, nil
+		}
+	}
+
+	identity.FavoriteRoutes = append(identity.FavoriteRoutes, api.FavoriteRoute{
+		Origin:      api.Stop{StopNumber: origin},
+		Destination: api.Stop{StopNumber: destination},
+	})
+	s.identities[identityID] = identity
+	return true, nil
+}
+
+// RemoveFavoriteRoute removes a route between two stops from the favorite routes of the account of an
+// identity. It returns false if the route wasn't a favorite
+func (s *IdentityStore) RemoveFavoriteRoute(identityID, origin, destination int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return false, fmt.Errorf("identity %d not found", identityID)
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID != identity.AccountID {
+			continue
+		}
+		if index := slices.IndexFunc(other.FavoriteRoutes, isRoute(origin, destination)); index != -1 {
+			other.FavoriteRoutes = slices.Delete(other.FavoriteRoutes, index, index+1)
+			s.identities[id] = other
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isRoute returns a function that tells whether a favorite route goes between the given stops
+func isRoute(origin, destination int) func(api.FavoriteRoute) bool {
+	return func(favorite api.FavoriteRoute) bool {
+		return favorite.Origin.StopNumber == origin && favorite.Destination.StopNumber == destination
+	}
+}
+
+// UpdateMetadata replaces the metadata of the account of an identity with the result of calling
+// update with the current one. Updates hold the lock of the store, so none is lost
+func (s *IdentityStore) UpdateMetadata(identityID int, update func(current map[string]any) (map[string]any, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok {
+		return fmt.Errorf("identity %d not found", identityID)
+	}
+
+	updated, err := update(copyIdentity(identity).Metadata)
+	if err != nil {
+		return err
+	}
+
+	for id, other := range s.identities {
+		if other.AccountID == identity.AccountID {
+			other.Metadata = updated
+			s.identities[id] = other
+		}
+	}
+	return nil
+}
+
+// DeleteIdentity deletes an identity by ID for good along with its history, handing its favorites
+// over to another identity of the account
+func (s *IdentityStore) DeleteIdentity(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteIdentity(id)
+	return nil
+}
+
+// deleteIdentity deletes an identity by ID for good
+func (s *IdentityStore) deleteIdentity(id int) {
+	identity, ok := s.identities[id]
+	if !ok {
+		return
+	}
+	s.handOverFavorites(identity)
+	s.discardLinkCodes(id)
+	delete(s.identities, id)
+	delete(s.deleted, id)
+	delete(s.events, id)
+}
+
+// handOverFavorites moves the favorites of an identity to another identity of its account that
+// is not deleted, if any
+func (s *IdentityStore) handOverFavorites(identity api.Identity) {
+	for _, heir := range s.sortedIdentities() {
+		if heir.AccountID == identity.AccountID && heir.ID != identity.ID {
+			heir.FavoriteStops = append(heir.FavoriteStops, identity.FavoriteStops...)
+			heir.FavoriteLines = append(heir.FavoriteLines, identity.FavoriteLines...)
+			heir.FavoriteRoutes = append(heir.FavoriteRoutes, identity.FavoriteRoutes...)
+			s.identities[heir.ID] = heir
+
+			identity.FavoriteStops = nil
+			identity.FavoriteLines = nil
+			identity.FavoriteRoutes = nil
+			s.identities[identity.ID] = identity
+			return
+		}
+	}
+}
+
+// discardLinkCodes deletes the link codes created by an identity
+func (s *IdentityStore) discardLinkCodes(id int) {
+	for code, linkCode := range s.linkCodes {
+		if linkCode.identityID == id {
+			delete(s.linkCodes, code)
+		}
+	}
+}
+
+// SoftDeleteIdentity marks an identity as deleted, hiding it until it is purged. Its favorites
+// are handed over to another identity of the account
+func (s *IdentityStore) SoftDeleteIdentity(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[id]
+	if !ok {
+		return fmt.Errorf("identity %d not found", id)
+	}
+	s.handOverFavorites(identity)
+	s.discardLinkCodes(id)
+	s.deleted[id] = time.Now()
+	return nil
+}
+
+// PurgeDeletedIdentities deletes for good the identities marked as deleted before the given time
+func (s *IdentityStore) PurgeDeletedIdentities(deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for id, deletedAt := range s.deleted {
+		if deletedAt.Before(deletedBefore) {
+			s.deleteIdentity(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// AddIdentityEvent records an action in the history of an identity
+func (s *IdentityStore) AddIdentityEvent(identityID int, event api.IdentityEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[identityID] = append(s.events[identityID], event)
+	return nil
+}
+
+// ListIdentityEvents retrieves the history of an identity, oldest first
+func (s *IdentityStore) ListIdentityEvents(identityID int) ([]api.IdentityEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]api.IdentityEvent{}, s.events[identityID]...), nil
+}
+
+// CreateLinkCode stores a one-time code that links another identity to the account of the identity,
+// replacing its previous codes
+func (s *IdentityStore) CreateLinkCode(identityID int, code string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for existing, linkCode := range s.linkCodes {
+		if linkCode.identityID == identityID || time.Now().After(linkCode.expiresAt) {
+			delete(s.linkCodes, existing)
+		}
+	}
+	s.linkCodes[code] = linkCode{identityID: identityID, expiresAt: expiresAt}
+	return nil
+}
+
+// LinkIdentity consumes a link code and moves the account of the identity into the account of the
+// identity that created the code, merging their favorites and metadata
+func (s *IdentityStore) LinkIdentity(identityID int, code string) (*api.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linkCode, ok := s.linkCodes[code]
+	if !ok {
+		return nil, storage.ErrInvalidLinkCode
+	}
+	delete(s.linkCodes, code)
+	if time.Now().After(linkCode.expiresAt) {
+		return nil, storage.ErrInvalidLinkCode
+	}
+
+	target, ok := s.identities[linkCode.identityID]
+	if !ok {
+		return nil, fmt.Errorf("identity %d not found", linkCode.identityID)
+	}
+	source, ok := s.identities[identityID]
+	if !ok {
+		return nil, fmt.Errorf("identity %d not found", identityID)
+	}
+	if target.AccountID == source.AccountID {
+		s.linkCodes[code] = linkCode
+		return nil, storage.ErrAlreadyLinked
+	}
+
+	current := s.getIdentity(target.ID)
+	favorites := make(map[int]bool)
+	for _, stop := range current.FavoriteStops {
+		favorites[stop.StopNumber] = true
+	}
+	favoriteLines := make(map[string]bool)
+	for _, line := range current.FavoriteLines {
+		favoriteLines[line.Name] = true
+	}
+	favoriteRoutes := make(map[[2]int]bool)
+	for _, route := range current.FavoriteRoutes {
+		favoriteRoutes[[2]int{route.Origin.StopNumber, route.Destination.StopNumber}] = true
+	}
+
+	metadata := storage.MergeMetadata(target.Metadata, source.Metadata)
+	for id, identity := range s.identities {
+		switch identity.AccountID {
+		case source.AccountID:
+			// The moved favorite stops go after the ones of the target account
+			var kept []api.FavoriteStop
+			for _, favorite := range identity.FavoriteStops {
+				if !favorites[favorite.StopNumber] {
+					favorite.Position += len(favorites)
+					kept = append(kept, favorite)
+				}
+			}
+			identity.FavoriteStops = kept
+
+			var keptLines []api.FavoriteLine
+			for _, favorite := range identity.FavoriteLines {
+				if !favoriteLines[favorite.Name] {
+					keptLines = append(keptLines, favorite)
+				}
+			}
+			identity.FavoriteLines = keptLines
+
+			var keptRoutes []api.FavoriteRoute
+			for _, favorite := range identity.FavoriteRoutes {
+				if !favoriteRoutes[[2]int{favorite.Origin.StopNumber, favorite.Destination.StopNumber}] {
+					keptRoutes = append(keptRoutes, favorite)
+				}
+			}
+			identity.FavoriteRoutes = keptRoutes
+			identity.AccountID = target.AccountID
+		case target.AccountID:
+		default:
+			continue
+		}
+		identity.Metadata = metadata
+		s.identities[id] = identity
+	}
+
+	return s.getIdentity(identityID), nil
+}
+
+// copyIdentity returns a copy of the identity that doesn't share its favorites nor its metadata,
+// keeping only the stop numbers, the line names and the user settings of the favorites like the database does
+func copyIdentity(identity api.Identity) api.Identity {
+	var favorites []api.FavoriteStop
+	for _, favorite := range identity.FavoriteStops {
+		favorites = append(favorites, api.FavoriteStop{
+			Stop:     api.Stop{StopNumber: favorite.StopNumber},
+			Alias:    favorite.Alias,
+			Position: favorite.Position,
+			Lines:    append([]string{}, favorite.Lines...),
+		})
+	}
+	identity.FavoriteStops = favorites
+
+	var lines []api.FavoriteLine
+	for _, favorite := range identity.FavoriteLines {
+		lines = append(lines, api.FavoriteLine{Line: api.Line{Name: favorite.Name}})
+	}
+	identity.FavoriteLines = lines
+
+	var routes []api.FavoriteRoute
+	for _, favorite := range identity.FavoriteRoutes {
+		routes = append(routes, api.FavoriteRoute{
+			Origin:      api.Stop{StopNumber: favorite.Origin.StopNumber},
+			Destination: api.Stop{StopNumber: favorite.Destination.StopNumber},
+		})
+	}
+	identity.FavoriteRoutes = routes
+
+	encoded, _ := storage.EncodeMetadata(identity.Metadata)
+	identity.Metadata = storage.DecodeMetadata(encoded)
+	return identity
+}
@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/internal/storage/storagetest"
+)
+
+func TestIdentityStore(t *testing.T) {
+	storagetest.IdentityRepository(t, func(t *testing.T) storage.IdentityRepository {
+		return NewIdentityStore()
+	})
+}
+
+func TestTokenStore(t *testing.T) {
+	storagetest.TokenRepository(t, func(t *testing.T) storage.TokenRepository {
+		return NewTokenStore()
+	})
+}
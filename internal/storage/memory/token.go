@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var _ storage.TokenRepository = (*TokenStore)(nil)
+
+// TokenStore keeps the API tokens in memory, indexed by the hash of their secret
+type TokenStore struct {
+	mu     sync.Mutex
+	nextID int
+	tokens map[string]api.Token
+}
+
+// NewTokenStore creates an empty TokenStore
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		nextID: 1,
+		tokens: make(map[string]api.Token),
+	}
+}
+
+// InsertToken stores a new API token along with the hash of its secret, setting its ID
+func (s *TokenStore) InsertToken(token *api.Token, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ID = s.nextID
+	s.nextID++
+	s.tokens[hash] = copyToken(*token)
+	return nil
+}
+
+// GetTokenByHash retrieves an API token by the hash of its secret, or nil if it doesn't exist
+func (s *TokenStore) GetTokenByHash(hash string) (*api.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, nil
+	}
+	token = copyToken(token)
+	return &token, nil
+}
+
+// ListTokens retrieves every API token, including the revoked and expired ones, sorted by ID
+func (s *TokenStore) ListTokens() ([]api.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []api.Token{}
+	for _, token := range s.tokens {
+		tokens = append(tokens, copyToken(token))
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// RevokeToken marks an API token as revoked, keeping when it was first revoked
+func (s *TokenStore) RevokeToken(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.ID != id {
+			continue
+		}
+		if token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			s.tokens[hash] = token
+		}
+		return nil
+	}
+	return storage.ErrTokenNotFound
+}
+
+// copyToken returns a copy of the token that doesn't share its scopes nor its providers
+func copyToken(token api.Token) api.Token {
+	token.Scopes = append([]api.Scope{}, token.Scopes...)
+	token.Providers = append([]api.ProviderType{}, token.Providers...)
+	return token
+}
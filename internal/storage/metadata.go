@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// legacyMetadataKey is the key that holds the metadata stored before it had to be a JSON object
+const legacyMetadataKey = "legacy"
+
+// EncodeMetadata converts the metadata of an identity to the JSON text stored in the database
+func EncodeMetadata(metadata map[string]any) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %v", err)
+	}
+	return string(encoded), nil
+}
+
+// DecodeMetadata converts the JSON text stored in the database to the metadata of an identity. Metadata
+// stored before it had to be a JSON object is kept as a string under the legacy key
+func DecodeMetadata(stored string) map[string]any {
+	metadata := make(map[string]any)
+	if stored == "" {
+		return metadata
+	}
+
+	if err := json.Unmarshal([]byte(stored), &metadata); err != nil || metadata == nil {
+		return map[string]any{legacyMetadataKey: stored}
+	}
+	return metadata
+}
+
+// MergeMetadata merges the metadata of two accounts being linked, keeping the value of the account
+// linked to when both have the same key
+func MergeMetadata(target, source map[string]any) map[string]any {
+	merged := make(map[string]any, len(target)+len(source))
+	for key, value := range source {
+		merged[key] = value
+	}
+	for key, value := range target {
+		merged[key] = value
+	}
+	return merged
+}
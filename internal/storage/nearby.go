@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"math"
+	"sort"
+
+	"github.com/eryalito/vigo-bus-core/internal/geo"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// NearestStops filters the stops within radius meters of a location and sorts them by distance,
+// truncating the result to limit stops when limit is greater than 0
+func NearestStops(lat, lon, radius float64, limit int, stops []api.Stop) []api.NearbyStop {
+	var nearby []api.NearbyStop
+	for _, stop := range stops {
+		distance := geo.Distance(lat, lon, stop.Location.Lat, stop.Location.Lon)
+		if distance > radius {
+			continue
+		}
+
+		bearing := geo.Bearing(lat, lon, stop.Location.Lat, stop.Location.Lon)
+		nearby = append(nearby, api.NearbyStop{
+			Stop:           stop,
+			Distance:       math.Round(distance),
+			Bearing:        math.Round(bearing),
+			Compass:        geo.CompassPoint(bearing),
+			WalkingMinutes: geo.WalkingMinutes(distance),
+		})
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].Distance < nearby[j].Distance
+	})
+
+	if limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+
+	return nearby
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/migrate"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+var (
+	// ErrStopNotFound is returned when no stop has the requested stop number
+	ErrStopNotFound = errors.New("stop not found")
+
+	// ErrLineNotFound is returned when no line has the requested name
+	ErrLineNotFound = errors.New("line not found")
+
+	// ErrInvalidLinkCode is returned when a link code doesn't exist, was already used or has expired
+	ErrInvalidLinkCode = errors.New("invalid link code")
+
+	// ErrAlreadyLinked is returned when linking identities that already belong to the same account
+	ErrAlreadyLinked = errors.New("identities already linked")
+
+	// ErrIdentityExists is returned when inserting an identity with the UUID and provider of another one
+	ErrIdentityExists = errors.New("identity already exists")
+
+	// ErrInvalidFavoriteOrder is returned when a new order of the favorite stops doesn't list every one of them once
+	ErrInvalidFavoriteOrder = errors.New("invalid favorite order")
+
+	// ErrTokenNotFound is returned when no API token has the requested ID
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrInvalidDataset is returned when a stops database file fails the checks made before serving it
+	ErrInvalidDataset = errors.New("invalid stops database")
+)
+
+// StopRepository gives access to the bus stops
+type StopRepository interface {
+	// GetStops retrieves all stops
+	GetStops() ([]api.Stop, error)
+
+	// GetStopByNumber retrieves a stop by the number provided by the bus company, or ErrStopNotFound if it doesn't exist
+	GetStopByNumber(stopNumber int) (api.Stop, error)
+
+	// FindStopsByText retrieves the stops whose name matches the text, ordered by relevance. A limit of 0 returns every match
+	FindStopsByText(text string, limit, offset int) ([]api.Stop, error)
+
+	// FindStopsByLocation retrieves the stops within radius meters of a location, nearest first. A limit of 0 returns every stop
+	FindStopsByLocation(lat, lon, radius float64, limit int) ([]api.NearbyStop, error)
+}
+
+// LineRepository gives access to the bus lines and the stops they go through
+type LineRepository interface {
+	// GetLines retrieves all lines
+	GetLines() ([]api.Line, error)
+
+	// GetLineByName retrieves a line by the name provided by the bus company, or ErrLineNotFound if it doesn't exist
+	GetLineByName(name string) (api.Line, error)
+
+	// GetLineStops retrieves the stops served by every line, indexed by line ID
+	GetLineStops() (map[int][]api.Stop, error)
+
+	// GetLineRoutes retrieves the ordered stops of every line route with a known order
+	GetLineRoutes() ([]api.LineRoute, error)
+}
+
+// IdentityRepository gives access to the users and their favorite stops. Every identity belongs to
+// an account, whose identities share the favorite stops and the metadata
+type IdentityRepository interface {
+	// InsertIdentity inserts a new identity in a new account along with its favorite stops, or returns
+	// ErrIdentityExists if another identity that is not deleted has the same UUID and provider
+	InsertIdentity(identity *api.Identity) error
+
+	// GetIdentity retrieves an identity by ID with the favorite stops of its account, or nil if it doesn't exist or is deleted
+	GetIdentity(id int) (*api.Identity, error)
+
+	// GetUserByUUID retrieves an identity by UUID and provider, or nil if it doesn't exist or is deleted
+	GetUserByUUID(provider, uuid string) (*api.Identity, error)
+
+	// ListIdentities retrieves every identity that is not deleted along with the favorite stops it added
+	ListIdentities() ([]api.Identity, error)
+
+	// UpdateIdentity updates an existing identity, replacing the favorite stops and the metadata of its account
+	UpdateIdentity(identity *api.Identity) error
+
+	// AddFavorite adds a stop at the end of the favorite stops of the account of an identity in a
+	// single step, so concurrent changes aren't lost. It returns false if the stop was already a favorite
+	AddFavorite(identityID, stopNumber int) (bool, error)
+
+	// RemoveFavorite removes a stop from the favorite stops of the account of an identity in a single
+	// step, so concurrent changes aren't lost. It returns false if the stop wasn't a favorite
+	RemoveFavorite(identityID, stopNumber int) (bool, error)
+
+	// UpdateFavoriteStop changes the alias or the line filter of a favorite stop of the account of an
+	// identity, leaving the fields that are nil as they are. It returns false if the stop isn't a favorite
+	UpdateFavoriteStop(identityID, stopNumber int, update api.FavoriteStopUpdate) (bool, error)
+
+	// ReorderFavoriteStops sets the order of the favorite stops of the account of an identity, or
+	// returns ErrInvalidFavoriteOrder if the stop numbers don't list every favorite stop once
+	ReorderFavoriteStops(identityID int, stopNumbers []int) error
+
+	// AddFavoriteLine adds a line at the end of the favorite lines of the account of an identity in a
+	// single step. It returns false if the line was already a favorite
+	AddFavoriteLine(identityID int, lineName string) (bool, error)
+
+	// RemoveFavoriteLine removes a line from the favorite lines of the account of an identity. It
+	// returns false if the line wasn't a favorite
+	RemoveFavoriteLine(identityID int, lineName string) (bool, error)
+
+	// AddFavoriteRoute adds a route between two stops at the end of the favorite routes of the account
+	// of an identity in a single step. It returns false if the route was already a favorite
+	AddFavoriteRoute(identityID, origin, destination int) (bool, error)
+
+	// RemoveFavoriteRoute removes a route between two stops from the favorite routes of the account of
+	// an identity. It returns false if the route wasn't a favorite
+	RemoveFavoriteRoute(identityID, origin, destination int) (bool, error)
+
+	// UpdateMetadata replaces the metadata of the account of an identity with the result of calling
+	// update with the current one. Concurrent updates of the account wait for each other so none is
+	// lost, and an error returned by update is returned as is, leaving the metadata unchanged
+	UpdateMetadata(identityID int, update func(current map[string]any) (map[string]any, error)) error
+
+	// DeleteIdentity deletes an identity by ID for good, along with its history. Its favorite stops
+	// are kept by the rest of the account, if any
+	DeleteIdentity(id int) error
+
+	// SoftDeleteIdentity marks an identity as deleted, hiding it until it is purged. Its favorite
+	// stops are handed over to the rest of the account, if any
+	SoftDeleteIdentity(id int) error
+
+	// PurgeDeletedIdentities deletes for good the identities marked as deleted before the given time,
+	// returning how many were purged
+	PurgeDeletedIdentities(deletedBefore time.Time) (int, error)
+
+	// AddIdentityEvent records an action in the history of an identity
+	AddIdentityEvent(identityID int, event api.IdentityEvent) error
+
+	// ListIdentityEvents retrieves the history of an identity, oldest first
+	ListIdentityEvents(identityID int) ([]api.IdentityEvent, error)
+
+	// CreateLinkCode stores a one-time code that links another identity to the account of the
+	// identity, replacing the previous codes of the identity
+	CreateLinkCode(identityID int, code string, expiresAt time.Time) error
+
+	// LinkIdentity consumes a link code and moves the identity, along with the rest of its account,
+	// to the account of the identity that created the code, merging their favorite stops and metadata.
+	// It returns ErrInvalidLinkCode if the code can't be used and ErrAlreadyLinked if both are in the same account
+	LinkIdentity(identityID int, code string) (*api.Identity, error)
+}
+
+// TokenRepository gives access to the API tokens of the clients, which are stored by their hash
+type TokenRepository interface {
+	// InsertToken stores a new API token along with the hash of its secret, setting its ID
+	InsertToken(token *api.Token, hash string) error
+
+	// GetTokenByHash retrieves an API token by the hash of its secret, even if it is revoked or
+	// expired, or nil if it doesn't exist
+	GetTokenByHash(hash string) (*api.Token, error)
+
+	// ListTokens retrieves every API token, including the revoked and expired ones, sorted by ID
+	ListTokens() ([]api.Token, error)
+
+	// RevokeToken marks an API token as revoked, keeping when it was first revoked, or returns
+	// ErrTokenNotFound if it doesn't exist
+	RevokeToken(id int) error
+}
+
+// IdentityDatabase is an identity repository backed by a database connection that has to be closed.
+// It also holds the API tokens
+type IdentityDatabase interface {
+	IdentityRepository
+	TokenRepository
+
+	// Migrator returns the migrator of the database schema
+	Migrator() (*migrate.Migrator, error)
+
+	// Close closes the database connection
+	Close() error
+}
+
+// ReloadableDataset is the stops and lines dataset, which can be reloaded from its source
+type ReloadableDataset interface {
+	// Dataset retrieves the size of the loaded dataset and when it was loaded
+	Dataset() (api.Dataset, error)
+
+	// DatasetChanges retrieves the changes made by the imports after the given version, or after the given time when it isn't zero
+	DatasetChanges(sinceVersion int, sinceTime time.Time) (api.DatasetChanges, error)
+
+	// Reload loads the dataset again from its source
+	Reload() error
+}
+
+// DatasetStop is a stop as published in the open data dataset, along with the names of the lines that serve it
+type DatasetStop struct {
+	api.Stop
+
+	// Lines is the list of names of the lines that serve the stop
+	Lines []string
+}
@@ -0,0 +1,556 @@
+// Package storagetest checks that the implementations of the storage repositories behave the same way
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/internal/storage"
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// IdentityDatabase runs the checks of the identity and token repositories on an identity database,
+// along with its migrations. open returns an empty database with every migration applied
+func IdentityDatabase(t *testing.T, open func(t *testing.T) storage.IdentityDatabase) {
+	t.Run("Migrations", func(t *testing.T) {
+		testMigrations(t, open(t))
+	})
+
+	IdentityRepository(t, func(t *testing.T) storage.IdentityRepository {
+		return open(t)
+	})
+	TokenRepository(t, func(t *testing.T) storage.TokenRepository {
+		return open(t)
+	})
+}
+
+// testMigrations checks that the migrations of a database are applied and can be reverted and applied again
+func testMigrations(t *testing.T, db storage.IdentityDatabase) {
+	migrator, err := db.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Fatalf("expected migration %d_%s to be applied", status.Version, status.Name)
+		}
+	}
+
+	if applied, err := migrator.Up(); err != nil || applied != 0 {
+		t.Fatalf("expected no pending migrations, got %d: %v", applied, err)
+	}
+	if reverted, err := migrator.Down(1); err != nil || reverted != 1 {
+		t.Fatalf("expected to revert 1 migration, got %d: %v", reverted, err)
+	}
+	if applied, err := migrator.Up(); err != nil || applied != 1 {
+		t.Fatalf("expected to apply 1 migration, got %d: %v", applied, err)
+	}
+
+	// The schema works after reverting and applying the latest migration again
+	user := insertUser(t, db, api.ProviderTypeTelegram, "1")
+	if _, err := db.AddFavoriteLine(user.ID, "C1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertToken(&api.Token{Name: "bot", CreatedAt: time.Now()}, "hash"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// IdentityRepository runs the checks of an identity repository. newRepository returns an empty repository
+func IdentityRepository(t *testing.T, newRepository func(t *testing.T) storage.IdentityRepository) {
+	t.Run("InsertIdentity", func(t *testing.T) {
+		testInsertIdentity(t, newRepository(t))
+	})
+	t.Run("FavoriteStops", func(t *testing.T) {
+		testFavoriteStops(t, newRepository(t))
+	})
+	t.Run("FavoriteLinesAndRoutes", func(t *testing.T) {
+		testFavoriteLinesAndRoutes(t, newRepository(t))
+	})
+	t.Run("UpdateMetadata", func(t *testing.T) {
+		testUpdateMetadata(t, newRepository(t))
+	})
+	t.Run("LinkIdentity", func(t *testing.T) {
+		testLinkIdentity(t, newRepository(t))
+	})
+	t.Run("DeleteIdentity", func(t *testing.T) {
+		testDeleteIdentity(t, newRepository(t))
+	})
+	t.Run("IdentityEvents", func(t *testing.T) {
+		testIdentityEvents(t, newRepository(t))
+	})
+}
+
+func testInsertIdentity(t *testing.T, repo storage.IdentityRepository) {
+	identity := &api.Identity{
+		Provider:      api.ProviderTypeTelegram,
+		UUID:          "1",
+		FavoriteStops: []api.FavoriteStop{{Stop: api.Stop{StopNumber: 101}, Alias: "Home", Lines: []string{"C1"}}, {Stop: api.Stop{StopNumber: 100}}},
+		FavoriteLines: []api.FavoriteLine{{Line: api.Line{Name: "C1"}}},
+		Metadata:      map[string]any{"lang": "gl"},
+	}
+	if err := repo.InsertIdentity(identity); err != nil {
+		t.Fatal(err)
+	}
+	if identity.ID == 0 {
+		t.Fatal("expected the identity ID to be set")
+	}
+
+	user := getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if user.ID != identity.ID || user.AccountID == 0 {
+		t.Fatalf("expected identity %d with an account, got %+v", identity.ID, user)
+	}
+	if numbers := stopNumbers(user); !slices.Equal(numbers, []int{101, 100}) {
+		t.Fatalf("expected favorite stops [101 100], got %v", numbers)
+	}
+	if favorite := user.FavoriteStops[0]; favorite.Alias != "Home" || !slices.Equal(favorite.Lines, []string{"C1"}) {
+		t.Fatalf("expected alias Home and lines [C1], got %+v", favorite)
+	}
+	if len(user.FavoriteLines) != 1 || user.FavoriteLines[0].Name != "C1" {
+		t.Fatalf("expected favorite lines [C1], got %+v", user.FavoriteLines)
+	}
+	if !reflect.DeepEqual(user.Metadata, map[string]any{"lang": "gl"}) {
+		t.Fatalf("expected metadata lang=gl, got %v", user.Metadata)
+	}
+
+	byID, err := repo.GetIdentity(identity.ID)
+	if err != nil || byID == nil || byID.UUID != "1" {
+		t.Fatalf("expected identity %d by ID, got %+v: %v", identity.ID, byID, err)
+	}
+
+	if missing, err := repo.GetUserByUUID(string(api.ProviderTypeDiscord), "1"); err != nil || missing != nil {
+		t.Fatalf("expected no identity for another provider, got %+v: %v", missing, err)
+	}
+	if missing, err := repo.GetIdentity(identity.ID + 100); err != nil || missing != nil {
+		t.Fatalf("expected no identity for an unknown ID, got %+v: %v", missing, err)
+	}
+
+	err = repo.InsertIdentity(&api.Identity{Provider: api.ProviderTypeTelegram, UUID: "1"})
+	if !errors.Is(err, storage.ErrIdentityExists) {
+		t.Fatalf("expected ErrIdentityExists, got %v", err)
+	}
+
+	insertUser(t, repo, api.ProviderTypeDiscord, "1")
+	identities, err := repo.ListIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 2 || identities[0].ID != identity.ID || len(identities[0].FavoriteStops) != 2 {
+		t.Fatalf("expected 2 identities, the first with 2 favorite stops, got %+v", identities)
+	}
+}
+
+func testFavoriteStops(t *testing.T, repo storage.IdentityRepository) {
+	user := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+
+	for _, stopNumber := range []int{100, 101, 102} {
+		if added, err := repo.AddFavorite(user.ID, stopNumber); err != nil || !added {
+			t.Fatalf("expected stop %d to be added: %v", stopNumber, err)
+		}
+	}
+	if added, err := repo.AddFavorite(user.ID, 101); err != nil || added {
+		t.Fatalf("expected stop 101 to be a favorite already: %v", err)
+	}
+
+	if removed, err := repo.RemoveFavorite(user.ID, 101); err != nil || !removed {
+		t.Fatalf("expected stop 101 to be removed: %v", err)
+	}
+	if removed, err := repo.RemoveFavorite(user.ID, 101); err != nil || removed {
+		t.Fatalf("expected stop 101 not to be a favorite: %v", err)
+	}
+
+	// Added stops go at the end even after others were removed
+	if _, err := repo.AddFavorite(user.ID, 101); err != nil {
+		t.Fatal(err)
+	}
+	user = getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if numbers := stopNumbers(user); !slices.Equal(numbers, []int{100, 102, 101}) {
+		t.Fatalf("expected favorite stops [100 102 101], got %v", numbers)
+	}
+	for i, favorite := range user.FavoriteStops {
+		if favorite.Position != i {
+			t.Fatalf("expected stop %d at position %d, got %d", favorite.StopNumber, i, favorite.Position)
+		}
+	}
+
+	alias, lines := "Home", []string{"C1", "L5"}
+	if updated, err := repo.UpdateFavoriteStop(user.ID, 102, api.FavoriteStopUpdate{Alias: &alias, Lines: &lines}); err != nil || !updated {
+		t.Fatalf("expected stop 102 to be updated: %v", err)
+	}
+	noLines := []string{}
+	if updated, err := repo.UpdateFavoriteStop(user.ID, 102, api.FavoriteStopUpdate{Lines: &noLines}); err != nil || !updated {
+		t.Fatalf("expected stop 102 to be updated: %v", err)
+	}
+	if updated, err := repo.UpdateFavoriteStop(user.ID, 999, api.FavoriteStopUpdate{Alias: &alias}); err != nil || updated {
+		t.Fatalf("expected stop 999 not to be a favorite: %v", err)
+	}
+	user = getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if favorite := user.FavoriteStops[1]; favorite.Alias != "Home" || len(favorite.Lines) != 0 {
+		t.Fatalf("expected alias Home and no lines, got %+v", favorite)
+	}
+
+	if err := repo.ReorderFavoriteStops(user.ID, []int{101, 100, 102}); err != nil {
+		t.Fatal(err)
+	}
+	user = getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if numbers := stopNumbers(user); !slices.Equal(numbers, []int{101, 100, 102}) {
+		t.Fatalf("expected favorite stops [101 100 102], got %v", numbers)
+	}
+
+	for _, order := range [][]int{{101, 100}, {101, 100, 100}, {101, 100, 102, 103}, {101, 100, 103}} {
+		if err := repo.ReorderFavoriteStops(user.ID, order); !errors.Is(err, storage.ErrInvalidFavoriteOrder) {
+			t.Fatalf("expected ErrInvalidFavoriteOrder for %v, got %v", order, err)
+		}
+	}
+}
+
+func testFavoriteLinesAndRoutes(t *testing.T, repo storage.IdentityRepository) {
+	user := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+
+	for _, name := range []string{"L5", "C1"} {
+		if added, err := repo.AddFavoriteLine(user.ID, name); err != nil || !added {
+			t.Fatalf("expected line %s to be added: %v", name, err)
+		}
+	}
+	if added, err := repo.AddFavoriteLine(user.ID, "C1"); err != nil || added {
+		t.Fatalf("expected line C1 to be a favorite already: %v", err)
+	}
+
+	for _, route := range [][2]int{{100, 101}, {101, 100}} {
+		if added, err := repo.AddFavoriteRoute(user.ID, route[0], route[1]); err != nil || !added {
+			t.Fatalf("expected route %v to be added: %v", route, err)
+		}
+	}
+	if added, err := repo.AddFavoriteRoute(user.ID, 100, 101); err != nil || added {
+		t.Fatalf("expected route 100-101 to be a favorite already: %v", err)
+	}
+
+	user = getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if len(user.FavoriteLines) != 2 || user.FavoriteLines[0].Name != "L5" || user.FavoriteLines[1].Name != "C1" {
+		t.Fatalf("expected favorite lines [L5 C1], got %+v", user.FavoriteLines)
+	}
+	if len(user.FavoriteRoutes) != 2 || user.FavoriteRoutes[1].Origin.StopNumber != 101 || user.FavoriteRoutes[1].Destination.StopNumber != 100 {
+		t.Fatalf("expected favorite routes 100-101 and 101-100, got %+v", user.FavoriteRoutes)
+	}
+
+	if removed, err := repo.RemoveFavoriteLine(user.ID, "L5"); err != nil || !removed {
+		t.Fatalf("expected line L5 to be removed: %v", err)
+	}
+	if removed, err := repo.RemoveFavoriteLine(user.ID, "L5"); err != nil || removed {
+		t.Fatalf("expected line L5 not to be a favorite: %v", err)
+	}
+	if removed, err := repo.RemoveFavoriteRoute(user.ID, 100, 101); err != nil || !removed {
+		t.Fatalf("expected route 100-101 to be removed: %v", err)
+	}
+	if removed, err := repo.RemoveFavoriteRoute(user.ID, 100, 101); err != nil || removed {
+		t.Fatalf("expected route 100-101 not to be a favorite: %v", err)
+	}
+
+	user = getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if len(user.FavoriteLines) != 1 || len(user.FavoriteRoutes) != 1 {
+		t.Fatalf("expected 1 favorite line and route, got %+v and %+v", user.FavoriteLines, user.FavoriteRoutes)
+	}
+}
+
+func testUpdateMetadata(t *testing.T, repo storage.IdentityRepository) {
+	user := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+
+	err := repo.UpdateMetadata(user.ID, func(current map[string]any) (map[string]any, error) {
+		if len(current) != 0 {
+			t.Errorf("expected no metadata, got %v", current)
+		}
+		return map[string]any{"lang": "gl"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An error from the update is returned as is, leaving the metadata unchanged
+	errInvalid := errors.New("invalid")
+	err = repo.UpdateMetadata(user.ID, func(current map[string]any) (map[string]any, error) {
+		if !reflect.DeepEqual(current, map[string]any{"lang": "gl"}) {
+			t.Errorf("expected metadata lang=gl, got %v", current)
+		}
+		return nil, errInvalid
+	})
+	if err != errInvalid {
+		t.Fatalf("expected the error of the update, got %v", err)
+	}
+
+	user = getUser(t, repo, api.ProviderTypeTelegram, "1")
+	if !reflect.DeepEqual(user.Metadata, map[string]any{"lang": "gl"}) {
+		t.Fatalf("expected metadata lang=gl, got %v", user.Metadata)
+	}
+}
+
+func testLinkIdentity(t *testing.T, repo storage.IdentityRepository) {
+	target := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+	source := insertUser(t, repo, api.ProviderTypeDiscord, "1")
+
+	for _, stopNumber := range []int{100, 101} {
+		if _, err := repo.AddFavorite(target.ID, stopNumber); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, stopNumber := range []int{101, 102} {
+		if _, err := repo.AddFavorite(source.ID, stopNumber); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.AddFavoriteLine(target.ID, "C1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddFavoriteLine(source.ID, "C1"); err != nil {
+		t.Fatal(err)
+	}
+	setMetadata(t, repo, target.ID, map[string]any{"lang": "gl"})
+	setMetadata(t, repo, source.ID, map[string]any{"lang": "es", "theme": "dark"})
+
+	if err := repo.CreateLinkCode(target.ID, "EXPIRED", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.LinkIdentity(source.ID, "EXPIRED"); !errors.Is(err, storage.ErrInvalidLinkCode) {
+		t.Fatalf("expected ErrInvalidLinkCode for an expired code, got %v", err)
+	}
+
+	if err := repo.CreateLinkCode(target.ID, "CODE", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := repo.LinkIdentity(source.ID, "CODE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The favorites are merged without duplicates, and the metadata of the target wins
+	if linked.AccountID != getUser(t, repo, api.ProviderTypeTelegram, "1").AccountID {
+		t.Fatalf("expected both identities in the same account")
+	}
+	if numbers := stopNumbers(*linked); !slices.Equal(numbers, []int{100, 101, 102}) {
+		t.Fatalf("expected favorite stops [100 101 102], got %v", numbers)
+	}
+	if len(linked.FavoriteLines) != 1 {
+		t.Fatalf("expected favorite lines [C1], got %+v", linked.FavoriteLines)
+	}
+	if !reflect.DeepEqual(linked.Metadata, map[string]any{"lang": "gl", "theme": "dark"}) {
+		t.Fatalf("expected merged metadata, got %v", linked.Metadata)
+	}
+	if len(linked.LinkedIdentities) != 1 || linked.LinkedIdentities[0].Provider != api.ProviderTypeTelegram {
+		t.Fatalf("expected the telegram identity to be linked, got %+v", linked.LinkedIdentities)
+	}
+
+	// Changes through any identity are seen by the whole account
+	if _, err := repo.AddFavorite(source.ID, 103); err != nil {
+		t.Fatal(err)
+	}
+	if numbers := stopNumbers(getUser(t, repo, api.ProviderTypeTelegram, "1")); !slices.Equal(numbers, []int{100, 101, 102, 103}) {
+		t.Fatalf("expected favorite stops [100 101 102 103], got %v", numbers)
+	}
+
+	if _, err := repo.LinkIdentity(source.ID, "CODE"); !errors.Is(err, storage.ErrInvalidLinkCode) {
+		t.Fatalf("expected ErrInvalidLinkCode for a used code, got %v", err)
+	}
+	if err := repo.CreateLinkCode(target.ID, "AGAIN", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.LinkIdentity(source.ID, "AGAIN"); !errors.Is(err, storage.ErrAlreadyLinked) {
+		t.Fatalf("expected ErrAlreadyLinked, got %v", err)
+	}
+}
+
+func testDeleteIdentity(t *testing.T, repo storage.IdentityRepository) {
+	target := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+	source := insertUser(t, repo, api.ProviderTypeDiscord, "1")
+	if _, err := repo.AddFavorite(target.ID, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateLinkCode(target.ID, "CODE", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.LinkIdentity(source.ID, "CODE"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A deleted identity is hidden right away and its favorites are kept by the rest of the account
+	if err := repo.SoftDeleteIdentity(target.ID); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := repo.GetUserByUUID(string(api.ProviderTypeTelegram), "1"); err != nil || deleted != nil {
+		t.Fatalf("expected the deleted identity to be hidden, got %+v: %v", deleted, err)
+	}
+	if deleted, err := repo.GetIdentity(target.ID); err != nil || deleted != nil {
+		t.Fatalf("expected the deleted identity to be hidden, got %+v: %v", deleted, err)
+	}
+	heir := getUser(t, repo, api.ProviderTypeDiscord, "1")
+	if numbers := stopNumbers(heir); !slices.Equal(numbers, []int{100}) || len(heir.LinkedIdentities) != 0 {
+		t.Fatalf("expected the favorite stops to be handed over and no linked identities, got %+v", heir)
+	}
+
+	// The UUID can be used again before the deleted identity is purged
+	again := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+	if again.AccountID == heir.AccountID || len(again.FavoriteStops) != 0 {
+		t.Fatalf("expected a new account without favorites, got %+v", again)
+	}
+
+	if err := repo.SoftDeleteIdentity(again.ID); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := repo.PurgeDeletedIdentities(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("expected nothing to be purged yet, got %d: %v", purged, err)
+	}
+	if purged, err := repo.PurgeDeletedIdentities(time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("expected 1 identity to be purged, got %d: %v", purged, err)
+	}
+
+	if err := repo.DeleteIdentity(heir.ID); err != nil {
+		t.Fatal(err)
+	}
+	identities, err := repo.ListIdentities()
+	if err != nil || len(identities) != 0 {
+		t.Fatalf("expected no identities, got %+v: %v", identities, err)
+	}
+}
+
+func testIdentityEvents(t *testing.T, repo storage.IdentityRepository) {
+	user := insertUser(t, repo, api.ProviderTypeTelegram, "1")
+
+	events, err := repo.ListIdentityEvents(user.ID)
+	if err != nil || events == nil || len(events) != 0 {
+		t.Fatalf("expected an empty history, got %#v: %v", events, err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	for _, event := range []api.IdentityEvent{
+		{Action: api.IdentityActionCreated, CreatedAt: createdAt},
+		{Action: api.IdentityActionFavoriteAdded, Detail: "100", CreatedAt: createdAt},
+	} {
+		if err := repo.AddIdentityEvent(user.ID, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err = repo.ListIdentityEvents(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != api.IdentityActionCreated || events[1].Detail != "100" || !events[1].CreatedAt.Equal(createdAt) {
+		t.Fatalf("expected the created and favorite_added events, got %+v", events)
+	}
+
+	if err := repo.DeleteIdentity(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := repo.ListIdentityEvents(user.ID); err != nil || len(events) != 0 {
+		t.Fatalf("expected the history to be deleted, got %+v: %v", events, err)
+	}
+}
+
+// TokenRepository runs the checks of an API token repository. newRepository returns an empty repository
+func TokenRepository(t *testing.T, newRepository func(t *testing.T) storage.TokenRepository) {
+	repo := newRepository(t)
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	expiresAt := createdAt.Add(time.Hour)
+	first := &api.Token{Name: "bot", Scopes: []api.Scope{api.ScopeStopsRead, api.ScopeUsersWrite}, Providers: []api.ProviderType{api.ProviderTypeTelegram}, CreatedAt: createdAt, ExpiresAt: &expiresAt}
+	second := &api.Token{Name: "admin", Scopes: []api.Scope{api.ScopeAdmin}, CreatedAt: createdAt}
+	for _, token := range []*api.Token{first, second} {
+		if err := repo.InsertToken(token, "hash-"+token.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Fatalf("expected increasing token IDs, got %d and %d", first.ID, second.ID)
+	}
+
+	token, err := repo.GetTokenByHash("hash-bot")
+	if err != nil || token == nil {
+		t.Fatalf("expected the token, got %+v: %v", token, err)
+	}
+	if token.ID != first.ID || !slices.Equal(token.Scopes, first.Scopes) || !slices.Equal(token.Providers, first.Providers) {
+		t.Fatalf("expected token %+v, got %+v", first, token)
+	}
+	if !token.CreatedAt.Equal(createdAt) || token.ExpiresAt == nil || !token.ExpiresAt.Equal(expiresAt) || token.RevokedAt != nil {
+		t.Fatalf("expected the times of the token to be kept, got %+v", token)
+	}
+	if missing, err := repo.GetTokenByHash("unknown"); err != nil || missing != nil {
+		t.Fatalf("expected no token for an unknown hash, got %+v: %v", missing, err)
+	}
+
+	if err := repo.RevokeToken(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := repo.GetTokenByHash("hash-admin")
+	if err != nil || revoked == nil || revoked.RevokedAt == nil {
+		t.Fatalf("expected the token to be revoked, got %+v: %v", revoked, err)
+	}
+	if err := repo.RevokeToken(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := repo.GetTokenByHash("hash-admin"); again == nil || again.RevokedAt == nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Fatalf("expected the first revocation time to be kept, got %+v", again)
+	}
+	if err := repo.RevokeToken(second.ID + 100); !errors.Is(err, storage.ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+
+	tokens, err := repo.ListTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0].ID != first.ID || tokens[1].RevokedAt == nil {
+		t.Fatalf("expected both tokens sorted by ID, got %+v", tokens)
+	}
+}
+
+// insertUser inserts an identity without favorites and returns it
+func insertUser(t *testing.T, repo storage.IdentityRepository, provider api.ProviderType, uuid string) api.Identity {
+	t.Helper()
+
+	identity := &api.Identity{Provider: provider, UUID: uuid}
+	if err := repo.InsertIdentity(identity); err != nil {
+		t.Fatal(err)
+	}
+	return getUser(t, repo, provider, uuid)
+}
+
+// getUser retrieves an identity by provider and UUID, failing the test if it doesn't exist
+func getUser(t *testing.T, repo storage.IdentityRepository, provider api.ProviderType, uuid string) api.Identity {
+	t.Helper()
+
+	identity, err := repo.GetUserByUUID(string(provider), uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity == nil {
+		t.Fatalf("expected identity %s/%s to exist", provider, uuid)
+	}
+	return *identity
+}
+
+// setMetadata replaces the metadata of the account of an identity
+func setMetadata(t *testing.T, repo storage.IdentityRepository, identityID int, metadata map[string]any) {
+	t.Helper()
+
+	err := repo.UpdateMetadata(identityID, func(map[string]any) (map[string]any, error) {
+		return metadata, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stopNumbers returns the stop numbers of the favorite stops of an identity in order
+func stopNumbers(identity api.Identity) []int {
+	numbers := []int{}
+	for _, favorite := range identity.FavoriteStops {
+		numbers = append(numbers, favorite.StopNumber)
+	}
+	return numbers
+}
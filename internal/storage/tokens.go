@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// EncodeScopes converts the scopes of an API token to the space-separated text stored in the database
+func EncodeScopes(scopes []api.Scope) string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, " ")
+}
+
+// DecodeScopes converts the space-separated text stored in the database to the scopes of an API token
+func DecodeScopes(stored string) []api.Scope {
+	scopes := []api.Scope{}
+	for _, name := range strings.Fields(stored) {
+		scopes = append(scopes, api.Scope(name))
+	}
+	return scopes
+}
+
+// EncodeProviders converts the identity providers an API token is bound to to the space-separated text stored in the database
+func EncodeProviders(providers []api.ProviderType) string {
+	names := make([]string, len(providers))
+	for i, provider := range providers {
+		names[i] = string(provider)
+	}
+	return strings.Join(names, " ")
+}
+
+// DecodeProviders converts the space-separated text stored in the database to the identity providers an API token is bound to
+func DecodeProviders(stored string) []api.ProviderType {
+	providers := []api.ProviderType{}
+	for _, name := range strings.Fields(stored) {
+		providers = append(providers, api.ProviderType(name))
+	}
+	return providers
+}
+
+// EncodeTime converts an optional time to the RFC 3339 text stored in the database, NULL if it is not set
+func EncodeTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}
+}
+
+// DecodeTime converts the RFC 3339 text stored in the database to an optional time, nil if it is NULL
+func DecodeTime(stored sql.NullString) *time.Time {
+	if !stored.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, stored.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
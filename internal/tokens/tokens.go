@@ -0,0 +1,69 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/eryalito/vigo-bus-core/pkg/api"
+)
+
+// ErrUnknownScope is returned when a scope can't be granted because it doesn't exist
+var ErrUnknownScope = errors.New("unknown scope")
+
+// prefix is prepended to the generated tokens so they are easy to recognize, such as by secret scanners
+const prefix = "vbc_"
+
+// secretBytes is the number of random bytes of a generated token
+const secretBytes = 32
+
+// scopes holds the scopes that can be granted to a token
+var scopes = []api.Scope{api.ScopeStopsRead, api.ScopeSchedulesRead, api.ScopeUsersWrite, api.ScopeAdmin}
+
+// Generate creates a new random token
+func Generate() (string, error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return prefix + hex.EncodeToString(secret), nil
+}
+
+// Hash returns the hash of a token, which is how tokens are stored and looked up
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateScopes checks that every scope exists
+func ValidateScopes(requested []api.Scope) error {
+	for _, scope := range requested {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+	}
+	return nil
+}
+
+// Allows tells whether a token grants a scope, which tokens with the admin scope always do
+func Allows(token *api.Token, scope api.Scope) bool {
+	return slices.Contains(token.Scopes, scope) || slices.Contains(token.Scopes, api.ScopeAdmin)
+}
+
+// AllowsProvider tells whether a token can access the users of an identity provider, which tokens
+// not bound to any provider always do
+func AllowsProvider(token *api.Token, provider api.ProviderType) bool {
+	return len(token.Providers) == 0 || slices.Contains(token.Providers, provider)
+}
+
+// Active tells whether a token can be used at the given time, being neither revoked nor expired
+func Active(token *api.Token, now time.Time) bool {
+	if token.RevokedAt != nil {
+		return false
+	}
+	return token.ExpiresAt == nil || now.Before(*token.ExpiresAt)
+}
@@ -24,7 +24,7 @@ func PngToBase64(img image.Image) (string, error) {
 func GenerateImageWithMarkers(apiKey string, origin struct {
 	Lat float64 `json:"lat"`
 	Lon float64 `json:"lon"`
-}, stops []api.Stop) (image.Image, error) {
+}, stops []api.NearbyStop) (image.Image, error) {
 	// Construct the Google Maps Static API URL
 	baseURL := "https://maps.googleapis.com/maps/api/staticmap"
 	size := "600x400"
@@ -7,7 +7,7 @@ import (
 	"regexp"
 	"strconv"
 
-	"github.com/eryalito/vigo-bus-core/internal/sqlite"
+	"github.com/eryalito/vigo-bus-core/internal/storage"
 	"github.com/eryalito/vigo-bus-core/pkg/api"
 
 	"golang.org/x/net/html"
@@ -16,12 +16,16 @@ import (
 type VitrasaClient struct {
 	// ScheduleEndpoint is the base ScheduleEndpoint of the Vitrasa API
 	ScheduleEndpoint string
+
+	// Lines is the repository used to look up the lines found in the schedules
+	Lines storage.LineRepository
 }
 
-// NewVitrasaClient creates a new VitrasaClient with the given URL
-func NewVitrasaClient() *VitrasaClient {
+// NewVitrasaClient creates a new VitrasaClient that looks up lines in the given repository
+func NewVitrasaClient(lines storage.LineRepository) *VitrasaClient {
 	return &VitrasaClient{
 		ScheduleEndpoint: "http://infobus.vitrasa.es:8002/Default.aspx",
+		Lines:            lines,
 	}
 }
 
@@ -43,7 +47,7 @@ func (c *VitrasaClient) GetSchedules(stopNumber int) ([]api.Schedule, error) {
 	}
 
 	// Extract the schedules information from the HTML
-	schedules, err := extractSchedule(doc)
+	schedules, err := c.extractSchedule(doc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract schedule: %v", err)
 	}
@@ -52,7 +56,7 @@ func (c *VitrasaClient) GetSchedules(stopNumber int) ([]api.Schedule, error) {
 }
 
 // extractSchedule extracts the schedule information from the HTML document
-func extractSchedule(n *html.Node) ([]api.Schedule, error) {
+func (c *VitrasaClient) extractSchedule(n *html.Node) ([]api.Schedule, error) {
 	targetNode := findNodeById(n, "GridView1")
 	if targetNode == nil {
 		fmt.Println("GridView1 node not found")
@@ -67,8 +71,8 @@ func extractSchedule(n *html.Node) ([]api.Schedule, error) {
 	var schedules []api.Schedule
 
 	count := 0
-	for c := targetNode.FirstChild; c != nil; c = c.NextSibling {
-		if c.Type == html.ElementNode && c.Data == "tr" {
+	for row := targetNode.FirstChild; row != nil; row = row.NextSibling {
+		if row.Type == html.ElementNode && row.Data == "tr" {
 			if count == 0 {
 				// Skip the header row
 				count++
@@ -78,7 +82,7 @@ func extractSchedule(n *html.Node) ([]api.Schedule, error) {
 			var schedule api.Schedule
 			fieldCounter := 0
 
-			for field := c.FirstChild; field != nil; field = field.NextSibling {
+			for field := row.FirstChild; field != nil; field = field.NextSibling {
 				if field.Type == html.ElementNode && field.Data == "td" {
 					fieldCounter++
 					childNode := findNode(field, []string{"font"}).FirstChild
@@ -86,7 +90,7 @@ func extractSchedule(n *html.Node) ([]api.Schedule, error) {
 						data := childNode.Data
 						switch fieldCounter {
 						case 1:
-							line, err := retrieveLine(data)
+							line, err := c.retrieveLine(data)
 							if err != nil {
 								fmt.Printf("Error retrieving line %s: %v\n", data, err)
 								return nil, errors.New("error retrieving line")
@@ -113,14 +117,8 @@ func extractSchedule(n *html.Node) ([]api.Schedule, error) {
 	return schedules, nil
 }
 
-func retrieveLine(name string) (api.Line, error) {
-	// Perform the GET request
-
-	sdb_conn, err := sqlite.NewBusConnector()
-	if err != nil {
-		return api.Line{}, fmt.Errorf("failed to get line by name: %v", err)
-	}
-	line, err := sdb_conn.GetLineByName(name)
+func (c *VitrasaClient) retrieveLine(name string) (api.Line, error) {
+	line, err := c.Lines.GetLineByName(name)
 	if err != nil {
 		return api.Line{}, fmt.Errorf("failed to get line by name: %v", err)
 	}
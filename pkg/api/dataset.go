@@ -0,0 +1,119 @@
+package api
+
+import "time"
+
+// DatasetEntity is an enum that represents the kinds of records of the dataset
+type DatasetEntity string
+
+const (
+	// DatasetEntityStop represents a bus stop
+	DatasetEntityStop DatasetEntity = "stop"
+	// DatasetEntityLine represents a bus line
+	DatasetEntityLine DatasetEntity = "line"
+)
+
+// DatasetChangeAction is an enum that represents the ways a record of the dataset can change
+type DatasetChangeAction string
+
+const (
+	// DatasetChangeAdded represents a record that didn't exist before
+	DatasetChangeAdded DatasetChangeAction = "added"
+	// DatasetChangeUpdated represents a stop whose lines or internal ID changed, or that moved just a few meters
+	DatasetChangeUpdated DatasetChangeAction = "updated"
+	// DatasetChangeRenamed represents a stop whose name changed
+	DatasetChangeRenamed DatasetChangeAction = "renamed"
+	// DatasetChangeMoved represents a stop that moved farther than the configured distance
+	DatasetChangeMoved DatasetChangeAction = "moved"
+	// DatasetChangeRemoved represents a record that no longer exists
+	DatasetChangeRemoved DatasetChangeAction = "removed"
+)
+
+// DatasetChange is a change to a stop or line made by an import of the dataset
+type DatasetChange struct {
+	// Version is the dataset version that made the change
+	Version int `json:"version"`
+
+	// ImportedAt is the time the dataset version was imported
+	ImportedAt time.Time `json:"imported_at"`
+
+	// Entity is the kind of record that changed
+	Entity DatasetEntity `json:"entity"`
+
+	// Action is the way the record changed
+	Action DatasetChangeAction `json:"action"`
+
+	// StopNumber is the number of the stop that changed, only for stops
+	StopNumber int `json:"stop_number,omitempty"`
+
+	// Line is the name of the line that changed, only for lines
+	Line string `json:"line,omitempty"`
+
+	// Name is the name of the stop after the change, only for stops that were not removed
+	Name string `json:"name,omitempty"`
+
+	// PreviousName is the name of the stop before the change, only for stops that were not added
+	PreviousName string `json:"previous_name,omitempty"`
+
+	// Location is the location of the stop after the change, only for stops that were not removed
+	Location *Coordinates `json:"location,omitempty"`
+
+	// PreviousLocation is the location of the stop before the change, only for stops that were not added
+	PreviousLocation *Coordinates `json:"previous_location,omitempty"`
+
+	// Distance is how far the stop moved in meters, only for stops that were not added or removed
+	Distance float64 `json:"distance_m,omitempty"`
+}
+
+// DatasetChanges is the list of changes made by the imports after a given version or time
+type DatasetChanges struct {
+	// Version is the number of the last import
+	Version int `json:"version"`
+
+	// Changes is the list of changes, oldest first
+	Changes []DatasetChange `json:"changes"`
+}
+
+// DatasetVersion is an import of the open data dataset that changed the stops or lines
+type DatasetVersion struct {
+	// Version is the sequential number of the import
+	Version int `json:"version"`
+
+	// Source is the URL the dataset was downloaded from
+	Source string `json:"source"`
+
+	// ImportedAt is the time the dataset was imported
+	ImportedAt time.Time `json:"imported_at"`
+
+	// StopsAdded is the number of new stops
+	StopsAdded int `json:"stops_added"`
+
+	// StopsUpdated is the number of stops that were renamed, moved or whose lines changed
+	StopsUpdated int `json:"stops_updated"`
+
+	// StopsRemoved is the number of stops that no longer exist
+	StopsRemoved int `json:"stops_removed"`
+
+	// LinesAdded is the number of new lines
+	LinesAdded int `json:"lines_added"`
+
+	// LinesRemoved is the number of lines that no longer exist
+	LinesRemoved int `json:"lines_removed"`
+}
+
+// Dataset describes the stops and lines currently served
+type Dataset struct {
+	// Stops is the number of stops in the dataset
+	Stops int `json:"stops"`
+
+	// Lines is the number of lines in the dataset
+	Lines int `json:"lines"`
+
+	// LoadedAt is the time the dataset was loaded
+	LoadedAt time.Time `json:"loaded_at"`
+
+	// Version is the number of the last import, 0 if the dataset was never updated since it was generated
+	Version int `json:"version"`
+
+	// Versions is the list of the latest imports, newest first
+	Versions []DatasetVersion `json:"versions"`
+}
@@ -0,0 +1,29 @@
+package api
+
+// Departure is a bus that is about to pass by a stop near a location
+type Departure struct {
+	// Stop is the stop where the bus can be taken, with the walking distance from the location
+	Stop NearbyStop `json:"stop"`
+
+	// Line is the line of the bus
+	Line Line `json:"line"`
+
+	// Route is the route of the bus
+	Route string `json:"route"`
+
+	// Time is the time in minutes until the bus arrives at the stop
+	Time int `json:"time"`
+
+	// LeaveIn is the time in minutes left before walking to the stop to catch the bus
+	LeaveIn int `json:"leave_in"`
+}
+
+// NearbyDepartures is the list of the next buses leaving from the stops around a location
+type NearbyDepartures struct {
+	Origin struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"origin"`
+	Radius     float64     `json:"radius"`
+	Departures []Departure `json:"departures"`
+}
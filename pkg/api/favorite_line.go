@@ -0,0 +1,9 @@
+package api
+
+// FavoriteLine is a line saved by a user
+type FavoriteLine struct {
+	Line
+
+	// Missing tells that the line no longer exists in the lines dataset
+	Missing bool `json:"missing,omitempty"`
+}
@@ -0,0 +1,13 @@
+package api
+
+// FavoriteRoute is a trip between two stops saved by a user
+type FavoriteRoute struct {
+	// Origin is the stop where the trip starts
+	Origin Stop `json:"origin"`
+
+	// Destination is the stop where the trip ends
+	Destination Stop `json:"destination"`
+
+	// Lines are the lines that go from the origin to the destination without changing buses
+	Lines []Line `json:"lines"`
+}
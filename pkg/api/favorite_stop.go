@@ -0,0 +1,31 @@
+package api
+
+// FavoriteStop is a stop saved by a user, along with how the user labels and uses it
+type FavoriteStop struct {
+	Stop
+
+	// Alias is the name given by the user to the stop, such as Home or Work
+	Alias string `json:"alias"`
+
+	// Position is the place of the stop in the list of favorites of the user, starting at 0
+	Position int `json:"position"`
+
+	// Lines are the names of the lines the user cares about at the stop, all of them if empty
+	Lines []string `json:"lines"`
+}
+
+// FavoriteStopUpdate is the body of the request that changes a favorite stop. Only the fields that
+// are present are changed
+type FavoriteStopUpdate struct {
+	// Alias is the new name given by the user to the stop, empty to remove it
+	Alias *string `json:"alias,omitempty"`
+
+	// Lines are the new names of the lines the user cares about at the stop, empty for all of them
+	Lines *[]string `json:"lines,omitempty"`
+}
+
+// FavoriteStopsOrder is the body of the request that reorders the favorite stops of a user
+type FavoriteStopsOrder struct {
+	// StopNumbers are the numbers of every favorite stop of the user, in the new order
+	StopNumbers []int `json:"stop_numbers" binding:"required"`
+}
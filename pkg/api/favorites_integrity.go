@@ -0,0 +1,51 @@
+package api
+
+import "time"
+
+// MissingFavorite is a favorite stop that no longer exists in the stops dataset
+type MissingFavorite struct {
+	// StopNumber is the number of the missing stop
+	StopNumber int `json:"stop_number"`
+
+	// PreviousName is the name the stop had before it was removed, if the removal was recorded
+	PreviousName string `json:"previous_name,omitempty"`
+
+	// PreviousLocation is the location the stop had before it was removed, if the removal was recorded
+	PreviousLocation *Coordinates `json:"previous_location,omitempty"`
+
+	// RemovedInVersion is the dataset version that removed the stop, if the removal was recorded
+	RemovedInVersion int `json:"removed_in_version,omitempty"`
+
+	// Replacement is the stop suggested instead, either the nearest one to where the stop was or the one with the closest name
+	Replacement *Stop `json:"replacement,omitempty"`
+
+	// ReplacementDistance is the distance in meters between the missing stop and the replacement, only when found by location
+	ReplacementDistance float64 `json:"replacement_distance_m,omitempty"`
+}
+
+// DanglingFavorites is an identity with favorite stops that no longer exist
+type DanglingFavorites struct {
+	// ID is the unique identifier of the identity
+	ID int `json:"id"`
+
+	// UUID is the unique identifier of the identity, usually provided by the auth provider
+	UUID string `json:"uuid"`
+
+	// Provider is the type of the identity provider
+	Provider ProviderType `json:"provider"`
+
+	// Missing is the list of favorite stops that no longer exist
+	Missing []MissingFavorite `json:"missing"`
+}
+
+// FavoritesIntegrity is the result of checking every favorite stop against the stops dataset
+type FavoritesIntegrity struct {
+	// CheckedAt is the time the check was made
+	CheckedAt time.Time `json:"checked_at"`
+
+	// Identities is the number of identities checked
+	Identities int `json:"identities"`
+
+	// Dangling is the list of identities with favorite stops that no longer exist
+	Dangling []DanglingFavorites `json:"dangling"`
+}
@@ -6,6 +6,14 @@ type ProviderType string
 const (
 	// ProviderTypeTelegram represents the Telegram identity provider
 	ProviderTypeTelegram ProviderType = "telegram"
+	// ProviderTypeDiscord represents the Discord identity provider
+	ProviderTypeDiscord ProviderType = "discord"
+	// ProviderTypeMatrix represents the Matrix identity provider
+	ProviderTypeMatrix ProviderType = "matrix"
+	// ProviderTypeWeb represents the users of the web app
+	ProviderTypeWeb ProviderType = "web"
+	// ProviderTypeWhatsApp represents the WhatsApp identity provider
+	ProviderTypeWhatsApp ProviderType = "whatsapp"
 )
 
 // Identity is a struct that holds the information of a user, including their auth provider type and ID
@@ -13,15 +21,36 @@ type Identity struct {
 	// ID is the unique identifier of the identity
 	ID int `json:"id"`
 
+	// AccountID is the unique identifier of the account that groups the linked identities
+	AccountID int `json:"account_id"`
+
 	// UUID is the unique identifier of the identity, usually provided by the auth provider
 	UUID string `json:"uuid"`
 
 	// Provider is the type of the identity provider
 	Provider ProviderType `json:"provider"`
 
-	// FavoriteStops is a list of the user's favorite stops
-	FavoriteStops []Stop `json:"favorite_stops"`
+	// FavoriteStops is a list of the user's favorite stops, in the order chosen by the user
+	FavoriteStops []FavoriteStop `json:"favorite_stops"`
+
+	// FavoriteLines is a list of the user's favorite lines, in the order they were added
+	FavoriteLines []FavoriteLine `json:"favorite_lines"`
+
+	// FavoriteRoutes is a list of the user's favorite trips between two stops, in the order they were added
+	FavoriteRoutes []FavoriteRoute `json:"favorite_routes"`
+
+	// Metadata is a JSON object that holds additional information about the identity, shared by its account
+	Metadata map[string]any `json:"metadata"`
+
+	// LinkedIdentities is a list of the other identities of the same account
+	LinkedIdentities []LinkedIdentity `json:"linked_identities,omitempty"`
+}
 
-	// Metadata is a genric string that holds additional information about the identity
-	Metadata string `json:"metadata"`
+// LinkedIdentity is an identity linked to the same account as another one
+type LinkedIdentity struct {
+	// Provider is the type of the identity provider
+	Provider ProviderType `json:"provider"`
+
+	// UUID is the unique identifier of the identity, usually provided by the auth provider
+	UUID string `json:"uuid"`
 }
@@ -0,0 +1,59 @@
+package api
+
+import "time"
+
+// IdentityAction is an enum that represents the actions recorded in the history of an identity
+type IdentityAction string
+
+const (
+	// IdentityActionCreated represents the creation of the identity
+	IdentityActionCreated IdentityAction = "created"
+	// IdentityActionFavoriteAdded represents a stop added to the favorites
+	IdentityActionFavoriteAdded IdentityAction = "favorite_added"
+	// IdentityActionFavoriteRemoved represents a stop removed from the favorites
+	IdentityActionFavoriteRemoved IdentityAction = "favorite_removed"
+	// IdentityActionFavoriteUpdated represents a change of the alias or the lines of a favorite stop
+	IdentityActionFavoriteUpdated IdentityAction = "favorite_updated"
+	// IdentityActionFavoritesReordered represents a change of the order of the favorite stops
+	IdentityActionFavoritesReordered IdentityAction = "favorites_reordered"
+	// IdentityActionFavoriteLineAdded represents a line added to the favorites
+	IdentityActionFavoriteLineAdded IdentityAction = "favorite_line_added"
+	// IdentityActionFavoriteLineRemoved represents a line removed from the favorites
+	IdentityActionFavoriteLineRemoved IdentityAction = "favorite_line_removed"
+	// IdentityActionFavoriteRouteAdded represents a route between two stops added to the favorites
+	IdentityActionFavoriteRouteAdded IdentityAction = "favorite_route_added"
+	// IdentityActionFavoriteRouteRemoved represents a route between two stops removed from the favorites
+	IdentityActionFavoriteRouteRemoved IdentityAction = "favorite_route_removed"
+	// IdentityActionMetadataUpdated represents a change of the metadata
+	IdentityActionMetadataUpdated IdentityAction = "metadata_updated"
+	// IdentityActionLinkCodeCreated represents the creation of a code to link another identity
+	IdentityActionLinkCodeCreated IdentityAction = "link_code_created"
+	// IdentityActionLinked represents the identity being linked to another account
+	IdentityActionLinked IdentityAction = "linked"
+	// IdentityActionExported represents an export of the data of the identity
+	IdentityActionExported IdentityAction = "exported"
+)
+
+// IdentityEvent is an entry of the history of an identity
+type IdentityEvent struct {
+	// Action is what was done
+	Action IdentityAction `json:"action"`
+
+	// Detail tells what the action was applied to, such as the stop number of a favorite
+	Detail string `json:"detail,omitempty"`
+
+	// CreatedAt is when the action was done
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IdentityExport is the bundle of all the data kept about an identity
+type IdentityExport struct {
+	// ExportedAt is when the export was made
+	ExportedAt time.Time `json:"exported_at"`
+
+	// Identity is the identity along with its favorite stops, metadata and linked identities
+	Identity Identity `json:"identity"`
+
+	// History is the list of actions done on the identity, oldest first
+	History []IdentityEvent `json:"history"`
+}
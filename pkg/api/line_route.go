@@ -0,0 +1,13 @@
+package api
+
+// LineRoute is the ordered list of stops a line goes through in one direction
+type LineRoute struct {
+	// Line is the line the route belongs to
+	Line Line `json:"line"`
+
+	// Direction tells apart the routes of the same line, usually 0 for the outward trip and 1 for the return
+	Direction int `json:"direction"`
+
+	// Stops is the list of stops of the route in the order the bus goes through them
+	Stops []Stop `json:"stops"`
+}
@@ -0,0 +1,18 @@
+package api
+
+import "time"
+
+// LinkCode is a one-time code that links another identity to the account of the identity that requested it
+type LinkCode struct {
+	// Code is the value to send from the identity to link
+	Code string `json:"code"`
+
+	// ExpiresAt is when the code stops being valid
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LinkRequest is the body of the request that links an identity using a code
+type LinkRequest struct {
+	// Code is the link code requested from the other identity
+	Code string `json:"code" binding:"required"`
+}
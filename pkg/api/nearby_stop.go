@@ -0,0 +1,18 @@
+package api
+
+// NearbyStop is a bus stop found around a location, along with how far it is from it
+type NearbyStop struct {
+	Stop
+
+	// Distance is the distance in meters from the location to the stop
+	Distance float64 `json:"distance_m"`
+
+	// Bearing is the direction in degrees clockwise from north to walk from the location to the stop
+	Bearing float64 `json:"bearing"`
+
+	// Compass is the compass point closest to the bearing, such as N or SW
+	Compass string `json:"compass"`
+
+	// WalkingMinutes is the estimated time in minutes to walk from the location to the stop
+	WalkingMinutes int `json:"walking_minutes"`
+}
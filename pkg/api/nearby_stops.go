@@ -5,7 +5,7 @@ type NearbyStops struct {
 		Lat float64 `json:"lat"`
 		Lon float64 `json:"lon"`
 	} `json:"origin"`
-	Radius  float64 `json:"radius"`
-	Stops   []Stop  `json:"stops"`
-	Image64 string  `json:"image"`
+	Radius  float64      `json:"radius"`
+	Stops   []NearbyStop `json:"stops"`
+	Image64 string       `json:"image"`
 }
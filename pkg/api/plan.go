@@ -0,0 +1,99 @@
+package api
+
+import "time"
+
+// LegMode is an enum that represents the ways of moving along a leg of an itinerary
+type LegMode string
+
+const (
+	// LegModeWalk represents a leg made on foot
+	LegModeWalk LegMode = "walk"
+	// LegModeBus represents a leg made on a bus line
+	LegModeBus LegMode = "bus"
+)
+
+// Coordinates is a geographical location
+type Coordinates struct {
+	// Lat is the latitude of the location
+	Lat float64 `json:"lat"`
+	// Lon is the longitude of the location
+	Lon float64 `json:"lon"`
+}
+
+// Place is the start or the end of a leg, either a stop or one of the requested locations
+type Place struct {
+	// Location is the geographical location of the place
+	Location Coordinates `json:"location"`
+
+	// Stop is the stop at the place, empty for the origin and destination of the trip
+	Stop *Stop `json:"stop,omitempty"`
+}
+
+// Leg is a part of an itinerary made with a single mode of transport
+type Leg struct {
+	// Mode is the way of moving along the leg
+	Mode LegMode `json:"mode"`
+
+	// From is where the leg starts
+	From Place `json:"from"`
+
+	// To is where the leg ends
+	To Place `json:"to"`
+
+	// Line is the bus line to take, only for bus legs
+	Line *Line `json:"line,omitempty"`
+
+	// Stops is the list of stops the bus goes through, including where it is boarded and left, only for bus legs
+	Stops []Stop `json:"stops,omitempty"`
+
+	// Departure is the estimated time the leg starts
+	Departure time.Time `json:"departure"`
+
+	// Arrival is the estimated time the leg ends
+	Arrival time.Time `json:"arrival"`
+
+	// Duration is the estimated duration of the leg in minutes
+	Duration int `json:"duration"`
+
+	// Distance is the distance covered by the leg in meters
+	Distance float64 `json:"distance_m"`
+}
+
+// Itinerary is a way of getting from the origin to the destination of a trip
+type Itinerary struct {
+	// Departure is the time the trip starts
+	Departure time.Time `json:"departure"`
+
+	// Arrival is the estimated time the destination is reached
+	Arrival time.Time `json:"arrival"`
+
+	// Duration is the estimated duration of the trip in minutes
+	Duration int `json:"duration"`
+
+	// Transfers is the number of times the rider changes buses
+	Transfers int `json:"transfers"`
+
+	// WalkingDistance is the total distance walked in meters
+	WalkingDistance float64 `json:"walking_distance_m"`
+
+	// Legs is the list of parts of the trip in order
+	Legs []Leg `json:"legs"`
+
+	// TransferStops is the list of stops where the rider changes buses
+	TransferStops []Stop `json:"transfer_stops"`
+}
+
+// Plan is the list of itineraries found between two locations
+type Plan struct {
+	// From is the origin of the trip
+	From Coordinates `json:"from"`
+
+	// To is the destination of the trip
+	To Coordinates `json:"to"`
+
+	// DepartAt is the time the trip starts
+	DepartAt time.Time `json:"depart_at"`
+
+	// Itineraries is the list of itineraries found, from fewer to more transfers
+	Itineraries []Itinerary `json:"itineraries"`
+}
@@ -0,0 +1,104 @@
+package api
+
+import "net/http"
+
+// ProblemCode is an enum that represents the stable, machine-readable kinds of errors returned by the API
+type ProblemCode string
+
+const (
+	// ProblemInternalError represents an unexpected error, whose details are only logged
+	ProblemInternalError ProblemCode = "internal_error"
+	// ProblemInvalidParameter represents a missing or invalid query parameter
+	ProblemInvalidParameter ProblemCode = "invalid_parameter"
+	// ProblemInvalidCoordinates represents an invalid latitude or longitude
+	ProblemInvalidCoordinates ProblemCode = "invalid_coordinates"
+	// ProblemInvalidStopNumber represents a stop number that is not a number
+	ProblemInvalidStopNumber ProblemCode = "invalid_stop_number"
+	// ProblemInvalidBody represents a request body that can't be read
+	ProblemInvalidBody ProblemCode = "invalid_body"
+	// ProblemUnknownProvider represents an identity provider that is not supported
+	ProblemUnknownProvider ProblemCode = "unknown_provider"
+	// ProblemInvalidUUID represents a UUID that doesn't follow the format of its identity provider
+	ProblemInvalidUUID ProblemCode = "invalid_uuid"
+	// ProblemInvalidMetadata represents user metadata that is not a JSON object
+	ProblemInvalidMetadata ProblemCode = "invalid_metadata"
+	// ProblemMetadataTooLarge represents user metadata bigger than the size limit
+	ProblemMetadataTooLarge ProblemCode = "metadata_too_large"
+	// ProblemMetadataSchemaViolation represents user metadata that doesn't match the configured JSON Schema
+	ProblemMetadataSchemaViolation ProblemCode = "metadata_schema_violation"
+	// ProblemStopNotFound represents a stop that doesn't exist
+	ProblemStopNotFound ProblemCode = "stop_not_found"
+	// ProblemLineNotFound represents a line that doesn't exist
+	ProblemLineNotFound ProblemCode = "line_not_found"
+	// ProblemUserNotFound represents a user that doesn't exist
+	ProblemUserNotFound ProblemCode = "user_not_found"
+	// ProblemUserExists represents a user that already exists
+	ProblemUserExists ProblemCode = "user_exists"
+	// ProblemFavoriteExists represents a stop, line or route that is already a favorite of the user
+	ProblemFavoriteExists ProblemCode = "favorite_exists"
+	// ProblemFavoriteNotFound represents a stop, line or route that is not a favorite of the user
+	ProblemFavoriteNotFound ProblemCode = "favorite_not_found"
+	// ProblemInvalidLinkCode represents an account link code that doesn't exist, was already used or has expired
+	ProblemInvalidLinkCode ProblemCode = "invalid_link_code"
+	// ProblemAlreadyLinked represents identities that already belong to the same account
+	ProblemAlreadyLinked ProblemCode = "already_linked"
+	// ProblemInvalidFavoriteOrder represents a new order of the favorite stops that doesn't list each of them once
+	ProblemInvalidFavoriteOrder ProblemCode = "invalid_favorite_order"
+	// ProblemInvalidFavoriteRoute represents a favorite route whose origin and destination are the same stop
+	ProblemInvalidFavoriteRoute ProblemCode = "invalid_favorite_route"
+	// ProblemLineNotServingStop represents a line that doesn't stop at the requested stop
+	ProblemLineNotServingStop ProblemCode = "line_not_serving_stop"
+	// ProblemUpstreamUnavailable represents a failure of an external service, such as the Vitrasa schedules
+	ProblemUpstreamUnavailable ProblemCode = "upstream_unavailable"
+	// ProblemDatasetUnavailable represents a stops dataset that can't be described or reloaded
+	ProblemDatasetUnavailable ProblemCode = "dataset_unavailable"
+	// ProblemInvalidDataset represents a stops database file that can't be loaded
+	ProblemInvalidDataset ProblemCode = "invalid_dataset"
+	// ProblemForbidden represents a missing or invalid token
+	ProblemForbidden ProblemCode = "forbidden"
+	// ProblemInsufficientScope represents a valid token that doesn't grant the permission the endpoint requires
+	ProblemInsufficientScope ProblemCode = "insufficient_scope"
+	// ProblemInvalidScope represents a scope that doesn't exist in a request to create a token
+	ProblemInvalidScope ProblemCode = "invalid_scope"
+	// ProblemProviderNotAllowed represents a token that isn't bound to the provider of the requested user
+	ProblemProviderNotAllowed ProblemCode = "provider_not_allowed"
+	// ProblemTokenNotFound represents an API token that doesn't exist
+	ProblemTokenNotFound ProblemCode = "token_not_found"
+	// ProblemRateLimited represents a client that made too many requests
+	ProblemRateLimited ProblemCode = "rate_limited"
+)
+
+// Problem is an error response following RFC 7807, served as application/problem+json
+type Problem struct {
+	// Type is a URI that identifies the kind of error
+	Type string `json:"type"`
+
+	// Title is a short summary of the kind of error
+	Title string `json:"title"`
+
+	// Status is the HTTP status code of the response
+	Status int `json:"status"`
+
+	// Detail is a human-readable explanation of this occurrence of the error
+	Detail string `json:"detail"`
+
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+
+	// Code is the stable, machine-readable kind of error
+	Code ProblemCode `json:"code"`
+
+	// RequestID identifies the request in the server logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewProblem creates a Problem of the given kind
+func NewProblem(status int, code ProblemCode, detail string) Problem {
+	return Problem{
+		Type:   "urn:vigo-bus-core:problem:" + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
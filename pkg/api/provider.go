@@ -0,0 +1,16 @@
+package api
+
+// Provider describes an identity provider supported by the API
+type Provider struct {
+	// Type is the value used as provider in the user endpoints
+	Type ProviderType `json:"type"`
+
+	// Name is the display name of the provider
+	Name string `json:"name"`
+
+	// UUIDFormat describes the UUIDs accepted for the provider
+	UUIDFormat string `json:"uuid_format"`
+
+	// UUIDPattern is the regular expression the UUIDs must match
+	UUIDPattern string `json:"uuid_pattern"`
+}
@@ -0,0 +1,36 @@
+package api
+
+// ReachableStop is a stop that can be reached by bus from another one within a time budget
+type ReachableStop struct {
+	Stop
+
+	// Minutes is the estimated travel time in minutes from the origin stop
+	Minutes int `json:"minutes"`
+
+	// Transfers is the number of times the rider changes buses to get to the stop
+	Transfers int `json:"transfers"`
+}
+
+// Polygon is a GeoJSON polygon geometry
+type Polygon struct {
+	// Type is always Polygon
+	Type string `json:"type"`
+
+	// Coordinates is the list of closed rings of the polygon, each one a list of [lon, lat] positions
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// Reachability is the list of stops that can be reached from a stop within a time budget
+type Reachability struct {
+	// Origin is the stop the trips start from
+	Origin Stop `json:"origin"`
+
+	// Minutes is the time budget in minutes
+	Minutes int `json:"minutes"`
+
+	// Stops is the list of reachable stops, sorted by travel time
+	Stops []ReachableStop `json:"stops"`
+
+	// Hull is the convex polygon enclosing the origin and every reachable stop, only when requested
+	Hull *Polygon `json:"hull,omitempty"`
+}
@@ -21,4 +21,7 @@ type Stop struct {
 		// Lon is the longitude of the stop
 		Lon float64 `json:"lon"`
 	} `json:"location"`
+
+	// Missing tells that the stop no longer exists in the stops dataset, only for favorite stops
+	Missing bool `json:"missing,omitempty"`
 }
@@ -0,0 +1,65 @@
+package api
+
+import "time"
+
+// Scope is an enum that represents the permissions that can be granted to an API token
+type Scope string
+
+const (
+	// ScopeStopsRead allows reading the stops, the lines, the trip plans and the dataset
+	ScopeStopsRead Scope = "stops:read"
+	// ScopeSchedulesRead allows reading the live schedules of the stops
+	ScopeSchedulesRead Scope = "schedules:read"
+	// ScopeUsersWrite allows reading and changing the users
+	ScopeUsersWrite Scope = "users:write"
+	// ScopeAdmin allows everything, including reloading the dataset and managing the API tokens
+	ScopeAdmin Scope = "admin"
+)
+
+// Token is an API token issued to a client. Only its hash is stored, so the token itself is only
+// shown when it is created
+type Token struct {
+	// ID is the unique identifier of the token
+	ID int `json:"id"`
+
+	// Name identifies the client using the token, such as telegram-bot or dashboard
+	Name string `json:"name"`
+
+	// Scopes are the permissions granted to the token
+	Scopes []Scope `json:"scopes"`
+
+	// Providers are the identity providers whose users the token can access, all of them if empty
+	Providers []ProviderType `json:"providers"`
+
+	// CreatedAt is when the token was created
+	CreatedAt time.Time `json:"created_at"`
+
+	// ExpiresAt is when the token stops being valid, if it ever does
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// RevokedAt is when the token was revoked, if it was
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TokenRequest is the body of the request that creates an API token
+type TokenRequest struct {
+	// Name identifies the client that will use the token
+	Name string `json:"name" binding:"required"`
+
+	// Scopes are the permissions granted to the token
+	Scopes []Scope `json:"scopes" binding:"required"`
+
+	// Providers are the identity providers whose users the token can access, all of them if empty
+	Providers []ProviderType `json:"providers,omitempty"`
+
+	// ExpiresAt is when the token stops being valid, never if empty
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedToken is an API token that was just created, along with the value clients authenticate with
+type CreatedToken struct {
+	Token
+
+	// Secret is the value to send as bearer token. It can't be retrieved again
+	Secret string `json:"token"`
+}
@@ -6,10 +6,14 @@ rm -f "stops.db"
 echo "Generating the database..."
 echo "Downloading the data..."
 curl -s -o stops.json https://datos.vigo.org/data/transporte/paradas.json
+if [ -n "$STOP_SEQUENCES_URL" ]; then
+    echo "Downloading the stop sequences..."
+    curl -s -o sequences.csv "$STOP_SEQUENCES_URL"
+fi
 echo "Data downloaded"
 echo "Running the Python script..."
 python3 "database_generator_stops_lines.py"
 echo "Database generated"
-rm -f "stops.json"
+rm -f "stops.json" "sequences.csv"
 mkdir -p "$(dirname "$STOPS_DATABASE_PATH")"
 mv "stops.db" "$STOPS_DATABASE_PATH"
\ No newline at end of file
@@ -1,3 +1,5 @@
+import csv
+import os
 import sqlite3
 import json
 
@@ -32,10 +34,22 @@ def create_database(db_path):
         FOREIGN KEY (stop_id) REFERENCES stops(id)
     );"""
 
+    create_line_stop_sequences_table = """
+    CREATE TABLE IF NOT EXISTS line_stop_sequences (
+        line_id INTEGER,
+        direction INTEGER,
+        sequence INTEGER,
+        stop_id INTEGER,
+        PRIMARY KEY (line_id, direction, sequence),
+        FOREIGN KEY (line_id) REFERENCES lines(id),
+        FOREIGN KEY (stop_id) REFERENCES stops(id)
+    );"""
+
     # Execute the SQL statements to create the tables
     cursor.execute(create_lines_table)
     cursor.execute(create_stops_table)
     cursor.execute(create_line_stops_table)
+    cursor.execute(create_line_stop_sequences_table)
 
     # Commit the changes and close the connection
     conn.commit()
@@ -83,6 +97,31 @@ def insert_data(db_path, json_path):
     conn.commit()
     conn.close()
 
+def insert_sequences(db_path, csv_path):
+    # Connect to the SQLite database
+    conn = sqlite3.connect(db_path)
+    cursor = conn.cursor()
+
+    # Read the CSV file with the line, direction, sequence and stop_number columns
+    with open(csv_path, 'r', newline='') as f:
+        for row in csv.DictReader(f):
+            cursor.execute("SELECT id FROM lines WHERE name = ?", (row['line'],))
+            line_row = cursor.fetchone()
+            cursor.execute("SELECT id FROM stops WHERE stop_number = ?", (int(row['stop_number']),))
+            stop_row = cursor.fetchone()
+            if not line_row or not stop_row:
+                print(f"Skipping unknown line {row['line']} or stop {row['stop_number']}")
+                continue
+
+            cursor.execute("""
+                INSERT OR REPLACE INTO line_stop_sequences (line_id, direction, sequence, stop_id)
+                VALUES (?, ?, ?, ?)
+            """, (line_row[0], int(row['direction']), int(row['sequence']), stop_row[0]))
+
+    # Commit the changes and close the connection
+    conn.commit()
+    conn.close()
+
 if __name__ == "__main__":
     # Path to the SQLite database file
     db_path = "stops.db"
@@ -93,4 +132,8 @@ if __name__ == "__main__":
     create_database(db_path)
     # Insert data from the JSON file
     insert_data(db_path, json_path)
+    # Insert the order of the stops of each line if available
+    sequences_path = "sequences.csv"
+    if os.path.exists(sequences_path):
+        insert_sequences(db_path, sequences_path)
     print(f"Database created and data inserted at {db_path}")
\ No newline at end of file